@@ -0,0 +1,100 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// forge-gitcode serves the GitCode forge as an addon forge, so it can be
+// loaded by servers built without it via WOODPECKER_ADDON_FORGE.
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/addon"
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/gitcode"
+)
+
+func main() {
+	// addons run in their own process and can't read the server config,
+	// so the options are taken from the environment the server passes on.
+	opts := gitcode.Opts{
+		URL:                      strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_URL")),
+		APIURL:                   strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_API_URL")),
+		SkipVerify:               os.Getenv("WOODPECKER_GITCODE_SKIP_VERIFY") == "true",
+		OAuthClientID:            strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_CLIENT")),
+		OAuthClientSecret:        strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_SECRET")),
+		OAuthRedirectHost:        strings.TrimSpace(os.Getenv("WOODPECKER_HOST")),
+		DomainAliases:            splitList(os.Getenv("WOODPECKER_GITCODE_DOMAIN_ALIASES")),
+		FailureIssueThreshold:    intEnv("WOODPECKER_GITCODE_FAILURE_ISSUE_THRESHOLD", 0),
+		Proxies:                  splitList(os.Getenv("WOODPECKER_GITCODE_PROXIES")),
+		ActivationCheck:          os.Getenv("WOODPECKER_GITCODE_ACTIVATION_CHECK") == "true",
+		MaxChangedFiles:          intEnv("WOODPECKER_GITCODE_MAX_CHANGED_FILES", 1000),
+		ForkConfigFromTarget:     os.Getenv("WOODPECKER_GITCODE_FORK_CONFIG_FROM_TARGET") == "true",
+		RepoAffiliation:          splitList(os.Getenv("WOODPECKER_GITCODE_REPO_AFFILIATION")),
+		LogSampleRate:            intEnv("WOODPECKER_GITCODE_LOG_SAMPLE_RATE", 0),
+		PageSize:                 intEnv("WOODPECKER_GITCODE_PAGE_SIZE", 50),
+		ConfigCacheSize:          intEnv("WOODPECKER_GITCODE_CONFIG_CACHE_SIZE", 1000),
+		ResponseCacheSize:        intEnv("WOODPECKER_GITCODE_RESPONSE_CACHE_SIZE", 1000),
+		AuthMode:                 strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_AUTH_MODE")),
+		GitUsername:              strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_GIT_USERNAME")),
+		GitToken:                 strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_GIT_TOKEN")),
+		RepoCredentials:          splitList(os.Getenv("WOODPECKER_GITCODE_REPO_CREDENTIALS")),
+		RefreshMargin:            durationEnv("WOODPECKER_GITCODE_REFRESH_MARGIN", 30*time.Minute),
+		HookEvents:               splitList(os.Getenv("WOODPECKER_GITCODE_HOOK_EVENTS")),
+		ReleaseActions:           splitList(os.Getenv("WOODPECKER_GITCODE_RELEASE_ACTIONS")),
+		SystemHookSecret:         strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_SYSTEM_HOOK_SECRET")),
+		BranchHeadMachineAccount: os.Getenv("WOODPECKER_GITCODE_BRANCH_HEAD_MACHINE_ACCOUNT") == "true",
+		StatusContext:            strings.TrimSpace(os.Getenv("WOODPECKER_GITCODE_STATUS_CONTEXT")),
+		MaxRetries:               intEnv("WOODPECKER_GITCODE_MAX_RETRIES", 2),
+		RetryBackoff:             durationEnv("WOODPECKER_GITCODE_RETRY_BACKOFF", 500*time.Millisecond),
+		Timeouts:                 splitList(os.Getenv("WOODPECKER_GITCODE_TIMEOUTS")),
+		DebugTrace:               os.Getenv("WOODPECKER_GITCODE_DEBUG_TRACE") == "true",
+		MaxResponseSize:          intEnv("WOODPECKER_GITCODE_MAX_RESPONSE_SIZE", 10<<20),
+	}
+
+	forge, err := gitcode.New(opts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not set up gitcode forge")
+	}
+	addon.Serve(forge)
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func intEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/gitcode"
+)
+
+// wiredOpts returns the fields set by the gitcode.Opts literals in a file.
+func wiredOpts(t *testing.T, file string) map[string]bool {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	require.NoError(t, err)
+
+	fields := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		sel, ok := lit.Type.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Opts" {
+			return true
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "gitcode" {
+			return true
+		}
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				fields[kv.Key.(*ast.Ident).Name] = true
+			}
+		}
+		return true
+	})
+	require.NotEmpty(t, fields, "no gitcode.Opts literal in %s", file)
+	return fields
+}
+
+// TestOptsInSyncWithServer makes sure the addon reads every option the
+// server wires in setupGitCode, so the two don't drift apart again.
+func TestOptsInSyncWithServer(t *testing.T) {
+	// the server derives the OAuth callback host from its own config
+	addonOnly := map[string]bool{"OAuthRedirectHost": true}
+
+	addon := wiredOpts(t, "main.go")
+	server := wiredOpts(t, "../../server/forge/setup/setup.go")
+
+	optsType := reflect.TypeOf(gitcode.Opts{})
+	for i := range optsType.NumField() {
+		name := optsType.Field(i).Name
+		assert.True(t, addon[name], "addon does not set gitcode.Opts.%s", name)
+		if !addonOnly[name] {
+			assert.True(t, server[name], "setupGitCode does not set gitcode.Opts.%s", name)
+		}
+	}
+}
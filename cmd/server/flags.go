@@ -390,7 +390,7 @@ var flags = append([]cli.Flag{
 	&cli.StringFlag{
 		Name:    "forge-url",
 		Usage:   "url of the forge",
-		Sources: cli.EnvVars("WOODPECKER_FORGE_URL", "WOODPECKER_GITHUB_URL", "WOODPECKER_GITLAB_URL", "WOODPECKER_GITEA_URL", "WOODPECKER_FORGEJO_URL", "WOODPECKER_BITBUCKET_URL", "WOODPECKER_BITBUCKET_DC_URL"),
+		Sources: cli.EnvVars("WOODPECKER_FORGE_URL", "WOODPECKER_GITHUB_URL", "WOODPECKER_GITLAB_URL", "WOODPECKER_GITEA_URL", "WOODPECKER_FORGEJO_URL", "WOODPECKER_BITBUCKET_URL", "WOODPECKER_BITBUCKET_DC_URL", "WOODPECKER_GITCODE_URL"),
 	},
 	&cli.StringFlag{
 		Sources: cli.NewValueSourceChain(
@@ -452,7 +452,8 @@ var flags = append([]cli.Flag{
 			"WOODPECKER_GITLAB_SKIP_VERIFY",
 			"WOODPECKER_GITEA_SKIP_VERIFY",
 			"WOODPECKER_FORGEJO_SKIP_VERIFY",
-			"WOODPECKER_BITBUCKET_SKIP_VERIFY"),
+			"WOODPECKER_BITBUCKET_SKIP_VERIFY",
+			"WOODPECKER_GITCODE_SKIP_VERIFY"),
 	},
 	&cli.StringFlag{
 		Sources: cli.EnvVars("WOODPECKER_EXPERT_FORGE_OAUTH_HOST"),
@@ -511,6 +512,169 @@ var flags = append([]cli.Flag{
 		Name:    "gitcode",
 		Usage:   "gitcode driver is enabled",
 	},
+	&cli.StringFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_API_URL"),
+		Name:    "gitcode-api-url",
+		Usage:   "gitcode api v5 url, defaults to <forge url>/api/v5 for self-hosted instances",
+	},
+	&cli.StringSliceFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_DOMAIN_ALIASES"),
+		Name:    "gitcode-domain-aliases",
+		Usage:   "additional domains serving the same gitcode instance (gitcode.net is always an alias of gitcode.com)",
+	},
+	&cli.IntFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_FAILURE_ISSUE_THRESHOLD"),
+		Name:    "gitcode-failure-issue-threshold",
+		Usage:   "open an issue after this many consecutive failures of a cron or default branch pipeline (0 to disable)",
+	},
+	&cli.StringSliceFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_PROXIES"),
+		Name:    "gitcode-proxies",
+		Usage:   "egress proxy per operation class (default, hook, sync, archive), e.g. sync=http://proxy:3128 or hook=direct",
+	},
+	&cli.BoolFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_ACTIVATION_CHECK"),
+		Name:    "gitcode-activation-check",
+		Usage:   "verify that the webhook url is reachable before a repository is activated",
+	},
+	&cli.IntFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_MAX_CHANGED_FILES"),
+		Name:    "gitcode-max-changed-files",
+		Usage:   "maximum number of changed files stored per pipeline, longer lists are cut and end with \"...\" (0 to disable)",
+		Value:   1000,
+	},
+	&cli.BoolFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_FORK_CONFIG_FROM_TARGET"),
+		Name:    "gitcode-fork-config-from-target",
+		Usage:   "read the pipeline config of pull requests from forks from the target branch instead of the pull request",
+	},
+	&cli.StringSliceFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_REPO_AFFILIATION"),
+		Name:    "gitcode-repo-affiliation",
+		Usage:   "only list repositories the user is owner, collaborator and/or organization_member of (default all)",
+	},
+	&cli.IntFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_LOG_SAMPLE_RATE"),
+		Name:    "gitcode-log-sample-rate",
+		Usage:   "only log every n-th successful read request to the GitCode API at trace level (0 or 1 to log all)",
+	},
+	&cli.IntFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_PAGE_SIZE"),
+		Name:    "gitcode-page-size",
+		Usage:   "number of items requested per page from the GitCode API (at most 100)",
+		Value:   50,
+	},
+	&cli.IntFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_CONFIG_CACHE_SIZE"),
+		Name:    "gitcode-config-cache-size",
+		Usage:   "number of pipeline config files cached in memory per commit (0 to disable)",
+		Value:   1000,
+	},
+	&cli.IntFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_RESPONSE_CACHE_SIZE"),
+		Name:    "gitcode-response-cache-size",
+		Usage:   "number of GitCode API responses cached in memory for conditional requests (0 to disable)",
+		Value:   1000,
+	},
+	&cli.StringFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_AUTH_MODE"),
+		Name:    "gitcode-auth-mode",
+		Usage:   "how the access token is sent to the GitCode API: bearer, private-token or query (for older instances only, leaks the token into logs)",
+		Value:   "bearer",
+	},
+	&cli.StringFlag{
+		Sources: cli.NewValueSourceChain(
+			cli.File(os.Getenv("WOODPECKER_GITCODE_GIT_USERNAME_FILE")),
+			cli.EnvVar("WOODPECKER_GITCODE_GIT_USERNAME")),
+		Name:  "gitcode-git-username",
+		Usage: "GitCode machine account used to clone repos instead of the activating user",
+		Config: cli.StringConfig{
+			TrimSpace: true,
+		},
+	},
+	&cli.StringFlag{
+		Sources: cli.NewValueSourceChain(
+			cli.File(os.Getenv("WOODPECKER_GITCODE_GIT_TOKEN_FILE")),
+			cli.EnvVar("WOODPECKER_GITCODE_GIT_TOKEN")),
+		Name:  "gitcode-git-token",
+		Usage: "GitCode machine account access token",
+		Config: cli.StringConfig{
+			TrimSpace: true,
+		},
+	},
+	&cli.StringSliceFlag{
+		Sources: cli.NewValueSourceChain(
+			cli.File(os.Getenv("WOODPECKER_GITCODE_REPO_CREDENTIALS_FILE")),
+			cli.EnvVar("WOODPECKER_GITCODE_REPO_CREDENTIALS")),
+		Name:  "gitcode-repo-credentials",
+		Usage: "GitCode machine account per repo used to clone, e.g. owner/repo=login:token or owner/*=login:token",
+	},
+	&cli.DurationFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_REFRESH_MARGIN"),
+		Name:    "gitcode-refresh-margin",
+		Usage:   "refresh GitCode OAuth tokens this long before they expire",
+		Value:   30 * time.Minute,
+	},
+	&cli.StringSliceFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_HOOK_EVENTS"),
+		Name:    "gitcode-hook-events",
+		Usage:   "events registered for GitCode webhooks (push, tag_push, pull_request, release, note, issues, deployment)",
+		Value:   []string{"push", "tag_push", "pull_request", "release"},
+	},
+	&cli.StringSliceFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_RELEASE_ACTIONS"),
+		Name:    "gitcode-release-actions",
+		Usage:   "GitCode release hook actions that start a pipeline (published, prereleased, edited)",
+		Value:   []string{"published"},
+	},
+	&cli.BoolFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_BRANCH_HEAD_MACHINE_ACCOUNT"),
+		Name:    "gitcode-branch-head-machine-account",
+		Usage:   "look up branch heads, e.g. for cron pipelines, with the GitCode machine account instead of the repo owner's token",
+	},
+	&cli.StringFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_STATUS_CONTEXT"),
+		Name:    "gitcode-status-context",
+		Usage:   "template of GitCode commit status contexts with %context, %event, %workflow, %owner, %repo and %axis, empty uses the status context format",
+	},
+	&cli.IntFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_MAX_RETRIES"),
+		Name:    "gitcode-max-retries",
+		Usage:   "how often failed GitCode GET and DELETE requests are retried (-1 to disable)",
+		Value:   2,
+	},
+	&cli.DurationFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_RETRY_BACKOFF"),
+		Name:    "gitcode-retry-backoff",
+		Usage:   "wait before the first retry of a GitCode request, doubled for every further retry",
+		Value:   500 * time.Millisecond,
+	},
+	&cli.StringSliceFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_TIMEOUTS"),
+		Name:    "gitcode-timeouts",
+		Usage:   "time budget per operation class (default, hook, sync, archive), e.g. archive=10m or hook=5s",
+	},
+	&cli.BoolFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_DEBUG_TRACE"),
+		Name:    "gitcode-debug-trace",
+		Usage:   "log every GitCode API request with its status, duration and truncated, redacted bodies",
+	},
+	&cli.IntFlag{
+		Sources: cli.EnvVars("WOODPECKER_GITCODE_MAX_RESPONSE_SIZE"),
+		Name:    "gitcode-max-response-size",
+		Usage:   "largest GitCode API response or file in bytes that is read (-1 to disable)",
+		Value:   10 << 20,
+	},
+	&cli.StringFlag{
+		Sources: cli.NewValueSourceChain(
+			cli.File(os.Getenv("WOODPECKER_GITCODE_SYSTEM_HOOK_SECRET_FILE")),
+			cli.EnvVar("WOODPECKER_GITCODE_SYSTEM_HOOK_SECRET")),
+		Name:  "gitcode-system-hook-secret",
+		Usage: "secret of a GitCode system hook sent to /api/hook?forge_id=<id>, empty disables system hooks",
+		Config: cli.StringConfig{
+			TrimSpace: true,
+		},
+	},
 	//
 	// Bitbucket
 	//
@@ -2376,6 +2376,12 @@ const docTemplate = `{
                         "description": "for response pagination, max items per page",
                         "name": "perPage",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "only return branches whose name contains this value, ignoring case",
+                        "name": "search",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -3395,6 +3401,59 @@ const docTemplate = `{
                 }
             }
         },
+        "/repos/{repo_id}/pipelines/{number}/release-assets": {
+            "post": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "tags": [
+                    "Pipelines"
+                ],
+                "summary": "Attach a file to the release that triggered a pipeline",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cpersonal access token\u003e",
+                        "description": "Insert your personal access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the repository id",
+                        "name": "repo_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "the number of the pipeline",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "the asset to upload",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "the asset name, defaults to the file name",
+                        "name": "name",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
         "/repos/{repo_id}/pull_requests": {
             "get": {
                 "produces": [
@@ -4958,6 +5017,12 @@ const docTemplate = `{
                 "refspec": {
                     "type": "string"
                 },
+                "release_assets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ReleaseAsset"
+                    }
+                },
                 "reviewed": {
                     "type": "integer"
                 },
@@ -5091,6 +5156,20 @@ const docTemplate = `{
                 }
             }
         },
+        "ReleaseAsset": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
         "Repo": {
             "type": "object",
             "properties": {
@@ -5124,6 +5203,9 @@ const docTemplate = `{
                 "clone_url_ssh": {
                     "type": "string"
                 },
+                "comment_on_failure": {
+                    "type": "boolean"
+                },
                 "config_extension_endpoint": {
                     "type": "string"
                 },
@@ -5143,6 +5225,9 @@ const docTemplate = `{
                 "forge_url": {
                     "type": "string"
                 },
+                "fork": {
+                    "type": "boolean"
+                },
                 "full_name": {
                     "type": "string"
                 },
@@ -5173,6 +5258,9 @@ const docTemplate = `{
                 "require_approval": {
                     "$ref": "#/definitions/model.ApprovalMode"
                 },
+                "skip_draft_pull_requests": {
+                    "type": "boolean"
+                },
                 "timeout": {
                     "type": "integer"
                 },
@@ -5217,6 +5305,9 @@ const docTemplate = `{
                 "clone_url_ssh": {
                     "type": "string"
                 },
+                "comment_on_failure": {
+                    "type": "boolean"
+                },
                 "config_extension_endpoint": {
                     "type": "string"
                 },
@@ -5236,6 +5327,9 @@ const docTemplate = `{
                 "forge_url": {
                     "type": "string"
                 },
+                "fork": {
+                    "type": "boolean"
+                },
                 "full_name": {
                     "type": "string"
                 },
@@ -5269,6 +5363,9 @@ const docTemplate = `{
                 "require_approval": {
                     "$ref": "#/definitions/model.ApprovalMode"
                 },
+                "skip_draft_pull_requests": {
+                    "type": "boolean"
+                },
                 "timeout": {
                     "type": "integer"
                 },
@@ -5301,6 +5398,9 @@ const docTemplate = `{
                         "$ref": "#/definitions/WebhookEvent"
                     }
                 },
+                "comment_on_failure": {
+                    "type": "boolean"
+                },
                 "config_extension_endpoint": {
                     "type": "string"
                 },
@@ -5316,6 +5416,9 @@ const docTemplate = `{
                 "require_approval": {
                     "type": "string"
                 },
+                "skip_draft_pull_requests": {
+                    "type": "boolean"
+                },
                 "timeout": {
                     "type": "integer"
                 },
@@ -5364,6 +5467,9 @@ const docTemplate = `{
                 "org_id": {
                     "type": "integer"
                 },
+                "protected": {
+                    "type": "boolean"
+                },
                 "repo_id": {
                     "type": "integer"
                 },
@@ -5539,6 +5645,10 @@ const docTemplate = `{
                     "description": "the avatar url for this user.",
                     "type": "string"
                 },
+                "display_name": {
+                    "description": "DisplayName is the full name or nickname shown by the forge, if any.",
+                    "type": "string"
+                },
                 "email": {
                     "description": "Email is the email address for this user.\n\nrequired: true",
                     "type": "string"
@@ -5635,6 +5745,12 @@ const docTemplate = `{
                 "refspec": {
                     "type": "string"
                 },
+                "release_assets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/metadata.ReleaseAsset"
+                    }
+                },
                 "sha": {
                     "type": "string"
                 }
@@ -5733,6 +5849,20 @@ const docTemplate = `{
                 }
             }
         },
+        "metadata.ReleaseAsset": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
         "metadata.Repo": {
             "type": "object",
             "properties": {
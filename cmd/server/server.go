@@ -37,6 +37,7 @@ import (
 	"go.woodpecker-ci.org/woodpecker/v3/server/cron"
 	"go.woodpecker-ci.org/woodpecker/v3/server/router"
 	"go.woodpecker-ci.org/woodpecker/v3/server/router/middleware"
+	"go.woodpecker-ci.org/woodpecker/v3/server/statusqueue"
 	"go.woodpecker-ci.org/woodpecker/v3/server/store"
 	"go.woodpecker-ci.org/woodpecker/v3/server/web"
 	"go.woodpecker-ci.org/woodpecker/v3/shared/logger"
@@ -134,6 +135,16 @@ func run(ctx context.Context, c *cli.Command) error {
 		return nil
 	})
 
+	serviceWaitingGroup.Go(func() error {
+		log.Info().Msg("starting status retry service ...")
+		if err := statusqueue.Run(ctx, _store); err != nil {
+			go stopServerFunc(err)
+			return err
+		}
+		log.Info().Msg("status retry service stopped")
+		return nil
+	})
+
 	// start the grpc server
 	serviceWaitingGroup.Go(func() error {
 		log.Info().Msg("starting grpc server ...")
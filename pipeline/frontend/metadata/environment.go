@@ -102,6 +102,14 @@ func (m *Metadata) Environ() map[string]string {
 	}
 	if pipeline.Event == EventRelease {
 		setNonEmptyEnvVar(params, "CI_COMMIT_PRERELEASE", strconv.FormatBool(pipeline.Commit.IsPrerelease))
+		setNonEmptyEnvVar(params, "CI_COMMIT_RELEASE_UPLOAD_URL", m.getReleaseAssetsURL(pipeline))
+		if len(pipeline.Commit.ReleaseAssets) != 0 {
+			releaseAssets, err := json.Marshal(pipeline.Commit.ReleaseAssets)
+			if err != nil {
+				log.Error().Err(err).Msg("marshal release assets")
+			}
+			params["CI_COMMIT_RELEASE_ASSETS"] = string(releaseAssets)
+		}
 	}
 	if EventIsPull(pipeline.Event) {
 		sourceBranch, targetBranch := getSourceTargetBranches(commit.Refspec)
@@ -168,6 +176,11 @@ func (m *Metadata) getPipelineWebURL(pipeline Pipeline, stepNumber int) string {
 	return fmt.Sprintf("%s/repos/%d/pipeline/%d/%d", m.Sys.URL, m.Repo.ID, pipeline.Number, stepNumber)
 }
 
+// getReleaseAssetsURL returns the API endpoint release pipelines can upload release assets to.
+func (m *Metadata) getReleaseAssetsURL(pipeline Pipeline) string {
+	return fmt.Sprintf("%s/api/repos/%d/pipelines/%d/release-assets", m.Sys.URL, m.Repo.ID, pipeline.Number)
+}
+
 func getSourceTargetBranches(refspec string) (string, string) {
 	var (
 		sourceBranch string
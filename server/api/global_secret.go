@@ -85,10 +85,11 @@ func PostGlobalSecret(c *gin.Context) {
 		return
 	}
 	secret := &model.Secret{
-		Name:   in.Name,
-		Value:  in.Value,
-		Events: in.Events,
-		Images: in.Images,
+		Name:      in.Name,
+		Value:     in.Value,
+		Events:    in.Events,
+		Images:    in.Images,
+		Protected: in.Protected,
 	}
 	if err := secret.Validate(); err != nil {
 		c.String(http.StatusBadRequest, "Error inserting global secret. %s", err)
@@ -116,7 +117,7 @@ func PostGlobalSecret(c *gin.Context) {
 func PatchGlobalSecret(c *gin.Context) {
 	name := c.Param("secret")
 
-	in := new(model.Secret)
+	in := new(secretPatch)
 	err := c.Bind(in)
 	if err != nil {
 		c.String(http.StatusBadRequest, "Error parsing secret. %s", err)
@@ -138,6 +139,9 @@ func PatchGlobalSecret(c *gin.Context) {
 	if in.Images != nil {
 		secret.Images = in.Images
 	}
+	if in.Protected != nil {
+		secret.Protected = *in.Protected
+	}
 
 	if err := secret.Validate(); err != nil {
 		c.String(http.StatusBadRequest, "Error updating global secret. %s", err)
@@ -23,6 +23,7 @@ import (
 
 	"go.woodpecker-ci.org/woodpecker/v3/server"
 	"go.woodpecker-ci.org/woodpecker/v3/server/forge"
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
 	"go.woodpecker-ci.org/woodpecker/v3/server/model"
 	"go.woodpecker-ci.org/woodpecker/v3/server/pipeline"
 	"go.woodpecker-ci.org/woodpecker/v3/server/store"
@@ -52,6 +53,19 @@ func handleDBError(c *gin.Context, err error) {
 	_ = c.AbortWithError(http.StatusInternalServerError, err)
 }
 
+// handleForgeRepoErr maps errors of forge.Repo to responses telling a missing
+// repository apart from a missing permission.
+func handleForgeRepoErr(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, forge_types.ErrRepoNotFound):
+		c.String(http.StatusNotFound, "Repository not found on the forge.")
+	case errors.Is(err, forge_types.ErrRepoNoPermission):
+		c.String(http.StatusForbidden, "No permission to access the repository on the forge, ask an admin to grant access.")
+	default:
+		c.String(http.StatusInternalServerError, "Could not fetch repository from forge.")
+	}
+}
+
 // If the forge has a refresh token, the current access token may be stale.
 // Therefore, we should refresh prior to dispatching the job.
 func refreshUserToken(c *gin.Context, user *model.User) {
@@ -75,3 +89,10 @@ func pipelineDeleteAllowed(pl *model.Pipeline) bool {
 
 	return true
 }
+
+// secretPatch is the body of a secret update. Protected is a pointer, so
+// clients that omit it keep the current value instead of resetting it.
+type secretPatch struct {
+	model.Secret
+	Protected *bool `json:"protected"`
+}
@@ -31,6 +31,7 @@ import (
 	"go.woodpecker-ci.org/woodpecker/v3/server/model"
 	"go.woodpecker-ci.org/woodpecker/v3/server/pipeline"
 	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_types "go.woodpecker-ci.org/woodpecker/v3/server/store/types"
 	"go.woodpecker-ci.org/woodpecker/v3/shared/token"
 )
 
@@ -208,20 +209,38 @@ func PostHook(c *gin.Context) {
 
 	var repo *model.Repo
 
-	_, err := token.ParseRequest([]token.Type{token.HookToken}, c.Request, func(t *token.Token) (string, error) {
+	if forgeID := c.Query("forge_id"); forgeID != "" && c.Query("access_token") == "" {
+		// system hooks of a forge instance carry no repo token, the forge finds the repo
 		var err error
-		repo, err = getRepoFromToken(_store, t)
+		repo, err = getRepoFromSystemHook(c, forgeID)
+		if errors.Is(err, store_types.RecordNotExist) {
+			msg := "ignoring system hook: repo is not activated"
+			log.Debug().Msg(msg)
+			c.String(http.StatusOK, msg)
+			return
+		}
 		if err != nil {
-			return "", err
+			msg := "failure to verify system hook"
+			log.Error().Err(err).Msg(msg)
+			c.String(http.StatusBadRequest, msg)
+			return
 		}
+	} else {
+		_, err := token.ParseRequest([]token.Type{token.HookToken}, c.Request, func(t *token.Token) (string, error) {
+			var err error
+			repo, err = getRepoFromToken(_store, t)
+			if err != nil {
+				return "", err
+			}
 
-		return repo.Hash, nil
-	})
-	if err != nil {
-		msg := "failure to parse token from hook"
-		log.Error().Err(err).Msg(msg)
-		c.String(http.StatusBadRequest, msg)
-		return
+			return repo.Hash, nil
+		})
+		if err != nil {
+			msg := "failure to parse token from hook"
+			log.Error().Err(err).Msg(msg)
+			c.String(http.StatusBadRequest, msg)
+			return
+		}
 	}
 
 	if repo == nil {
@@ -263,6 +282,13 @@ func PostHook(c *gin.Context) {
 		c.String(http.StatusOK, msg)
 		return
 	}
+
+	// forges dropping redelivered webhooks only forget a delivery once no pipeline was created for it
+	created := false
+	if tracker, ok := _forge.(forge.HookDeliveryTracker); ok {
+		defer func() { tracker.HookHandled(c, pipelineFromForge, created) }()
+	}
+
 	if repoFromForge == nil {
 		msg := "failure to ascertain repo from hook"
 		log.Debug().Msg(msg)
@@ -341,10 +367,36 @@ func PostHook(c *gin.Context) {
 	if err != nil {
 		handlePipelineErr(c, err)
 	} else {
+		created = true
 		c.JSON(http.StatusOK, pl)
 	}
 }
 
+// getRepoFromSystemHook lets the forge with the given id find the repo of a system hook delivery.
+func getRepoFromSystemHook(c *gin.Context, rawForgeID string) (*model.Repo, error) {
+	forgeID, err := strconv.ParseInt(rawForgeID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forge id %q", rawForgeID)
+	}
+	_forge, err := server.Config.Services.Manager.ForgeByID(forgeID)
+	if err != nil {
+		return nil, err
+	}
+	receiver, ok := _forge.(forge.SystemHookReceiver)
+	if !ok {
+		return nil, fmt.Errorf("forge %d does not support system hooks", forgeID)
+	}
+
+	repo, err := receiver.SystemHookRepo(c, c.Request)
+	if err != nil {
+		return nil, err
+	}
+	if repo.ForgeID != forgeID {
+		return nil, fmt.Errorf("repo %s does not belong to forge %d", repo.FullName, forgeID)
+	}
+	return repo, nil
+}
+
 func getRepoFromToken(store store.Store, t *token.Token) (*model.Repo, error) {
 	if t.Get("repo-forge-remote-id") != "" {
 		// TODO: use both the forge ID and repo forge remote ID
@@ -1,6 +1,7 @@
 package api_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -21,6 +22,7 @@ import (
 	registry_service_mocks "go.woodpecker-ci.org/woodpecker/v3/server/services/registry/mocks"
 	secret_service_mocks "go.woodpecker-ci.org/woodpecker/v3/server/services/secret/mocks"
 	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+	store_types "go.woodpecker-ci.org/woodpecker/v3/server/store/types"
 	"go.woodpecker-ci.org/woodpecker/v3/shared/token"
 )
 
@@ -94,3 +96,87 @@ func TestHook(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, c.Writer.Status())
 	assert.Equal(t, "true", w.Header().Get("Pipeline-Filtered"))
 }
+
+// deliveryTrackingForge is a forge that records what the server reported about its pipelines.
+type deliveryTrackingForge struct {
+	*forge_mocks.MockForge
+	handled []bool
+}
+
+func (f *deliveryTrackingForge) HookHandled(_ context.Context, _ *model.Pipeline, created bool) {
+	f.handled = append(f.handled, created)
+}
+
+func TestHookReportsFailedPipelineCreation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	_manager := services_mocks.NewMockManager(t)
+	_forge := &deliveryTrackingForge{MockForge: forge_mocks.NewMockForge(t)}
+	_store := store_mocks.NewMockStore(t)
+	server.Config.Services.Manager = _manager
+
+	user := &model.User{ID: 123}
+	repo := &model.Repo{ID: 123, ForgeRemoteID: "123", FullName: "owner/name", IsActive: true, UserID: user.ID, Hash: "secret-123-this-is-a-secret"}
+	repoToken := token.New(token.HookToken)
+	repoToken.Set("repo-id", fmt.Sprintf("%d", repo.ID))
+	signedToken, err := repoToken.Sign(repo.Hash)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set("store", _store)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/hook", nil)
+	c.Request.Header.Set("Authorization", "Bearer "+signedToken)
+
+	_manager.On("ForgeFromRepo", repo).Return(_forge, nil)
+	_forge.On("Hook", mock.Anything, mock.Anything).Return(repo, &model.Pipeline{Event: model.EventPush}, nil)
+	_store.On("GetRepo", repo.ID).Return(repo, nil)
+	_store.On("GetUser", user.ID).Return(user, nil)
+	_store.On("UpdateRepo", repo).Return(fmt.Errorf("database is locked"))
+
+	api.PostHook(c)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, []bool{false}, _forge.handled)
+}
+
+// systemHookForge is a forge that accepts system hooks.
+type systemHookForge struct {
+	*forge_mocks.MockForge
+	repo *model.Repo
+	err  error
+}
+
+func (f *systemHookForge) SystemHookRepo(context.Context, *http.Request) (*model.Repo, error) {
+	return f.repo, f.err
+}
+
+func TestSystemHook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	post := func(forgeID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("store", store_mocks.NewMockStore(t))
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/hook?forge_id="+forgeID, nil)
+		api.PostHook(c)
+		return w
+	}
+
+	_manager := services_mocks.NewMockManager(t)
+	server.Config.Services.Manager = _manager
+
+	// forges without system hook support reject them
+	_manager.On("ForgeByID", int64(1)).Return(forge_mocks.NewMockForge(t), nil).Once()
+	assert.Equal(t, http.StatusBadRequest, post("1").Code)
+
+	// deliveries for repos that are not activated are ignored
+	_manager.On("ForgeByID", int64(1)).Return(&systemHookForge{MockForge: forge_mocks.NewMockForge(t), err: store_types.RecordNotExist}, nil).Once()
+	assert.Equal(t, http.StatusOK, post("1").Code)
+
+	// repos of other forges are rejected
+	_manager.On("ForgeByID", int64(1)).Return(&systemHookForge{MockForge: forge_mocks.NewMockForge(t), repo: &model.Repo{ForgeID: 2}}, nil).Once()
+	assert.Equal(t, http.StatusBadRequest, post("1").Code)
+
+	assert.Equal(t, http.StatusBadRequest, post("main").Code)
+}
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -63,6 +64,8 @@ func HandleAuth(c *gin.Context) {
 	state := c.Request.FormValue("state")
 	isCallback := code != "" && state != ""
 	var forgeID int64
+	// page to return to after login, carried through the forge in the signed state token
+	redirectPath := server.Config.Server.RootPath + "/"
 
 	if isCallback { // validate the state token
 		stateToken, err := token.Parse([]token.Type{token.OAuthStateToken}, state, func(_ *token.Token) (string, error) {
@@ -81,6 +84,10 @@ func HandleAuth(c *gin.Context) {
 			c.Redirect(http.StatusSeeOther, server.Config.Server.RootPath+"/login?error=invalid_state")
 			return
 		}
+
+		if redirect := stateToken.Get("redirect"); isLocalRedirect(redirect) {
+			redirectPath = redirect
+		}
 	} else { // only generate a state token if not a callback
 		var err error
 
@@ -100,6 +107,9 @@ func HandleAuth(c *gin.Context) {
 		exp := time.Now().Add(stateTokenDuration).Unix()
 		stateToken := token.New(token.OAuthStateToken)
 		stateToken.Set("forge-id", strconv.FormatInt(forgeID, 10))
+		if redirect := c.Request.FormValue("url"); isLocalRedirect(redirect) {
+			stateToken.Set("redirect", redirect)
+		}
 		state, err = stateToken.SignExpires(jwtSecret, exp)
 		if err != nil {
 			log.Error().Err(err).Msg("cannot create state token")
@@ -167,6 +177,7 @@ func HandleAuth(c *gin.Context) {
 			Expiry:        userFromForge.Expiry,
 			Email:         userFromForge.Email,
 			Avatar:        userFromForge.Avatar,
+			DisplayName:   userFromForge.DisplayName,
 			Hash: base32.StdEncoding.EncodeToString(
 				securecookie.GenerateRandomKey(32),
 			),
@@ -234,6 +245,7 @@ func HandleAuth(c *gin.Context) {
 	user.RefreshToken = userFromForge.RefreshToken
 	user.Email = userFromForge.Email
 	user.Avatar = userFromForge.Avatar
+	user.DisplayName = userFromForge.DisplayName
 	user.ForgeID = forgeID
 	user.ForgeRemoteID = userFromForge.ForgeRemoteID
 	user.Login = userFromForge.Login
@@ -264,7 +276,17 @@ func HandleAuth(c *gin.Context) {
 
 	httputil.SetCookie(c.Writer, c.Request, "user_sess", tokenString)
 
-	c.Redirect(http.StatusSeeOther, server.Config.Server.RootPath+"/")
+	c.Redirect(http.StatusSeeOther, redirectPath)
+}
+
+// isLocalRedirect reports whether target is a path on this server, so the
+// login can't be abused as an open redirect to another site.
+func isLocalRedirect(target string) bool {
+	if !strings.HasPrefix(target, server.Config.Server.RootPath+"/") || strings.HasPrefix(target, "//") || strings.Contains(target, "\\") {
+		return false
+	}
+	u, err := url.Parse(target)
+	return err == nil && u.Scheme == "" && u.Host == ""
 }
 
 func updateRepoPermissions(c *gin.Context, user *model.User, _store store.Store, _forge forge.Forge) error {
@@ -204,6 +204,56 @@ func TestHandleAuth(t *testing.T) {
 		assert.NotEmpty(t, c.Writer.Header().Get("Set-Cookie"))
 	})
 
+	t.Run("should return to the page the login started from", func(t *testing.T) {
+		_manager := services_mocks.NewMockManager(t)
+		_forge := forge_mocks.NewMockForge(t)
+		_store := store_mocks.NewMockStore(t)
+		server.Config.Services.Manager = _manager
+		server.Config.Server.JWTSecret = "jwt-secret"
+		server.Config.Permissions.Open = true
+		server.Config.Permissions.Orgs = permissions.NewOrgs(nil)
+		server.Config.Permissions.Admins = permissions.NewAdmins(nil)
+
+		for target, expected := range map[string]string{
+			"/repos/1/pipeline/2":   "/repos/1/pipeline/2",
+			"https://evil.example/": "/",
+			"//evil.example/":       "/",
+		} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("store", _store)
+
+			stateToken := token.New(token.OAuthStateToken)
+			stateToken.Set("forge-id", "1")
+			stateToken.Set("redirect", target)
+			state, err := stateToken.SignExpires(server.Config.Server.JWTSecret, time.Now().Add(time.Minute).Unix())
+			assert.NoError(t, err)
+
+			query := url.Values{}
+			query.Set("code", "assumed_to_be_valid_code")
+			query.Set("state", state)
+			c.Request = &http.Request{
+				Header: make(http.Header),
+				URL: &url.URL{
+					Scheme:   "https",
+					RawQuery: query.Encode(),
+				},
+			}
+
+			_manager.On("ForgeByID", int64(1)).Return(_forge, nil)
+			_forge.On("Login", mock.Anything, mock.Anything).Return(user, "", nil)
+			_store.On("GetUserRemoteID", user.ForgeRemoteID, user.Login).Return(user, nil)
+			_store.On("OrgGet", org.ID).Return(org, nil)
+			_store.On("UpdateUser", mock.Anything).Return(nil)
+			_forge.On("Repos", mock.Anything, mock.Anything).Return(nil, nil)
+
+			api.HandleAuth(c)
+
+			assert.Equal(t, http.StatusSeeOther, c.Writer.Status())
+			assert.Equal(t, expected, c.Writer.Header().Get("Location"), target)
+		}
+	})
+
 	t.Run("should deny a new user if registration is closed", func(t *testing.T) {
 		_manager := services_mocks.NewMockManager(t)
 		_forge := forge_mocks.NewMockForge(t)
@@ -94,11 +94,12 @@ func PostOrgSecret(c *gin.Context) {
 		return
 	}
 	secret := &model.Secret{
-		OrgID:  org.ID,
-		Name:   in.Name,
-		Value:  in.Value,
-		Events: in.Events,
-		Images: in.Images,
+		OrgID:     org.ID,
+		Name:      in.Name,
+		Value:     in.Value,
+		Events:    in.Events,
+		Images:    in.Images,
+		Protected: in.Protected,
 	}
 	if err := secret.Validate(); err != nil {
 		c.String(http.StatusUnprocessableEntity, "Error inserting org %q secret. %s", org.ID, err)
@@ -128,7 +129,7 @@ func PatchOrgSecret(c *gin.Context) {
 	org := session.Org(c)
 	name := c.Param("secret")
 
-	in := new(model.Secret)
+	in := new(secretPatch)
 	if err := c.Bind(in); err != nil {
 		c.String(http.StatusBadRequest, "Error parsing secret. %s", err)
 		return
@@ -149,6 +150,9 @@ func PatchOrgSecret(c *gin.Context) {
 	if in.Images != nil {
 		secret.Images = in.Images
 	}
+	if in.Protected != nil {
+		secret.Protected = *in.Protected
+	}
 
 	if err := secret.Validate(); err != nil {
 		c.String(http.StatusUnprocessableEntity, "Error updating org %q secret. %s", org.ID, err)
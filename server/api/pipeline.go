@@ -29,6 +29,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"go.woodpecker-ci.org/woodpecker/v3/server"
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge"
 	"go.woodpecker-ci.org/woodpecker/v3/server/model"
 	"go.woodpecker-ci.org/woodpecker/v3/server/pipeline"
 	"go.woodpecker-ci.org/woodpecker/v3/server/pipeline/stepbuilder"
@@ -84,7 +85,7 @@ func CreatePipeline(c *gin.Context) {
 }
 
 func createTmpPipeline(event model.WebhookEvent, commit *model.Commit, user *model.User, opts *model.PipelineOptions) *model.Pipeline {
-	return &model.Pipeline{
+	pipeline := &model.Pipeline{
 		Event:     event,
 		Commit:    commit.SHA,
 		Branch:    opts.Branch,
@@ -98,9 +99,20 @@ func createTmpPipeline(event model.WebhookEvent, commit *model.Commit, user *mod
 
 		Author: user.Login,
 		Email:  user.Email,
+		Sender: user.Login,
 
 		ForgeURL: commit.ForgeURL,
 	}
+
+	// use the commit details if the forge provides them
+	if commit.Message != "" {
+		pipeline.Message = commit.Message
+	}
+	if commit.Author != "" {
+		pipeline.Author = commit.Author
+		pipeline.Email = commit.Email
+	}
+	return pipeline
 }
 
 // GetPipelines
@@ -564,6 +576,92 @@ func PostDecline(c *gin.Context) {
 	}
 }
 
+// maxReleaseAssetSize limits the size of an uploaded release asset, forges
+// may enforce a lower limit.
+const maxReleaseAssetSize = 100 << 20 // 100 MiB
+
+// releaseAssetFormOverhead leaves room for the multipart headers and the name
+// field of a release asset upload.
+const releaseAssetFormOverhead = 1 << 20
+
+// PostPipelineReleaseAsset
+//
+//	@Summary	Attach a file to the release that triggered a pipeline
+//	@Router		/repos/{repo_id}/pipelines/{number}/release-assets [post]
+//	@Accept		multipart/form-data
+//	@Success	204
+//	@Tags		Pipelines
+//	@Param		Authorization	header		string	true	"Insert your personal access token"	default(Bearer <personal access token>)
+//	@Param		repo_id			path		int		true	"the repository id"
+//	@Param		number			path		int		true	"the number of the pipeline"
+//	@Param		file			formData	file	true	"the asset to upload"
+//	@Param		name			formData	string	false	"the asset name, defaults to the file name"
+func PostPipelineReleaseAsset(c *gin.Context) {
+	var (
+		_store = store.FromContext(c)
+		repo   = session.Repo(c)
+		num, _ = strconv.ParseInt(c.Params.ByName("number"), 10, 64)
+	)
+
+	pl, err := _store.GetPipelineNumber(repo, num)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	if pl.Event != model.EventRelease {
+		c.String(http.StatusBadRequest, "pipeline was not triggered by a release")
+		return
+	}
+
+	_forge, err := server.Config.Services.Manager.ForgeFromRepo(repo)
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot get forge from repo")
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	publisher, ok := _forge.(forge.ReleaseAssetPublisher)
+	if !ok {
+		c.String(http.StatusNotImplemented, "forge does not support release assets")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReleaseAssetSize+releaseAssetFormOverhead)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.String(http.StatusRequestEntityTooLarge, "release asset is too large, the limit is %d bytes", maxReleaseAssetSize)
+			return
+		}
+		c.String(http.StatusBadRequest, "missing file: %s", err)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxReleaseAssetSize {
+		c.String(http.StatusRequestEntityTooLarge, "release asset is too large, the limit is %d bytes", maxReleaseAssetSize)
+		return
+	}
+
+	name := c.PostForm("name")
+	if name == "" {
+		name = header.Filename
+	}
+
+	repoUser, err := _store.GetUser(repo.UserID)
+	if err != nil {
+		handleDBError(c, err)
+		return
+	}
+	forge.Refresh(c, _forge, _store, repoUser)
+
+	if err := publisher.PublishReleaseAsset(c, repoUser, repo, pl, name, file, header.Size); err != nil {
+		c.String(http.StatusInternalServerError, "failed to publish release asset: %s", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 // GetPipelineQueue
 //
 //	@Summary	List pipelines in queue
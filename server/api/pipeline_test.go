@@ -15,7 +15,10 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -233,3 +236,106 @@ func TestGetPipelineMetadata(t *testing.T) {
 		})
 	})
 }
+
+func TestCreateTmpPipeline(t *testing.T) {
+	user := &model.User{Login: "alice", Email: "alice@example.com"}
+	opts := &model.PipelineOptions{Branch: "main"}
+
+	pipeline := createTmpPipeline(model.EventManual, &model.Commit{SHA: "abc"}, user, opts)
+	assert.Equal(t, "MANUAL PIPELINE @ main", pipeline.Message)
+	assert.Equal(t, "alice", pipeline.Author)
+	assert.Equal(t, "alice", pipeline.Sender)
+
+	pipeline = createTmpPipeline(model.EventManual, &model.Commit{SHA: "abc", Message: "fix build", Author: "bob", Email: "bob@example.com"}, user, opts)
+	assert.Equal(t, "fix build", pipeline.Message)
+	assert.Equal(t, "bob", pipeline.Author)
+	assert.Equal(t, "bob@example.com", pipeline.Email)
+	assert.Equal(t, "alice", pipeline.Sender)
+}
+
+// releaseAssetForge is a forge that publishes release assets.
+type releaseAssetForge struct {
+	*forge_mocks.MockForge
+	published map[string]int64
+}
+
+func (f *releaseAssetForge) PublishReleaseAsset(_ context.Context, _ *model.User, _ *model.Repo, _ *model.Pipeline, name string, _ io.ReadSeeker, size int64) error {
+	f.published[name] = size
+	return nil
+}
+
+// zeroReader reads an endless stream of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestPostPipelineReleaseAsset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fakeRepo := &model.Repo{ID: 1, UserID: 1}
+	fakeUser := &model.User{ID: 1}
+	releasePipeline := &model.Pipeline{ID: 2, Number: 2, Event: model.EventRelease}
+
+	upload := func(t *testing.T, _forge *releaseAssetForge, size int64) int {
+		mockManager := manager_mocks.NewMockManager(t)
+		mockManager.On("ForgeFromRepo", fakeRepo).Return(_forge, nil)
+		server.Config.Services.Manager = mockManager
+		mockStore := store_mocks.NewMockStore(t)
+		mockStore.On("GetPipelineNumber", fakeRepo, int64(2)).Return(releasePipeline, nil)
+		mockStore.On("GetUser", fakeUser.ID).Return(fakeUser, nil).Maybe()
+
+		body, writer := io.Pipe()
+		form := multipart.NewWriter(writer)
+		go func() {
+			part, err := form.CreateFormFile("file", "app.tar.gz")
+			if err == nil {
+				_, err = io.Copy(part, io.LimitReader(zeroReader{}, size))
+			}
+			if err == nil {
+				err = form.Close()
+			}
+			writer.CloseWithError(err)
+		}()
+		defer body.Close()
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/repos/1/pipelines/2/release-assets", body)
+		c.Request.Header.Set("Content-Type", form.FormDataContentType())
+		c.Params = gin.Params{{Key: "number", Value: "2"}}
+		c.Set("store", mockStore)
+		c.Set("repo", fakeRepo)
+
+		PostPipelineReleaseAsset(c)
+		return c.Writer.Status()
+	}
+
+	t.Run("should publish the uploaded asset", func(t *testing.T) {
+		_forge := &releaseAssetForge{MockForge: forge_mocks.NewMockForge(t), published: map[string]int64{}}
+
+		status := upload(t, _forge, 1024)
+
+		assert.Equal(t, http.StatusNoContent, status)
+		assert.Equal(t, map[string]int64{"app.tar.gz": 1024}, _forge.published)
+	})
+
+	t.Run("should reject assets above the limit", func(t *testing.T) {
+		_forge := &releaseAssetForge{MockForge: forge_mocks.NewMockForge(t), published: map[string]int64{}}
+
+		status := upload(t, _forge, maxReleaseAssetSize+1)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+		assert.Empty(t, _forge.published)
+	})
+
+	t.Run("should stop reading oversized requests", func(t *testing.T) {
+		_forge := &releaseAssetForge{MockForge: forge_mocks.NewMockForge(t), published: map[string]int64{}}
+
+		status := upload(t, _forge, maxReleaseAssetSize+2*releaseAssetFormOverhead)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+		assert.Empty(t, _forge.published)
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -34,6 +35,7 @@ import (
 	"go.woodpecker-ci.org/woodpecker/v3/server/store"
 	"go.woodpecker-ci.org/woodpecker/v3/server/store/types"
 	"go.woodpecker-ci.org/woodpecker/v3/shared/token"
+	shared_utils "go.woodpecker-ci.org/woodpecker/v3/shared/utils"
 )
 
 // PostRepo
@@ -75,7 +77,8 @@ func PostRepo(c *gin.Context) {
 
 	from, err := _forge.Repo(c, user, forgeRemoteID, "", "")
 	if err != nil {
-		c.String(http.StatusInternalServerError, "Could not fetch repository from forge.")
+		log.Error().Err(err).Msgf("could not fetch repository %s from forge", forgeRemoteID)
+		handleForgeRepoErr(c, err)
 		return
 	}
 	if !from.Perm.Admin {
@@ -251,6 +254,12 @@ func PatchRepo(c *gin.Context) {
 	if in.AllowDeploy != nil {
 		repo.AllowDeploy = *in.AllowDeploy
 	}
+	if in.CommentOnFailure != nil {
+		repo.CommentOnFailure = *in.CommentOnFailure
+	}
+	if in.SkipDraftPullRequests != nil {
+		repo.SkipDraftPullRequests = *in.SkipDraftPullRequests
+	}
 
 	if in.RequireApproval != nil {
 		if mode := model.ApprovalMode(*in.RequireApproval); mode.Valid() {
@@ -372,6 +381,7 @@ func GetRepoPermissions(c *gin.Context) {
 //	@Param		repo_id			path	int		true	"the repository id"
 //	@Param		page			query	int		false	"for response pagination, page offset number"	default(1)
 //	@Param		perPage			query	int		false	"for response pagination, max items per page"	default(50)
+//	@Param		search			query	string	false	"only return branches whose name contains this value, ignoring case"
 func GetRepoBranches(c *gin.Context) {
 	_store := store.FromContext(c)
 	repo := session.Repo(c)
@@ -390,7 +400,20 @@ func GetRepoBranches(c *gin.Context) {
 
 	forge.Refresh(c, _forge, _store, repoUser)
 
-	branches, err := _forge.Branches(c, repoUser, repo, session.Pagination(c))
+	var branches []string
+	search := c.Query("search")
+	pagination := session.Pagination(c)
+	searcher, canSearch := _forge.(forge.BranchSearcher)
+	switch {
+	case search == "":
+		branches, err = _forge.Branches(c, repoUser, repo, pagination)
+	case canSearch:
+		branches, err = searcher.SearchBranches(c, repoUser, repo, search, pagination)
+	default:
+		// forges without server-side search are filtered here, which needs all branches before paginating
+		branches, err = searchAllBranches(c, _forge, repoUser, repo, search, pagination.PerPage)
+		branches = model.ApplyPagination(pagination, branches)
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("failed to load branches")
 		c.String(http.StatusInternalServerError, "failed to load branches: %s", err)
@@ -400,6 +423,25 @@ func GetRepoBranches(c *gin.Context) {
 	c.JSON(http.StatusOK, branches)
 }
 
+// searchAllBranches loads all branches of repo and returns those whose name contains search, ignoring case.
+func searchAllBranches(c *gin.Context, _forge forge.Forge, u *model.User, repo *model.Repo, search string, perPage int) ([]string, error) {
+	branches, err := shared_utils.Paginate(func(page int) ([]string, error) {
+		return _forge.Branches(c, u, repo, &model.ListOptions{Page: page, PerPage: perPage})
+	}, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	search = strings.ToLower(search)
+	filtered := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		if strings.Contains(strings.ToLower(branch), search) {
+			filtered = append(filtered, branch)
+		}
+	}
+	return filtered, nil
+}
+
 // GetRepoPullRequests
 //
 //	@Summary	List active pull requests of a repository
@@ -536,7 +578,8 @@ func MoveRepo(c *gin.Context) {
 
 	from, err := _forge.Repo(c, user, "", owner, name)
 	if err != nil {
-		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		_ = c.Error(err)
+		handleForgeRepoErr(c, err)
 		return
 	}
 	if !from.Perm.Admin {
@@ -66,11 +66,12 @@ func PostSecret(c *gin.Context) {
 		return
 	}
 	secret := &model.Secret{
-		RepoID: repo.ID,
-		Name:   in.Name,
-		Value:  in.Value,
-		Events: in.Events,
-		Images: in.Images,
+		RepoID:    repo.ID,
+		Name:      in.Name,
+		Value:     in.Value,
+		Events:    in.Events,
+		Images:    in.Images,
+		Protected: in.Protected,
 	}
 	if err := secret.Validate(); err != nil {
 		c.String(http.StatusUnprocessableEntity, "Error inserting secret. %s", err)
@@ -102,7 +103,7 @@ func PatchSecret(c *gin.Context) {
 		name = c.Param("secret")
 	)
 
-	in := new(model.Secret)
+	in := new(secretPatch)
 	err := c.Bind(in)
 	if err != nil {
 		c.String(http.StatusBadRequest, "Error parsing secret. %s", err)
@@ -124,6 +125,9 @@ func PatchSecret(c *gin.Context) {
 	if in.Images != nil {
 		secret.Images = in.Images
 	}
+	if in.Protected != nil {
+		secret.Protected = *in.Protected
+	}
 
 	if err := secret.Validate(); err != nil {
 		c.String(http.StatusUnprocessableEntity, "Error updating secret. %s", err)
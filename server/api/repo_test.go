@@ -0,0 +1,94 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server"
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge"
+	forge_mocks "go.woodpecker-ci.org/woodpecker/v3/server/forge/mocks"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	manager_mocks "go.woodpecker-ci.org/woodpecker/v3/server/services/mocks"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+// branchSearchForge is a forge that searches branches server-side.
+type branchSearchForge struct {
+	*forge_mocks.MockForge
+	results []string
+}
+
+func (f *branchSearchForge) SearchBranches(_ context.Context, _ *model.User, _ *model.Repo, _ string, _ *model.ListOptions) ([]string, error) {
+	return f.results, nil
+}
+
+func TestGetRepoBranches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fakeRepo := &model.Repo{ID: 1, UserID: 1}
+	fakeUser := &model.User{ID: 1}
+
+	getBranches := func(t *testing.T, _forge forge.Forge, query string) []string {
+		mockManager := manager_mocks.NewMockManager(t)
+		mockManager.On("ForgeFromRepo", fakeRepo).Return(_forge, nil)
+		server.Config.Services.Manager = mockManager
+		mockStore := store_mocks.NewMockStore(t)
+		mockStore.On("GetUser", fakeUser.ID).Return(fakeUser, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/repos/1/branches?"+query, nil)
+		c.Set("store", mockStore)
+		c.Set("repo", fakeRepo)
+
+		GetRepoBranches(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var branches []string
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &branches))
+		return branches
+	}
+
+	t.Run("should return the results of server-side search unchanged", func(t *testing.T) {
+		_forge := &branchSearchForge{MockForge: forge_mocks.NewMockForge(t), results: []string{"Feature-A", "feature-b"}}
+
+		assert.Equal(t, []string{"Feature-A", "feature-b"}, getBranches(t, _forge, "search=feature"))
+	})
+
+	t.Run("should search all branches before paginating for forges without search", func(t *testing.T) {
+		pages := map[int][]string{
+			1: {"main", "feature-a"},
+			2: {"Feature-b", "dev"},
+			3: {"feature-c"},
+		}
+		_forge := forge_mocks.NewMockForge(t)
+		for page, branches := range pages {
+			_forge.On("Branches", mock.Anything, fakeUser, fakeRepo, &model.ListOptions{Page: page, PerPage: 2}).Return(branches, nil)
+		}
+
+		assert.Equal(t, []string{"feature-a", "Feature-b"}, getBranches(t, _forge, "search=FEATURE&perPage=2&page=1"))
+		assert.Equal(t, []string{"feature-c"}, getBranches(t, _forge, "search=feature&perPage=2&page=2"))
+		assert.Empty(t, getBranches(t, _forge, "search=feature&perPage=2&page=3"))
+	})
+}
@@ -144,6 +144,8 @@ func CreatePipeline(ctx context.Context, store store.Store, cron *model.Cron) (*
 		Message:   cron.Name,
 		Timestamp: cron.NextExec,
 		Sender:    cron.Name,
+		Author:    commit.Author,
+		Email:     commit.Email,
 		ForgeURL:  commit.ForgeURL,
 	}, nil
 }
@@ -17,6 +17,7 @@ package forge
 
 import (
 	"context"
+	"io"
 	"net/http"
 
 	"go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
@@ -91,3 +92,43 @@ type Forge interface {
 	// Org fetches the organization from the forge by name. If the name is a user an org with type user is returned.
 	Org(ctx context.Context, u *model.User, org string) (*model.Org, error)
 }
+
+// BranchSearcher is implemented by forges that can filter branches by name server-side.
+type BranchSearcher interface {
+	// SearchBranches returns the names of the branches whose name contains search.
+	SearchBranches(ctx context.Context, u *model.User, r *model.Repo, search string, p *model.ListOptions) ([]string, error)
+}
+
+// ReleaseAssetPublisher is implemented by forges that can attach files to a release.
+type ReleaseAssetPublisher interface {
+	// PublishReleaseAsset uploads content as asset name to the release that triggered pipeline p.
+	PublishReleaseAsset(ctx context.Context, u *model.User, r *model.Repo, p *model.Pipeline, name string, content io.ReadSeeker, size int64) error
+}
+
+// RefProtectionChecker is implemented by forges that know which branches and tags are protected.
+type RefProtectionChecker interface {
+	// IsProtectedRef reports whether the branch or tag pipeline p runs for is protected.
+	IsProtectedRef(ctx context.Context, u *model.User, r *model.Repo, p *model.Pipeline) (bool, error)
+}
+
+// SystemHookReceiver is implemented by forges that accept instance-wide webhooks,
+// which are sent to /api/hook?forge_id=<id> without a repo token.
+type SystemHookReceiver interface {
+	// SystemHookRepo authenticates a system hook delivery and returns the activated repo it belongs to.
+	// The request body has to stay readable for the following call to Hook.
+	SystemHookRepo(ctx context.Context, r *http.Request) (*model.Repo, error)
+}
+
+// HookDeliveryTracker is implemented by forges that drop redelivered webhooks.
+// Hook only reserves a delivery, the server reports afterwards whether a pipeline
+// was created, so a redelivery of a failed delivery is accepted again.
+type HookDeliveryTracker interface {
+	// HookHandled is called once the server is done with the pipeline returned by Hook.
+	HookHandled(ctx context.Context, p *model.Pipeline, created bool)
+}
+
+// FailureNotifier is implemented by forges that can notify users on the forge about failed pipelines.
+type FailureNotifier interface {
+	// NotifyFailure is called once a pipeline finished with a failure.
+	NotifyFailure(ctx context.Context, u *model.User, r *model.Repo, p *model.Pipeline) error
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/url"
+	"strings"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// gitcode.com 也可以通过 gitcode.net 访问
+var defaultDomainAliases = map[string][]string{
+	"gitcode.com": {"gitcode.net"},
+}
+
+// normalizeHost 将 "https://Example.com:443/" 或 "example.com" 统一为小写主机名
+func normalizeHost(s string) string {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if strings.Contains(s, "://") {
+		if u, err := url.Parse(s); err == nil {
+			return u.Hostname()
+		}
+	}
+	s = strings.TrimSuffix(s, "/")
+	if i := strings.LastIndex(s, ":"); i != -1 && !strings.Contains(s, "]") {
+		s = s[:i]
+	}
+	return s
+}
+
+// domainAliases 返回实例主机之外被视为同一实例的主机名
+func domainAliases(instanceHost string, configured []string) []string {
+	var aliases []string
+	for _, alias := range append(configured, defaultDomainAliases[instanceHost]...) {
+		host := normalizeHost(alias)
+		if host == "" || host == instanceHost {
+			continue
+		}
+		aliases = append(aliases, host)
+	}
+	return aliases
+}
+
+// isInstanceHost 判断主机名是否为当前实例或其别名
+func (c *GitCode) isInstanceHost(host string) bool {
+	host = normalizeHost(host)
+	if host == c.links().hostname() {
+		return true
+	}
+	for _, alias := range c.aliases {
+		if host == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalURL 将使用别名域名的 HTTP(S) 或 SSH 地址改写为实例域名
+func (c *GitCode) canonicalURL(raw string) string {
+	if raw == "" || len(c.aliases) == 0 {
+		return raw
+	}
+	instance := c.links().hostname()
+
+	// git@host:owner/repo.git
+	if rest, ok := strings.CutPrefix(raw, "git@"); ok {
+		host, path, found := strings.Cut(rest, ":")
+		if found && host != instance && c.isInstanceHost(host) {
+			return "git@" + instance + ":" + path
+		}
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	if u.Hostname() == instance || !c.isInstanceHost(u.Hostname()) {
+		return raw
+	}
+	u.Host = c.links().host()
+	return u.String()
+}
+
+// canonicalizeRepo 将仓库地址中的别名域名改写为实例域名
+func (c *GitCode) canonicalizeRepo(repo *model.Repo) {
+	if repo == nil {
+		return
+	}
+	repo.ForgeURL = c.canonicalURL(repo.ForgeURL)
+	repo.Clone = c.canonicalURL(repo.Clone)
+	repo.CloneSSH = c.canonicalURL(repo.CloneSSH)
+	repo.Avatar = c.canonicalURL(repo.Avatar)
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// maxArchiveSize 是读取配置时下载的压缩归档的最大字节数，更大的仓库改为逐个请求目录树和文件
+const maxArchiveSize = 50 << 20
+
+// errArchiveTooLarge 表示归档超过 maxArchiveSize
+var errArchiveTooLarge = errors.New("archive exceeds the size limit")
+
+// readArchive 下载 ref 处的归档，返回完整的目录树以及 dir 目录中可能是流水线配置的文件内容，
+// 一次请求即可代替递归获取目录树和逐个下载文件。归档中没有 blob SHA，目录树条目的 SHA 为空
+func (c *GitCode) readArchive(ctx context.Context, client *GitCodeClient, r *model.Repo, ref, dir string) ([]TreeEntry, map[string][]byte, error) {
+	ctx, cancel := c.timeouts.withOperation(ctx, opArchive)
+	defer cancel()
+
+	body, err := client.GetArchive(ctx, r.Owner, r.Name, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer body.Close()
+
+	limited := &io.LimitedReader{R: body, N: maxArchiveSize + 1}
+	tree, files, err := readConfigArchive(limited, dir)
+	if limited.N <= 0 {
+		return nil, nil, errArchiveTooLarge
+	}
+	return tree, files, err
+}
+
+// readConfigArchive 读取 tar.gz 归档。归档中的路径以 "<仓库>-<引用>/" 开头，返回的路径去掉了这一层
+func readConfigArchive(archive io.Reader, dir string) ([]TreeEntry, map[string][]byte, error) {
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read archive: %w", err)
+	}
+	defer gz.Close()
+
+	var tree []TreeEntry
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return tree, files, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read archive: %w", err)
+		}
+
+		entryType := "blob"
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+		case tar.TypeDir:
+			entryType = "tree"
+		default:
+			// 全局扩展头、符号链接等
+			continue
+		}
+		_, name, ok := strings.Cut(strings.TrimSuffix(hdr.Name, "/"), "/")
+		if !ok || name == "" {
+			continue
+		}
+		tree = append(tree, TreeEntry{Name: path.Base(name), Path: name, Type: entryType, Size: hdr.Size})
+
+		if entryType == "blob" && inDir(name, dir) && isConfigCandidate(name, hdr.Size) {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("read %s from archive: %w", name, err)
+			}
+			files[name] = data
+		}
+	}
+}
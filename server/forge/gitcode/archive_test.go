@@ -0,0 +1,98 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// tarball 构建测试用的 tar.gz 归档，以 / 结尾的名称为目录
+func tarball(t *testing.T, files map[string]string, order ...string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range order {
+		if strings.HasSuffix(name, "/") {
+			assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0o755}))
+			continue
+		}
+		content := files[name]
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestReadConfigArchive(t *testing.T) {
+	archive := tarball(t, map[string]string{
+		"testci-abc/README.md":                "# testci",
+		"testci-abc/.woodpecker/build.yaml":   "steps: []",
+		"testci-abc/.woodpecker/logo.png":     "PNG",
+		"testci-abc/.woodpecker/nested/x.yml": "steps: []",
+	}, "testci-abc/", "testci-abc/README.md", "testci-abc/.woodpecker/", "testci-abc/.woodpecker/build.yaml",
+		"testci-abc/.woodpecker/logo.png", "testci-abc/.woodpecker/nested/", "testci-abc/.woodpecker/nested/x.yml")
+
+	tree, files, err := readConfigArchive(bytes.NewReader(archive), ".woodpecker/")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{".woodpecker/build.yaml": []byte("steps: []")}, files)
+
+	var paths []string
+	for _, entry := range tree {
+		paths = append(paths, entry.Type+":"+entry.Path)
+	}
+	assert.Equal(t, []string{
+		"blob:README.md", "tree:.woodpecker", "blob:.woodpecker/build.yaml",
+		"blob:.woodpecker/logo.png", "tree:.woodpecker/nested", "blob:.woodpecker/nested/x.yml",
+	}, paths)
+
+	_, _, err = readConfigArchive(strings.NewReader("not gzip"), ".woodpecker/")
+	assert.Error(t, err)
+}
+
+func TestDirReadsArchive(t *testing.T) {
+	archive := tarball(t, map[string]string{
+		"repo-abc/.woodpecker/a.yaml": "steps: [a]",
+		"repo-abc/.woodpecker/b.yaml": "steps: [b]",
+	}, "repo-abc/.woodpecker/a.yaml", "repo-abc/.woodpecker/b.yaml")
+
+	var requests []string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		requests = append(requests, req.URL.Path+"?"+req.URL.RawQuery)
+		if strings.HasSuffix(req.URL.Path, "/tarball") {
+			return http.StatusOK, string(archive)
+		}
+		return http.StatusNotFound, `{}`
+	})
+
+	files, err := c.Dir(t.Context(), &model.User{AccessToken: "token"}, &model.Repo{Owner: "owner", Name: "repo"}, &model.Pipeline{Commit: "abc"}, ".woodpecker")
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, ".woodpecker/a.yaml", files[0].Name)
+	assert.Equal(t, "steps: [a]", string(files[0].Data))
+	assert.Equal(t, "steps: [b]", string(files[1].Data))
+	assert.Equal(t, []string{"/api/v5/repos/owner/repo/tarball?ref=abc"}, requests)
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// backfillCommitAuthor 在推送事件缺少作者或邮箱时，通过提交接口补充作者、邮箱和提交信息；
+// 查询失败时保留 webhook 中的信息
+func (c *GitCode) backfillCommitAuthor(ctx context.Context, repo *model.Repo, p *model.Pipeline) {
+	if p.Commit == "" || (p.Author != "" && p.Email != "" && p.Message != "") {
+		return
+	}
+
+	client, stored, err := c.repoOwnerClient(ctx, repo)
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not look up author of commit %s in %s", p.Commit, repo.FullName)
+		return
+	}
+	commit, err := client.GetCommit(ctx, stored.Owner, stored.Name, p.Commit)
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not look up author of commit %s in %s", p.Commit, repo.FullName)
+		return
+	}
+
+	author := commit.Commit.Author
+	p.Author = orDefault(p.Author, author.Name)
+	p.Email = orDefault(p.Email, author.Email)
+	p.Message = orDefault(p.Message, commit.Commit.Message)
+	if p.Author != "" && p.Sender == "" {
+		p.Sender = p.Author
+	}
+}
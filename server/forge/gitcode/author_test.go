@@ -0,0 +1,57 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func TestBackfillCommitAuthor(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Owner: "owner", Name: "repo", UserID: 1}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("1"), "owner/repo").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token", Expiry: 1 << 40}, nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	var requests int
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		requests++
+		assert.Equal(t, "/api/v5/repos/owner/repo/commits/abc", req.URL.Path)
+		return http.StatusOK, `{"sha":"abc","commit":{"message":"fix build","author":{"name":"Jane","email":"jane@example.com"}}}`
+	})
+
+	p := &model.Pipeline{Commit: "abc", Author: "jane", Message: "fix"}
+	c.backfillCommitAuthor(ctx, repo, p)
+	assert.Equal(t, "jane", p.Author)
+	assert.Equal(t, "jane@example.com", p.Email)
+	assert.Equal(t, "fix", p.Message)
+
+	p = &model.Pipeline{Commit: "abc"}
+	c.backfillCommitAuthor(ctx, repo, p)
+	assert.Equal(t, "Jane", p.Author)
+	assert.Equal(t, "Jane", p.Sender)
+	assert.Equal(t, "fix build", p.Message)
+
+	// 信息完整时不查询
+	c.backfillCommitAuthor(ctx, repo, &model.Pipeline{Commit: "abc", Author: "jane", Email: "jane@example.com", Message: "fix"})
+	assert.Equal(t, 2, requests)
+}
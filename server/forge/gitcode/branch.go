@@ -0,0 +1,57 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+)
+
+// syncDefaultBranch 确保 webhook 返回的仓库带有当前的默认分支，服务端据此更新保存的仓库，
+// 在 GitCode 上修改默认分支后手动和定时流水线使用新的默认分支。
+// 部分 webhook 不包含默认分支，此时通过 API 查询，查询失败时保留已保存的默认分支，避免被清空
+func (c *GitCode) syncDefaultBranch(ctx context.Context, repo *model.Repo) {
+	_store, ok := store.TryFromContext(ctx)
+	if !ok {
+		return
+	}
+	stored, err := _store.GetRepoNameFallback(repo.ForgeRemoteID, repo.FullName)
+	if err != nil {
+		return
+	}
+
+	if repo.Branch == "" {
+		repo.Branch = stored.Branch
+		client, _, err := c.repoOwnerClient(ctx, repo)
+		if err != nil {
+			log.Debug().Err(err).Msgf("could not look up the default branch of %s", repo.FullName)
+			return
+		}
+		remote, err := client.GetRepo(ctx, stored.Owner, stored.Name)
+		if err != nil || remote.DefaultBranch == "" {
+			log.Debug().Err(err).Msgf("could not look up the default branch of %s", repo.FullName)
+			return
+		}
+		repo.Branch = remote.DefaultBranch
+	}
+
+	if stored.Branch != "" && stored.Branch != repo.Branch {
+		log.Info().Msgf("default branch of %s changed from %s to %s", repo.FullName, stored.Branch, repo.Branch)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func TestSyncDefaultBranch(t *testing.T) {
+	stored := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Owner: "owner", Name: "repo", UserID: 1, Branch: "master"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("1"), "owner/repo").Return(stored, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token", Expiry: 1 << 40}, nil).Maybe()
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	status := http.StatusOK
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/api/v5/repos/owner/repo", req.URL.Path)
+		return status, `{"id":1,"full_name":"owner/repo","default_branch":"main"}`
+	})
+
+	// webhook 带有默认分支时直接使用
+	repo := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Branch: "develop"}
+	c.syncDefaultBranch(ctx, repo)
+	assert.Equal(t, "develop", repo.Branch)
+
+	repo = &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo"}
+	c.syncDefaultBranch(ctx, repo)
+	assert.Equal(t, "main", repo.Branch)
+
+	// 查询失败时保留已保存的默认分支
+	status = http.StatusInternalServerError
+	repo = &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo"}
+	c.syncDefaultBranch(ctx, repo)
+	assert.Equal(t, "master", repo.Branch)
+}
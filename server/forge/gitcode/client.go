@@ -18,42 +18,203 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
 )
 
 // GitCodeClient GitCode API v5 客户端
 type GitCodeClient struct {
-	baseURL    string
-	token      string
-	httpClient *http.Client
+	token           string
+	httpClient      *http.Client
+	uploadClient    *http.Client
+	maxResponseSize int64
+	middlewares     []Middleware
+	inflight        *singleflight.Group
+	rateLimits      *rateLimits
+	responses       *responseCache
+	api             apiAdapter
+	proxies         proxyProfiles
+	logSampler      zerolog.Sampler
+	authMode        AuthMode
+	maxRetries      int
+	retryBackoff    time.Duration
+	timeouts        operationTimeouts
+	debugTrace      bool
+}
+
+// ClientOption 配置 GitCodeClient 的可选项
+type ClientOption func(*GitCodeClient)
+
+// WithMaxResponseSize 设置读取响应体的最大字节数，小于等于 0 表示不限制
+func WithMaxResponseSize(size int64) ClientOption {
+	return func(c *GitCodeClient) {
+		c.maxResponseSize = size
+	}
+}
+
+// WithMiddleware 在默认中间件之后追加自定义中间件，越靠后越接近网络层
+func WithMiddleware(middlewares ...Middleware) ClientOption {
+	return func(c *GitCodeClient) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithSingleflight 合并相同的并发 GET 请求，group 通常在多个客户端之间共享
+func WithSingleflight(group *singleflight.Group) ClientOption {
+	return func(c *GitCodeClient) {
+		c.inflight = group
+	}
+}
+
+// WithRateLimits 按令牌记录 GitCode 返回的限流状态，limits 通常在多个客户端之间共享
+func WithRateLimits(limits *rateLimits) ClientOption {
+	return func(c *GitCodeClient) {
+		c.rateLimits = limits
+	}
+}
+
+// WithResponseCache 对带有 ETag 或 Last-Modified 的 GET 响应发送条件请求，cache 通常在多个客户端之间共享
+func WithResponseCache(cache *responseCache) ClientOption {
+	return func(c *GitCodeClient) {
+		c.responses = cache
+	}
+}
+
+// WithAPIAdapter 指定使用的 API 版本适配器，默认为 v5
+func WithAPIAdapter(adapter apiAdapter) ClientOption {
+	return func(c *GitCodeClient) {
+		c.api = adapter
+	}
+}
+
+// WithProxyProfiles 按操作类别选择出口代理，未配置的类别使用环境变量中的代理
+func WithProxyProfiles(profiles proxyProfiles) ClientOption {
+	return func(c *GitCodeClient) {
+		c.proxies = profiles
+	}
+}
+
+// WithLogSampler 对成功的读请求日志进行采样，sampler 通常在多个客户端之间共享
+func WithLogSampler(sampler zerolog.Sampler) ClientOption {
+	return func(c *GitCodeClient) {
+		c.logSampler = sampler
+	}
+}
+
+// WithRetry 指定幂等请求失败后的最大重试次数和初始退避时间，退避时间每次重试翻倍
+func WithRetry(maxRetries int, backoff time.Duration) ClientOption {
+	return func(c *GitCodeClient) {
+		c.maxRetries = max(maxRetries, 0)
+		c.retryBackoff = backoff
+	}
+}
+
+// WithTimeouts 覆盖各类操作的超时时间，调用方在 context 中设置的截止时间优先
+func WithTimeouts(timeouts operationTimeouts) ClientOption {
+	return func(c *GitCodeClient) {
+		c.timeouts = timeouts
+	}
+}
+
+// WithDebugTrace 记录每次请求的 URL、状态、耗时以及截断并脱敏后的请求体和响应体
+func WithDebugTrace(enabled bool) ClientOption {
+	return func(c *GitCodeClient) {
+		c.debugTrace = enabled
+	}
+}
+
+// WithAuthMode 指定访问令牌的传递方式，默认使用 Authorization 请求头
+func WithAuthMode(mode AuthMode) ClientOption {
+	return func(c *GitCodeClient) {
+		c.authMode = mode
+	}
 }
 
 // NewGitCodeClient 创建新的 GitCode 客户端
-func NewGitCodeClient(token string, skipVerify bool) *GitCodeClient {
-	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
-			Proxy:           http.ProxyFromEnvironment,
-		},
+func NewGitCodeClient(token string, skipVerify bool, opts ...ClientOption) *GitCodeClient {
+	client := &GitCodeClient{
+		token:           token,
+		maxResponseSize: defaultMaxResponseSize,
+		api:             v5Adapter{},
+		authMode:        AuthModeBearer,
+		maxRetries:      defaultMaxRetries,
+		retryBackoff:    defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(client)
 	}
+	if client.rateLimits == nil {
+		client.rateLimits = newRateLimits()
+	}
+
+	base := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
+		Proxy:           client.proxies.proxy,
+	}
+	middlewares := []Middleware{
+		// 缓存放在最外层，日志和指标中可以看到 304 响应
+		cacheMiddleware(client.responses, token),
+		loggingMiddleware(client.logSampler),
+		metricsMiddleware(),
+		headerMiddleware(),
+		authMiddleware(token, client.authMode),
+		retryMiddleware(client.maxRetries, client.retryBackoff),
+		// 限流放在重试之后，每次重试同样需要等待限流重置
+		rateLimitMiddleware(client.rateLimits, token),
+	}
+	if client.debugTrace {
+		// 跟踪放在认证和重试之后，记录实际发出的每次请求
+		middlewares = append(middlewares, traceMiddleware())
+	}
+	middlewares = append(middlewares, client.middlewares...)
 
-	return &GitCodeClient{
-		baseURL:    defaultURL,
-		token:      token,
-		httpClient: httpClient,
+	// 超时由 context 控制，见 withOperation
+	client.httpClient = &http.Client{
+		Transport: chain(base, middlewares...),
+	}
+	// 上传地址是预签名的对象存储地址，不能附带访问令牌
+	client.uploadClient = &http.Client{
+		Transport: chain(base, append([]Middleware{loggingMiddleware(client.logSampler)}, client.middlewares...)...),
 	}
+	return client
+}
+
+// readBody 读取响应体，超过 maxResponseSize 时返回 ResponseTooLargeError
+func (c *GitCodeClient) readBody(resp *http.Response) ([]byte, error) {
+	return readLimited(resp.Body, c.maxResponseSize)
+}
+
+// buildURL 根据 API 路径和查询参数构建完整的请求 URL
+func (c *GitCodeClient) buildURL(endpoint string, query url.Values) (string, error) {
+	u, err := url.Parse(c.api.baseURL() + endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse endpoint %q: %w", endpoint, err)
+	}
+
+	q := u.Query()
+	for key, values := range query {
+		for _, value := range values {
+			q.Add(key, value)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
 }
 
 // makeRequest 发送 HTTP 请求
-func (c *GitCodeClient) makeRequest(ctx context.Context, method, endpoint string, body interface{}) (*http.Response, error) {
+func (c *GitCodeClient) makeRequest(ctx context.Context, method, endpoint string, query url.Values, body interface{}) (*http.Response, error) {
 	var reqBody io.Reader
 	if body != nil {
 		jsonBody, err := json.Marshal(body)
@@ -63,80 +224,166 @@ func (c *GitCodeClient) makeRequest(ctx context.Context, method, endpoint string
 		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
-	// GitCode API 基础 URL，使用独立的 API 域名
-	url := fmt.Sprintf("%s%s", defaultAPI, endpoint)
-
-	// GitCode 使用 access_token 查询参数进行认证
-	if c.token != "" {
-		// 如果 URL 已经有查询参数，添加 &，否则添加 ?
-		if strings.Contains(url, "?") {
-			url += "&access_token=" + c.token
-		} else {
-			url += "?access_token=" + c.token
-		}
+	reqURL, err := c.buildURL(endpoint, query)
+	if err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	// 调用方未指定截止时间时，使用默认超时
+	ctx, cancel := c.timeouts.ensureDeadline(ctx)
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
-	// 设置标准头部
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "Woodpecker-CI")
+	// Accept、User-Agent 和认证信息由中间件设置
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	return c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, redactError(err)
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
 }
 
-// get 发送 GET 请求并解析 JSON 响应
-func (c *GitCodeClient) get(ctx context.Context, endpoint string, result interface{}) error {
-	resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
+// pageQuery 构建分页查询参数
+func pageQuery(page, limit int) url.Values {
+	return url.Values{
+		"page":     []string{strconv.Itoa(page)},
+		"per_page": []string{strconv.Itoa(limit)},
+	}
+}
+
+// fetch 发送 GET 请求并返回响应体，状态码 >= 400 时返回 APIError。
+// 配置了 singleflight 时，相同 token、操作和 URL 的并发请求只会发出一次。共享的请求不随任何一个
+// 调用方取消，按操作的超时时间单独限时，每个调用方只等待到自己的 context 结束
+func (c *GitCodeClient) fetch(ctx context.Context, endpoint string, query url.Values) (int, []byte, error) {
+	if c.inflight == nil {
+		return c.doFetch(ctx, endpoint, query)
+	}
+
+	reqURL, err := c.buildURL(endpoint, query)
 	if err != nil {
-		return err
+		return 0, nil, err
+	}
+
+	type fetchResult struct {
+		status int
+		body   []byte
+	}
+	// 操作决定超时时间和代理配置，只合并同一操作的请求
+	op := operationFromContext(ctx)
+	results := c.inflight.DoChan(c.token+" "+op.String()+" "+reqURL, func() (any, error) {
+		sharedCtx, cancel := c.timeouts.withOperation(context.WithoutCancel(ctx), op)
+		defer cancel()
+		status, body, err := c.doFetch(sharedCtx, endpoint, query)
+		return fetchResult{status: status, body: body}, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	case result := <-results:
+		res, _ := result.Val.(fetchResult)
+		if result.Shared {
+			// 调用方可能修改返回的字节切片，共享结果需要复制
+			res.body = bytes.Clone(res.body)
+		}
+		return res.status, res.body, result.Err
+	}
+}
+
+// doFetch 实际发送 GET 请求并读取响应体
+func (c *GitCodeClient) doFetch(ctx context.Context, endpoint string, query url.Values) (int, []byte, error) {
+	resp, err := c.makeRequest(ctx, http.MethodGet, endpoint, query, nil)
+	if err != nil {
+		return 0, nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("GitCode API Error %d for %s: %s", resp.StatusCode, endpoint, string(body))
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+		return resp.StatusCode, nil, newAPIError(resp)
+	}
+
+	body, err := c.readBody(resp)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("read response body: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// get 发送 GET 请求并解析 JSON 响应
+func (c *GitCodeClient) get(ctx context.Context, endpoint string, query url.Values, result interface{}) error {
+	status, body, err := c.fetch(ctx, endpoint, query)
+	if err != nil {
+		return err
 	}
 
 	if result != nil {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("read response body: %w", err)
+		if apiErr := errorEnvelope(status, body); apiErr != nil {
+			return apiErr
 		}
 
-		log.Printf("GitCode API Response for %s: %s", endpoint, string(body))
-
-		if err := json.Unmarshal(body, result); err != nil {
-			log.Printf("GitCode API JSON decode error for %s: %v, body: %s", endpoint, err, string(body))
-			return fmt.Errorf("decode JSON response: %w", err)
+		if err := c.api.decode(body, result); err != nil {
+			return fmt.Errorf("decode JSON response of %s: %w", endpoint, err)
 		}
 	}
 
 	return nil
 }
 
+// getJSON 发送 GET 请求并返回解析后的类型化结果
+func getJSON[T any](ctx context.Context, c *GitCodeClient, endpoint string, query url.Values) (T, error) {
+	var result T
+	err := c.get(ctx, endpoint, query, &result)
+	return result, err
+}
+
+// postJSON 发送 POST 请求并返回解析后的类型化结果
+func postJSON[T any](ctx context.Context, c *GitCodeClient, endpoint string, body any) (T, error) {
+	var result T
+	err := c.post(ctx, endpoint, body, &result)
+	return result, err
+}
+
+// patchJSON 发送 PATCH 请求并返回解析后的类型化结果
+func patchJSON[T any](ctx context.Context, c *GitCodeClient, endpoint string, body any) (T, error) {
+	var result T
+	err := c.send(ctx, http.MethodPatch, endpoint, body, &result)
+	return result, err
+}
+
 // post 发送 POST 请求
 func (c *GitCodeClient) post(ctx context.Context, endpoint string, body interface{}, result interface{}) error {
-	resp, err := c.makeRequest(ctx, "POST", endpoint, body)
+	return c.send(ctx, http.MethodPost, endpoint, body, result)
+}
+
+// send 发送带 JSON 请求体的请求
+func (c *GitCodeClient) send(ctx context.Context, method, endpoint string, body interface{}, result interface{}) error {
+	resp, err := c.makeRequest(ctx, method, endpoint, nil, body)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return newAPIError(resp)
 	}
 
 	if result != nil {
-		return json.NewDecoder(resp.Body).Decode(result)
+		respBody, err := c.readBody(resp)
+		if err != nil {
+			return fmt.Errorf("read response body: %w", err)
+		}
+		if apiErr := errorEnvelope(resp.StatusCode, respBody); apiErr != nil {
+			return apiErr
+		}
+		return c.api.decode(respBody, result)
 	}
 
 	return nil
@@ -144,15 +391,14 @@ func (c *GitCodeClient) post(ctx context.Context, endpoint string, body interfac
 
 // delete 发送 DELETE 请求
 func (c *GitCodeClient) delete(ctx context.Context, endpoint string) error {
-	resp, err := c.makeRequest(ctx, "DELETE", endpoint, nil)
+	resp, err := c.makeRequest(ctx, http.MethodDelete, endpoint, nil, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+		return newAPIError(resp)
 	}
 
 	return nil
@@ -160,13 +406,68 @@ func (c *GitCodeClient) delete(ctx context.Context, endpoint string) error {
 
 // GitCode API 数据结构
 
+// ID GitCode 对象 ID，API 可能返回字符串或数字
+type ID string
+
+// UnmarshalJSON 同时接受 JSON 字符串和数字
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*id = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = ID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("decode id %s: %w", string(data), err)
+	}
+	*id = ID(n.String())
+	return nil
+}
+
 // User GitCode 用户信息
 type User struct {
-	ID        interface{} `json:"id"` // GitCode API 可能返回字符串或数字
-	Login     string      `json:"login"`
-	FullName  string      `json:"full_name"`
-	Email     string      `json:"email"`
-	AvatarURL string      `json:"avatar_url"`
+	ID        ID     `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	FullName  string `json:"full_name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// displayName 返回用户的昵称，与登录名相同时视为未设置
+func (u *User) displayName() string {
+	for _, name := range []string{u.Name, u.FullName} {
+		if name = strings.TrimSpace(name); name != "" && name != u.Login {
+			return name
+		}
+	}
+	return ""
+}
+
+// Organization GitCode 组织信息
+type Organization struct {
+	ID          ID     `json:"id"`
+	Login       string `json:"login"`
+	Name        string `json:"name"`
+	AvatarURL   string `json:"avatar_url"`
+	Description string `json:"description"`
+	Public      *bool  `json:"public"` // 部分实例不返回，此时视为公开
+}
+
+// OrgMembership 用户在组织中的成员关系，role 为 admin 或 member；
+// 部分实例不返回 active，此时视为已激活
+type OrgMembership struct {
+	Active *bool  `json:"active"`
+	Role   string `json:"role"`
+}
+
+// CollaboratorPermission 用户在仓库中的权限，permission 为 admin、write、read 或 none
+type CollaboratorPermission struct {
+	Permission string `json:"permission"`
 }
 
 // Repository GitCode 仓库信息 (基于实际 API 响应)
@@ -180,6 +481,7 @@ type Repository struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Status      string `json:"status"`
+	AvatarURL   string `json:"avatar_url"`
 
 	// 命名空间
 	Namespace struct {
@@ -267,28 +569,77 @@ type Repository struct {
 
 // Branch GitCode 分支信息
 type Branch struct {
-	Name   string `json:"name"`
-	Commit struct {
-		ID string `json:"id"` // GitCode 使用 "id" 而不是 "sha"
+	Name      string       `json:"name"`
+	Commit    BranchCommit `json:"commit"`
+	Protected bool         `json:"protected"`
+}
+
+// ProtectedTag GitCode 受保护标签规则，名称可包含通配符
+type ProtectedTag struct {
+	Name string `json:"name"`
+}
+
+// BranchCommit 分支最新提交信息
+type BranchCommit struct {
+	ID string `json:"id"`
+}
+
+// UnmarshalJSON 兼容两种格式：单个分支接口返回 "id"，分支列表接口返回 "sha"
+func (c *BranchCommit) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID  string `json:"id"`
+		SHA string `json:"sha"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.ID = raw.ID
+	if c.ID == "" {
+		c.ID = raw.SHA
+	}
+	return nil
+}
+
+// Commit GitCode 提交信息
+type Commit struct {
+	SHA     string `json:"sha"`
+	HTMLURL string `json:"html_url"`
+	Commit  struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+			Date  string `json:"date"`
+		} `json:"author"`
 	} `json:"commit"`
 }
 
 // PullRequest GitCode Pull Request 信息
 type PullRequest struct {
-	ID     int64  `json:"id"`
-	Number int    `json:"number"`
-	Title  string `json:"title"`
-	State  string `json:"state"`
-	Head   struct {
-		Ref string `json:"ref"`
-		SHA string `json:"sha"`
+	ID             int64  `json:"id"`
+	Number         int    `json:"number"`
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+	State          string `json:"state"`
+	MergeCommitSHA string `json:"merge_commit_sha"` // 仅合并后返回
+	Head           struct {
+		Ref  string           `json:"ref"`
+		SHA  string           `json:"sha"`
+		Repo *PullRequestRepo `json:"repo"`
 	} `json:"head"`
 	Base struct {
-		Ref string `json:"ref"`
-		SHA string `json:"sha"`
+		Ref  string           `json:"ref"`
+		SHA  string           `json:"sha"`
+		Repo *PullRequestRepo `json:"repo"`
 	} `json:"base"`
 }
 
+// PullRequestRepo 合并请求源或目标分支所在的仓库
+type PullRequestRepo struct {
+	ID       int    `json:"id"`
+	FullName string `json:"full_name"`
+}
+
 // Hook GitCode Webhook 信息
 type Hook struct {
 	ID     int64    `json:"id"`
@@ -310,6 +661,102 @@ type TreeEntry struct {
 	Path string `json:"path"`
 	Mode string `json:"mode"`
 	MD5  string `json:"md5"`
+	Size int64  `json:"size"` // 部分接口不返回，此时为 0
+}
+
+// ContentEntry 文件或目录条目，由 contents 接口返回；列出目录时不包含 Content
+type ContentEntry struct {
+	Type        string `json:"type"` // "file", "dir"
+	Encoding    string `json:"encoding"`
+	Size        int64  `json:"size"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Content     string `json:"content"`
+	SHA         string `json:"sha"`
+	DownloadURL string `json:"download_url"`
+}
+
+// Data 返回解码后的文件内容
+func (e *ContentEntry) Data() ([]byte, error) {
+	if e.Encoding != "base64" {
+		return []byte(e.Content), nil
+	}
+	// 内容可能按行折断
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(e.Content, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("decode content of %s: %w", e.Path, err)
+	}
+	return data, nil
+}
+
+// PullRequestFile 合并请求修改的文件
+type PullRequestFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"` // 仅重命名时返回
+	Status           string `json:"status"`
+}
+
+// Compare 两个提交之间的差异
+type Compare struct {
+	Files []*PullRequestFile `json:"files"`
+}
+
+// Label 合并请求标签，webhook 中使用 title，API 中使用 name
+type Label struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Name  string `json:"name"`
+}
+
+// CommitComment GitCode 提交评论
+type CommitComment struct {
+	ID      ID     `json:"id"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CommitStatus GitCode 提交状态
+type CommitStatus struct {
+	ID          ID     `json:"id"`
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+}
+
+// CreateStatusRequest 创建提交状态请求
+type CreateStatusRequest struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context"`
+}
+
+// CreateCommentRequest 创建评论请求
+type CreateCommentRequest struct {
+	Body string `json:"body"`
+}
+
+// Issue GitCode Issue，number 可能是数字或字符串
+type Issue struct {
+	ID      ID     `json:"id"`
+	Number  ID     `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreateIssueRequest 创建 Issue 请求
+type CreateIssueRequest struct {
+	Repo  string `json:"repo"`
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+}
+
+// ReleaseUploadURL 发行版附件的预签名上传地址及需要携带的请求头
+type ReleaseUploadURL struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
 }
 
 // CreateHookRequest 创建 Webhook 请求
@@ -318,145 +765,277 @@ type CreateHookRequest struct {
 	ContentType string   `json:"content_type"`
 	Events      []string `json:"events"`
 	Active      bool     `json:"active"`
+	Password    string   `json:"password,omitempty"` // 作为 X-Gitcode-Token 请求头随 webhook 发送
 }
 
 // GitCode API 方法
 
 // GetUser 获取当前用户信息
 func (c *GitCodeClient) GetUser(ctx context.Context) (*User, error) {
-	var user User
-	tokenPreview := c.token
-	if len(tokenPreview) > 10 {
-		tokenPreview = tokenPreview[:10] + "..."
-	}
-	log.Printf("GitCode API: Getting user info with token: %s", tokenPreview)
-	err := c.get(ctx, "/user", &user)
+	// 请求本身由 loggingMiddleware 记录，这里不记录令牌
+	user, err := getJSON[*User](ctx, c, userEndpoint(), nil)
 	if err != nil {
-		log.Printf("GitCode API Error getting user: %v", err)
 		return nil, err
 	}
-	log.Printf("GitCode API Success: Got user %s", user.Login)
-	return &user, err
+	log.Trace().Str("login", user.Login).Msg("GitCode API: got authenticated user")
+	return user, nil
 }
 
-// GetUserRepos 获取用户仓库列表
-func (c *GitCodeClient) GetUserRepos(ctx context.Context, page, limit int) ([]*Repository, error) {
-	endpoint := fmt.Sprintf("/user/repos?page=%d&per_page=%d&sort=updated&direction=desc", page, limit)
-	log.Printf("Calling GitCode API endpoint: %s", endpoint)
-
-	var repos []*Repository
-	err := c.get(ctx, endpoint, &repos)
-	if err != nil {
-		log.Printf("GitCode API Error: %v", err)
-		return nil, err
+// GetUserRepos 获取用户仓库列表，affiliation 非空时只返回对应关系的仓库，
+// 取值为 owner、collaborator、organization_member 的组合
+func (c *GitCodeClient) GetUserRepos(ctx context.Context, affiliation []string, page, limit int) ([]*Repository, error) {
+	endpoint := userReposEndpoint()
+	query := c.api.pageQuery(page, limit)
+	query.Set("sort", "updated")
+	query.Set("direction", "desc")
+	if len(affiliation) > 0 {
+		query.Set("affiliation", strings.Join(affiliation, ","))
 	}
+	return getJSON[[]*Repository](ctx, c, endpoint, query)
+}
 
-	log.Printf("GitCode API Success: Got %d repos", len(repos))
-	for i, repo := range repos {
-		log.Printf("Repo %d: ID=%v, Name=%s, FullName=%s, Private=%v", i, repo.ID, repo.Name, repo.FullName, repo.Private)
-	}
-	return repos, nil
+// GetUserOrgs 获取当前用户所属的组织
+func (c *GitCodeClient) GetUserOrgs(ctx context.Context, page, limit int) ([]*Organization, error) {
+	return getJSON[[]*Organization](ctx, c, userOrgsEndpoint(), c.api.pageQuery(page, limit))
+}
+
+// GetOrg 获取组织信息，owner 不是组织时返回 404
+func (c *GitCodeClient) GetOrg(ctx context.Context, org string) (*Organization, error) {
+	return getJSON[*Organization](ctx, c, orgEndpoint(org), nil)
+}
+
+// GetUserByLogin 获取指定用户的公开信息
+func (c *GitCodeClient) GetUserByLogin(ctx context.Context, login string) (*User, error) {
+	return getJSON[*User](ctx, c, usersEndpoint(login), nil)
+}
+
+// GetOrgMembership 获取用户在组织中的成员关系，非成员时返回 404
+func (c *GitCodeClient) GetOrgMembership(ctx context.Context, org, username string) (*OrgMembership, error) {
+	return getJSON[*OrgMembership](ctx, c, orgMembershipEndpoint(org, username), nil)
 }
 
 // GetRepo 获取仓库信息
 func (c *GitCodeClient) GetRepo(ctx context.Context, owner, repo string) (*Repository, error) {
-	endpoint := fmt.Sprintf("/repos/%s/%s", owner, repo)
-	var repository Repository
-	err := c.get(ctx, endpoint, &repository)
-	return &repository, err
+	return getJSON[*Repository](ctx, c, repoEndpoint(owner, repo), nil)
 }
 
-// GetBranches 获取分支列表
-func (c *GitCodeClient) GetBranches(ctx context.Context, owner, repo string) ([]*Branch, error) {
-	endpoint := fmt.Sprintf("/repos/%s/%s/branches", owner, repo)
-	var branches []*Branch
-	err := c.get(ctx, endpoint, &branches)
-	return branches, err
+// GetCollaboratorPermission 获取用户在仓库中的权限，非协作者时返回 404
+func (c *GitCodeClient) GetCollaboratorPermission(ctx context.Context, owner, repo, username string) (*CollaboratorPermission, error) {
+	return getJSON[*CollaboratorPermission](ctx, c, collaboratorPermissionEndpoint(owner, repo, username), nil)
+}
+
+// GetRepoByID 通过仓库 ID 获取仓库信息，旧版本实例不支持时返回 404
+func (c *GitCodeClient) GetRepoByID(ctx context.Context, id string) (*Repository, error) {
+	return getJSON[*Repository](ctx, c, repositoryEndpoint(id), nil)
+}
+
+// GetBranches 获取分支列表（分页），search 非空时由服务端按分支名过滤
+func (c *GitCodeClient) GetBranches(ctx context.Context, owner, repo, search string, page, limit int) ([]*Branch, error) {
+	endpoint := branchesEndpoint(owner, repo)
+	query := c.api.pageQuery(page, limit)
+	if search != "" {
+		query.Set("search", search)
+	}
+	return getJSON[[]*Branch](ctx, c, endpoint, query)
 }
 
 // GetBranch 获取分支信息
 func (c *GitCodeClient) GetBranch(ctx context.Context, owner, repo, branch string) (*Branch, error) {
-	endpoint := fmt.Sprintf("/repos/%s/%s/branches/%s", owner, repo, branch)
-	var branchInfo Branch
-	err := c.get(ctx, endpoint, &branchInfo)
-	return &branchInfo, err
+	return getJSON[*Branch](ctx, c, branchEndpoint(owner, repo, branch), nil)
+}
+
+// GetProtectedTags 获取受保护标签规则列表（分页）
+func (c *GitCodeClient) GetProtectedTags(ctx context.Context, owner, repo string, page, limit int) ([]*ProtectedTag, error) {
+	return getJSON[[]*ProtectedTag](ctx, c, protectedTagsEndpoint(owner, repo), c.api.pageQuery(page, limit))
+}
+
+// GetCommit 获取单个提交信息
+func (c *GitCodeClient) GetCommit(ctx context.Context, owner, repo, sha string) (*Commit, error) {
+	return getJSON[*Commit](ctx, c, commitEndpoint(owner, repo, sha), nil)
+}
+
+// CreateCommitComment 在提交上发表评论
+func (c *GitCodeClient) CreateCommitComment(ctx context.Context, owner, repo, sha, body string) (*CommitComment, error) {
+	return postJSON[*CommitComment](ctx, c, commitCommentsEndpoint(owner, repo, sha), &CreateCommentRequest{Body: body})
+}
+
+// CreateCommitStatus 设置提交状态，相同 context 的状态会被覆盖
+func (c *GitCodeClient) CreateCommitStatus(ctx context.Context, owner, repo, sha string, status *CreateStatusRequest) (*CommitStatus, error) {
+	return postJSON[*CommitStatus](ctx, c, commitStatusesEndpoint(owner, repo, sha), status)
+}
+
+// GetIssues 获取仓库的 Issue 列表，state 为 open、closed 或 all
+func (c *GitCodeClient) GetIssues(ctx context.Context, owner, repo, state string, page, limit int) ([]*Issue, error) {
+	query := c.api.pageQuery(page, limit)
+	query.Set("state", state)
+	return getJSON[[]*Issue](ctx, c, issuesEndpoint(owner, repo), query)
+}
+
+// CreateIssue 创建 Issue
+func (c *GitCodeClient) CreateIssue(ctx context.Context, owner, repo, title, body string) (*Issue, error) {
+	return postJSON[*Issue](ctx, c, createIssueEndpoint(owner), &CreateIssueRequest{Repo: repo, Title: title, Body: body})
+}
+
+// CreateIssueComment 在 Issue 下发表评论
+func (c *GitCodeClient) CreateIssueComment(ctx context.Context, owner, repo, number, body string) (*CommitComment, error) {
+	return postJSON[*CommitComment](ctx, c, issueCommentsEndpoint(owner, repo, number), &CreateCommentRequest{Body: body})
 }
 
 // GetPullRequests 获取 PR 列表
-func (c *GitCodeClient) GetPullRequests(ctx context.Context, owner, repo string) ([]*PullRequest, error) {
-	endpoint := fmt.Sprintf("/repos/%s/%s/pulls", owner, repo)
-	var prs []*PullRequest
-	err := c.get(ctx, endpoint, &prs)
-	return prs, err
+func (c *GitCodeClient) GetPullRequests(ctx context.Context, owner, repo string, page, limit int) ([]*PullRequest, error) {
+	return getJSON[[]*PullRequest](ctx, c, pullsEndpoint(owner, repo), c.api.pageQuery(page, limit))
+}
+
+// GetPullRequest 获取单个合并请求
+func (c *GitCodeClient) GetPullRequest(ctx context.Context, owner, repo string, number int64) (*PullRequest, error) {
+	return getJSON[*PullRequest](ctx, c, pullEndpoint(owner, repo, number), nil)
+}
+
+// GetPullRequestFiles 获取合并请求修改的文件列表
+func (c *GitCodeClient) GetPullRequestFiles(ctx context.Context, owner, repo string, number int64) ([]*PullRequestFile, error) {
+	return getJSON[[]*PullRequestFile](ctx, c, pullFilesEndpoint(owner, repo, number), nil)
+}
+
+// CompareCommits 比较 base 和 head 两个提交，返回 head 相对 base 修改的文件
+func (c *GitCodeClient) CompareCommits(ctx context.Context, owner, repo, base, head string) (*Compare, error) {
+	return getJSON[*Compare](ctx, c, compareEndpoint(owner, repo, base, head), nil)
+}
+
+// GetPullRequestLabels 获取合并请求当前的标签
+func (c *GitCodeClient) GetPullRequestLabels(ctx context.Context, owner, repo string, number int64) ([]*Label, error) {
+	return getJSON[[]*Label](ctx, c, pullLabelsEndpoint(owner, repo, number), nil)
 }
 
 // GetFileContent 获取文件内容
 func (c *GitCodeClient) GetFileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
 	// GitCode 使用不同的端点获取文件内容
-	endpoint := fmt.Sprintf("/repos/%s/%s/raw/%s", owner, repo, path)
+	endpoint := rawFileEndpoint(owner, repo, path)
+	query := url.Values{}
 	if ref != "" {
-		endpoint += "?ref=" + url.QueryEscape(ref)
+		query.Set("ref", ref)
 	}
 
-	resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
+	_, body, err := c.fetch(ctx, endpoint, query)
+	return body, err
+}
+
+// GetContents 获取文件内容及其大小、blob SHA 和编码
+func (c *GitCodeClient) GetContents(ctx context.Context, owner, repo, path, ref string) (*ContentEntry, error) {
+	query := url.Values{}
+	if ref != "" {
+		query.Set("ref", ref)
+	}
+	return getJSON[*ContentEntry](ctx, c, contentsEndpoint(owner, repo, path), query)
+}
+
+// ListContents 列出目录中的文件和子目录及其大小和 blob SHA，不下载文件内容
+func (c *GitCodeClient) ListContents(ctx context.Context, owner, repo, dir, ref string) ([]*ContentEntry, error) {
+	query := url.Values{}
+	if ref != "" {
+		query.Set("ref", ref)
+	}
+	return getJSON[[]*ContentEntry](ctx, c, contentsEndpoint(owner, repo, dir), query)
+}
+
+// GetArchive 下载 ref 处仓库内容的 tar.gz 归档，调用方负责关闭返回的数据流
+func (c *GitCodeClient) GetArchive(ctx context.Context, owner, repo, ref string) (io.ReadCloser, error) {
+	query := url.Values{}
+	if ref != "" {
+		query.Set("ref", ref)
+	}
+	resp, err := c.makeRequest(ctx, http.MethodGet, archiveEndpoint(owner, repo), query, nil)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp.Body, nil
+}
+
+// GetReleaseUploadURL 获取发行版附件的预签名上传地址
+func (c *GitCodeClient) GetReleaseUploadURL(ctx context.Context, owner, repo, tag, fileName string) (*ReleaseUploadURL, error) {
+	query := url.Values{"file_name": []string{fileName}}
+	return getJSON[*ReleaseUploadURL](ctx, c, releaseUploadURLEndpoint(owner, repo, tag), query)
+}
+
+// UploadReleaseAsset 将附件内容 PUT 到预签名地址，网络错误和 5xx 响应会重试
+func (c *GitCodeClient) UploadReleaseAsset(ctx context.Context, upload *ReleaseUploadURL, content io.ReadSeeker, size int64) error {
+	for attempt := 0; ; attempt++ {
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("rewind release asset: %w", err)
+		}
+
+		err := c.putReleaseAsset(ctx, upload, content, size)
+		var apiErr *APIError
+		retryable := isRetryableError(err) || (errors.As(err, &apiErr) && apiErr.StatusCode >= http.StatusInternalServerError)
+		if err == nil || attempt >= c.maxRetries || !retryable {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay(c.retryBackoff, attempt)):
+		}
+	}
+}
+
+func (c *GitCodeClient) putReleaseAsset(ctx context.Context, upload *ReleaseUploadURL, content io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, upload.URL, io.NopCloser(content))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.ContentLength = size
+	for key, value := range upload.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.uploadClient.Do(req)
+	if err != nil {
+		return redactError(err)
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+		return newAPIError(resp)
 	}
-
-	return io.ReadAll(resp.Body)
+	return nil
 }
 
 // GetTree 获取目录树结构 (基于 GitCode 官方文档)
 // https://docs.gitcode.com/docs/apis/get-api-v-5-repos-owner-repo-git-trees-sha
 func (c *GitCodeClient) GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*Tree, error) {
 	// 根据 GitCode API 文档：https://api.gitcode.com/api/v5/repos/:owner/:repo/git/trees/:sha
-	endpoint := fmt.Sprintf("/repos/%s/%s/git/trees/%s", owner, repo, sha)
+	endpoint := treeEndpoint(owner, repo, sha)
 
 	// 添加查询参数
-	params := make([]string, 0)
+	query := url.Values{}
 	if recursive {
-		params = append(params, "recursive=1")
+		query.Set("recursive", "1")
 	}
 
-	if len(params) > 0 {
-		endpoint += "?" + strings.Join(params, "&")
-	}
-
-	var tree Tree
-	err := c.get(ctx, endpoint, &tree)
-	if err != nil {
-		log.Printf("GitCode API: GetTree failed for %s/%s at %s: %v", owner, repo, sha, err)
-		return nil, err
-	}
-
-	log.Printf("GitCode API: GetTree success for %s/%s at %s, got %d entries", owner, repo, sha, len(tree.Tree))
-	return &tree, nil
+	return getJSON[*Tree](ctx, c, endpoint, query)
 }
 
 // CreateHook 创建 Webhook
 func (c *GitCodeClient) CreateHook(ctx context.Context, owner, repo string, hook *CreateHookRequest) (*Hook, error) {
-	endpoint := fmt.Sprintf("/repos/%s/%s/hooks", owner, repo)
-	var result Hook
-	err := c.post(ctx, endpoint, hook, &result)
-	return &result, err
+	return postJSON[*Hook](ctx, c, hooksEndpoint(owner, repo), hook)
+}
+
+// UpdateHook 修改 Webhook 的地址、事件和密码
+func (c *GitCodeClient) UpdateHook(ctx context.Context, owner, repo string, hookID int64, hook *CreateHookRequest) (*Hook, error) {
+	return patchJSON[*Hook](ctx, c, hookEndpoint(owner, repo, hookID), hook)
 }
 
 // GetHooks 获取 Webhook 列表
-func (c *GitCodeClient) GetHooks(ctx context.Context, owner, repo string) ([]*Hook, error) {
-	endpoint := fmt.Sprintf("/repos/%s/%s/hooks", owner, repo)
-	var hooks []*Hook
-	err := c.get(ctx, endpoint, &hooks)
-	return hooks, err
+func (c *GitCodeClient) GetHooks(ctx context.Context, owner, repo string, page, limit int) ([]*Hook, error) {
+	return getJSON[[]*Hook](ctx, c, hooksEndpoint(owner, repo), c.api.pageQuery(page, limit))
 }
 
 // DeleteHook 删除 Webhook
 func (c *GitCodeClient) DeleteHook(ctx context.Context, owner, repo string, hookID int64) error {
-	endpoint := fmt.Sprintf("/repos/%s/%s/hooks/%d", owner, repo, hookID)
+	endpoint := hookEndpoint(owner, repo, hookID)
 	return c.delete(ctx, endpoint)
 }
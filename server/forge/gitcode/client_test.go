@@ -16,9 +16,19 @@ package gitcode
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/singleflight"
 )
 
 func TestGitCodeClient_Connection(t *testing.T) {
@@ -59,7 +69,7 @@ func TestGitCodeClient_WithToken(t *testing.T) {
 
 		t.Logf("✅ 用户信息: %s (%s)", user.Login, user.FullName)
 
-		if user.ID == 0 {
+		if user.ID == "" {
 			t.Error("用户 ID 不应该为 0")
 		}
 		if user.Login == "" {
@@ -68,7 +78,7 @@ func TestGitCodeClient_WithToken(t *testing.T) {
 	})
 
 	t.Run("GetUserRepos", func(t *testing.T) {
-		repos, err := client.GetUserRepos(ctx, 1, 5)
+		repos, err := client.GetUserRepos(ctx, nil, 1, 5)
 		if err != nil {
 			t.Errorf("获取用户仓库失败: %v", err)
 			return
@@ -146,3 +156,164 @@ func BenchmarkGitCodeClient_GetUser(b *testing.B) {
 		}
 	}
 }
+
+func TestBranchCommitUnmarshal(t *testing.T) {
+	var branches []*Branch
+	err := json.Unmarshal([]byte(`[
+		{"name": "main", "commit": {"id": "6a6d29ba8df340a5df8c18bb08ab4ee6626476fd"}},
+		{"name": "dev", "commit": {"sha": "e0f538eaf7ded5a29cac7068497f455300b3a5ae"}}
+	]`), &branches)
+	assert.NoError(t, err)
+	assert.Len(t, branches, 2)
+	assert.Equal(t, "6a6d29ba8df340a5df8c18bb08ab4ee6626476fd", branches[0].Commit.ID)
+	assert.Equal(t, "e0f538eaf7ded5a29cac7068497f455300b3a5ae", branches[1].Commit.ID)
+}
+
+func TestContentEntryData(t *testing.T) {
+	var entry ContentEntry
+	err := json.Unmarshal([]byte(`{"type":"file","encoding":"base64","size":12,"name":"a.yaml","path":".woodpecker/a.yaml",
+		"content":"c3RlcHM6\nIFtdCg==","sha":"abc"}`), &entry)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), entry.Size)
+	data, err := entry.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, "steps: []\n", string(data))
+
+	entry = ContentEntry{Path: "a.yaml", Content: "steps: []"}
+	data, err = entry.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, "steps: []", string(data))
+
+	entry = ContentEntry{Path: "a.yaml", Encoding: "base64", Content: "%%%"}
+	_, err = entry.Data()
+	assert.Error(t, err)
+}
+
+func TestBuildURL(t *testing.T) {
+	client := NewGitCodeClient("secret&token", false)
+
+	reqURL, err := client.buildURL("/repos/owner/repo/raw/"+escapePath("ci config/a#b.yaml"), url.Values{"ref": []string{"feature/x&y"}})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultAPI+"/repos/owner/repo/raw/ci%20config/a%23b.yaml?ref=feature%2Fx%26y", reqURL)
+
+	reqURL, err = NewGitCodeClient("", false).buildURL("/user/repos", pageQuery(2, 50))
+	assert.NoError(t, err)
+	assert.Equal(t, defaultAPI+"/user/repos?page=2&per_page=50", reqURL)
+}
+
+func TestIDUnmarshal(t *testing.T) {
+	var users []User
+	err := json.Unmarshal([]byte(`[{"id": 143790}, {"id": "64f1a2"}, {"id": null}]`), &users)
+	assert.NoError(t, err)
+	assert.Equal(t, ID("143790"), users[0].ID)
+	assert.Equal(t, ID("64f1a2"), users[1].ID)
+	assert.Equal(t, ID(""), users[2].ID)
+}
+
+func TestSingleflightGet(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	stub := func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			calls.Add(1)
+			<-release
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"name":"main"}`))}, nil
+		})
+	}
+	client := NewGitCodeClient("token", false, WithMiddleware(stub), WithSingleflight(&singleflight.Group{}))
+
+	var wg sync.WaitGroup
+	results := make([]*Branch, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = client.GetBranch(context.Background(), "owner", "repo", "main")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, calls.Load())
+	for _, branch := range results {
+		if assert.NotNil(t, branch) {
+			assert.Equal(t, "main", branch.Name)
+		}
+	}
+}
+
+func TestSingleflightGetCancelledCaller(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var requestErr error
+	stub := func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			close(started)
+			<-release
+			requestErr = req.Context().Err()
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"name":"main"}`))}, nil
+		})
+	}
+	client := NewGitCodeClient("token", false, WithMiddleware(stub), WithSingleflight(&singleflight.Group{}))
+
+	// 第一个调用方发出请求后被取消
+	ctx, cancel := context.WithCancel(t.Context())
+	first := make(chan error)
+	go func() {
+		_, err := client.GetBranch(ctx, "owner", "repo", "main")
+		first <- err
+	}()
+	<-started
+
+	second := make(chan *Branch)
+	go func() {
+		branch, _ := client.GetBranch(t.Context(), "owner", "repo", "main")
+		second <- branch
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+
+	// 其他调用方仍然拿到共享请求的结果
+	close(release)
+	if branch := <-second; assert.NotNil(t, branch) {
+		assert.Equal(t, "main", branch.Name)
+	}
+	assert.NoError(t, requestErr)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestUploadReleaseAsset(t *testing.T) {
+	var bodies []string
+	stub := func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			assert.Empty(t, req.Header.Get("Authorization"))
+			assert.Empty(t, req.URL.Query().Get("access_token"))
+			assert.Equal(t, "abc", req.Header.Get("X-Signature"))
+			body, _ := io.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+
+			status := http.StatusOK
+			if len(bodies) == 1 {
+				status = http.StatusServiceUnavailable
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+		})
+	}
+	client := NewGitCodeClient("token", false, WithMiddleware(stub))
+
+	upload := &ReleaseUploadURL{URL: "https://obs.example.com/asset.tar.gz?sig=1", Headers: map[string]string{"X-Signature": "abc"}}
+	err := client.UploadReleaseAsset(context.Background(), upload, strings.NewReader("payload"), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"payload", "payload"}, bodies)
+}
+
+func TestUserDisplayName(t *testing.T) {
+	assert.Equal(t, "Alice Liddell", (&User{Login: "alice", Name: " Alice Liddell "}).displayName())
+	assert.Equal(t, "Alice", (&User{Login: "alice", Name: "alice", FullName: "Alice"}).displayName())
+	assert.Empty(t, (&User{Login: "alice", Name: "alice"}).displayName())
+}
@@ -0,0 +1,163 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// 合并请求评论中可以触发流水线的命令
+const (
+	commandRetry   = "/retry"
+	commandRebuild = "/rebuild"
+	commandDeploy  = "/deploy"
+)
+
+// noteableMergeRequest 是合并请求评论的 noteable_type
+const noteableMergeRequest = "MergeRequest"
+
+// commentCommand 是从评论中解析出的命令，args 为命令后的参数
+type commentCommand struct {
+	name string
+	args []string
+}
+
+// parseCommentCommand 解析评论第一行中的命令，评论不以已知命令开头时返回 false
+func parseCommentCommand(note string) (commentCommand, bool) {
+	line, _, _ := strings.Cut(strings.TrimSpace(note), "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return commentCommand{}, false
+	}
+
+	cmd := commentCommand{name: strings.ToLower(fields[0]), args: fields[1:]}
+	switch cmd.name {
+	case commandRetry, commandRebuild:
+		return cmd, true
+	case commandDeploy:
+		return cmd, len(cmd.args) > 0
+	}
+	return commentCommand{}, false
+}
+
+// parseNoteHook parses a note hook and returns the Repo and Pipeline details of the
+// command in a merge request comment. Comments without a command are ignored.
+func parseNoteHook(links linkBuilder, payload io.Reader) (*model.Repo, *model.Pipeline, error) {
+	note := new(noteHook)
+	if err := json.NewDecoder(payload).Decode(note); err != nil {
+		return nil, nil, err
+	}
+
+	if note.ObjectAttributes.NoteableType != noteableMergeRequest {
+		log.Debug().Msgf("ignoring comment on %s", note.ObjectAttributes.NoteableType)
+		return nil, nil, nil
+	}
+	cmd, ok := parseCommentCommand(note.ObjectAttributes.Note)
+	if !ok {
+		return nil, nil, nil
+	}
+	if note.MergeRequest.IID == 0 || note.MergeRequest.LastCommit.ID == "" {
+		return nil, nil, fmt.Errorf("comment %d does not contain merge request info", note.ObjectAttributes.ID)
+	}
+	if state := note.MergeRequest.State; state == "merged" || state == "closed" {
+		log.Debug().Msgf("ignoring %s on %s merge request !%d", cmd.name, state, note.MergeRequest.IID)
+		return nil, nil, nil
+	}
+
+	repo := repoFromProject(links, cmp.Or(note.ProjectID, note.Project.ID), &note.Project)
+	pipeline := pipelineFromNote(links, note, cmd)
+	// 部署流水线不经过复刻仓库的审批，且会按目标分支获取受保护的密钥，不能运行复刻仓库的代码
+	if pipeline.Event == model.EventDeploy && pipeline.FromFork {
+		return nil, nil, &types.ErrIgnoreEvent{Event: hookNote, Reason: fmt.Sprintf("merge request !%d is from a fork and can't be deployed", note.MergeRequest.IID)}
+	}
+	return repo, pipeline, nil
+}
+
+// pipelineFromNote 由合并请求评论中的命令创建流水线：/retry 和 /rebuild 重新运行合并请求流水线，
+// /deploy <env> 将合并请求的最新提交部署到 env
+func pipelineFromNote(links linkBuilder, hook *noteHook, cmd commentCommand) *model.Pipeline {
+	mr := hook.MergeRequest
+	pipeline := &model.Pipeline{
+		Event:     model.EventPull,
+		Commit:    mr.LastCommit.ID,
+		Ref:       fmt.Sprintf("refs/pull/%d/head", mr.IID),
+		Branch:    mr.TargetBranch,
+		Refspec:   fmt.Sprintf("%s:%s", mr.SourceBranch, mr.TargetBranch),
+		ForgeURL:  orDefault(mr.URL, links.mergeRequest(hook.Project.PathWithNamespace, mr.IID)),
+		Title:     mr.Title,
+		Message:   mr.Title,
+		Avatar:    links.avatarOr(orDefault(hook.User.Email, hook.User.Username), fixMalformedAvatar(hook.User.AvatarURL)),
+		Author:    hook.User.Username,
+		Sender:    hook.User.Username,
+		Email:     hook.User.Email,
+		Timestamp: time.Now().UTC().Unix(),
+		FromFork: isForkMergeRequest(
+			mergeRequestProject{id: cmp.Or(mr.Source.ID, mr.SourceProjectID), path: mr.Source.PathWithNamespace},
+			mergeRequestProject{id: cmp.Or(mr.Target.ID, mr.TargetProjectID, hook.ProjectID, hook.Project.ID), path: cmp.Or(mr.Target.PathWithNamespace, hook.Project.PathWithNamespace)},
+		),
+		// 与其他 forge 一致，事件原因记录触发流水线的命令
+		EventReason: []string{strings.TrimPrefix(cmd.name, "/")},
+	}
+
+	if cmd.name == commandDeploy {
+		pipeline.Event = model.EventDeploy
+		pipeline.DeployTo = cmd.args[0]
+		if len(cmd.args) > 1 {
+			pipeline.DeployTask = cmd.args[1]
+		}
+	}
+	return pipeline
+}
+
+// collaboratorCanTrigger 是可以通过评论触发流水线的仓库权限
+var collaboratorCanTrigger = []string{"admin", "maintain", "write", "push"}
+
+// canTrigger 判断权限是否允许通过评论触发流水线
+func (p *CollaboratorPermission) canTrigger() bool {
+	return slices.Contains(collaboratorCanTrigger, strings.ToLower(p.Permission))
+}
+
+// verifyCommenter 校验评论者拥有仓库的写权限，只读用户和外部贡献者的评论不能触发流水线
+func (c *GitCode) verifyCommenter(ctx context.Context, repo *model.Repo, pipeline *model.Pipeline) error {
+	client, stored, err := c.repoOwnerClient(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	perm, err := client.GetCollaboratorPermission(ctx, stored.Owner, stored.Name, pipeline.Sender)
+	if isStatus(err, http.StatusNotFound) {
+		return &types.ErrIgnoreEvent{Event: hookNote, Reason: fmt.Sprintf("%s is not a collaborator of %s", pipeline.Sender, stored.FullName)}
+	}
+	if err != nil {
+		return err
+	}
+	if !perm.canTrigger() {
+		return &types.ErrIgnoreEvent{Event: hookNote, Reason: fmt.Sprintf("%s has no write permission on %s", pipeline.Sender, stored.FullName)}
+	}
+	return nil
+}
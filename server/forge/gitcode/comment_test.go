@@ -0,0 +1,134 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func TestParseCommentCommand(t *testing.T) {
+	cmd, ok := parseCommentCommand("  /retry\nplease")
+	assert.True(t, ok)
+	assert.Equal(t, commandRetry, cmd.name)
+
+	cmd, ok = parseCommentCommand("/Deploy production migrate")
+	assert.True(t, ok)
+	assert.Equal(t, commandDeploy, cmd.name)
+	assert.Equal(t, []string{"production", "migrate"}, cmd.args)
+
+	for _, note := range []string{"", "LGTM", "/deploy", "please /retry", "/retrying"} {
+		_, ok := parseCommentCommand(note)
+		assert.False(t, ok, note)
+	}
+}
+
+func notePayload(noteableType, note, state string) string {
+	return `{
+		"object_kind": "note",
+		"user": {"username": "jetsung", "email": "i@jetsung.com"},
+		"project_id": 7720285,
+		"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci", "web_url": "https://gitcode.com/jetsung/testci"},
+		"object_attributes": {"id": 9, "note": "` + note + `", "noteable_type": "` + noteableType + `"},
+		"merge_request": {"id": 1, "iid": 3, "title": "fix", "state": "` + state + `", "source_branch": "dev", "target_branch": "main",
+			"last_commit": {"id": "abc"}, "source": {"id": 7720285}, "target": {"id": 7720285}}
+	}`
+}
+
+func TestParseNoteHook(t *testing.T) {
+	parse := func(payload string) (*model.Repo, *model.Pipeline, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(payload))
+		req.Header.Set(hookEvent, hookNote)
+		return parseHook(req, newLinkBuilder(defaultURL))
+	}
+
+	repo, pipeline, err := parse(notePayload("MergeRequest", "/retry", "opened"))
+	assert.NoError(t, err)
+	assert.Equal(t, "7720285", string(repo.ForgeRemoteID))
+	assert.Equal(t, model.EventPull, pipeline.Event)
+	assert.Equal(t, []string{"retry"}, pipeline.EventReason)
+	assert.Equal(t, "abc", pipeline.Commit)
+	assert.Equal(t, "refs/pull/3/head", pipeline.Ref)
+	assert.Equal(t, "dev:main", pipeline.Refspec)
+	assert.Equal(t, "jetsung", pipeline.Sender)
+	assert.False(t, pipeline.FromFork)
+
+	_, pipeline, err = parse(notePayload("MergeRequest", "/deploy staging", "opened"))
+	assert.NoError(t, err)
+	assert.Equal(t, model.EventDeploy, pipeline.Event)
+	assert.Equal(t, "staging", pipeline.DeployTo)
+
+	fork := strings.Replace(notePayload("MergeRequest", "/deploy staging", "opened"), `"source": {"id": 7720285}`, `"source": {"id": 1234}`, 1)
+	_, pipeline, err = parse(fork)
+	assert.ErrorIs(t, err, &forge_types.ErrIgnoreEvent{})
+	assert.Nil(t, pipeline)
+
+	_, pipeline, err = parse(strings.Replace(fork, "/deploy staging", "/retry", 1))
+	assert.NoError(t, err)
+	assert.Equal(t, model.EventPull, pipeline.Event)
+	assert.True(t, pipeline.FromFork)
+
+	for _, payload := range []string{
+		notePayload("Issue", "/retry", "opened"),
+		notePayload("MergeRequest", "looks good", "opened"),
+		notePayload("MergeRequest", "/retry", "merged"),
+	} {
+		repo, pipeline, err := parse(payload)
+		assert.NoError(t, err)
+		assert.Nil(t, repo)
+		assert.Nil(t, pipeline)
+	}
+}
+
+func TestHookChecksCommenterPermission(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "7720285", FullName: "jetsung/testci", Owner: "jetsung", Name: "testci", UserID: 1, Hash: "hash"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("7720285"), "jetsung/testci").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token", Expiry: 1 << 40}, nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	permission := "write"
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		if req.URL.Path == "/api/v5/repos/jetsung/testci/collaborators/jetsung/permission" {
+			return http.StatusOK, `{"permission":"` + permission + `"}`
+		}
+		return http.StatusOK, `[]`
+	})
+	hook := func() (*model.Pipeline, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(notePayload("MergeRequest", "/rebuild", "opened")))
+		req.Header.Set(hookEvent, hookNote)
+		req.Header.Set(hookToken, webhookSecret(repo))
+		_, pipeline, err := c.Hook(ctx, req)
+		return pipeline, err
+	}
+
+	pipeline, err := hook()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rebuild"}, pipeline.EventReason)
+
+	permission = "read"
+	_, err = hook()
+	assert.ErrorIs(t, err, &forge_types.ErrIgnoreEvent{})
+	assert.ErrorContains(t, err, "no write permission")
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	shared_utils "go.woodpecker-ci.org/woodpecker/v3/shared/utils"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// isTruncatedPush 判断推送事件是否只携带了部分提交。新建分支时没有可比较的推送前提交
+func isTruncatedPush(push *pushHook) bool {
+	return push.TotalCommitsCount > len(push.Commits) && push.Before != "" && push.Before != emptyCommit
+}
+
+// completePushChangedFiles 在推送事件的提交列表被截断时，比较推送前后的提交得到完整的变更文件，
+// 比较失败时保留 webhook 中已有的变更文件。payload 为 webhook 的原始请求体
+func (c *GitCode) completePushChangedFiles(ctx context.Context, repo *model.Repo, pipeline *model.Pipeline, payload []byte) {
+	push, err := parsePush(bytes.NewReader(payload))
+	if err != nil || !isTruncatedPush(push) {
+		return
+	}
+
+	client, stored, err := c.repoOwnerClient(ctx, repo)
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not compare push to %s", repo.FullName)
+		return
+	}
+	compare, err := client.CompareCommits(ctx, stored.Owner, stored.Name, push.Before, push.After)
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not compare %s...%s of %s, changed files are limited to the %d commits in the webhook",
+			push.Before, push.After, repo.FullName, len(push.Commits))
+		return
+	}
+
+	changed := make([]string, 0, len(compare.Files))
+	for _, file := range compare.Files {
+		if file.PreviousFilename != "" {
+			changed = append(changed, file.PreviousFilename)
+		}
+		changed = append(changed, file.Filename)
+	}
+	pipeline.ChangedFiles = shared_utils.Deduplicate(changed)
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func TestCompletePushChangedFiles(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Owner: "owner", Name: "repo", UserID: 1}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("1"), "owner/repo").Return(repo, nil).Maybe()
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token", Expiry: 1 << 40}, nil).Maybe()
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	status := http.StatusOK
+	requests := 0
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		requests++
+		assert.Equal(t, "/api/v5/repos/owner/repo/compare/aaa...ccc", req.URL.Path)
+		return status, `{"files":[{"filename":"a.go"},{"filename":"new.go","previous_filename":"old.go"},{"filename":"b.go"}]}`
+	})
+
+	truncated := []byte(`{"before":"aaa","after":"ccc","total_commits_count":30,"commits":[{"id":"ccc","modified":["b.go"]}]}`)
+	pipeline := &model.Pipeline{Event: model.EventPush, ChangedFiles: []string{"b.go"}}
+	c.completePushChangedFiles(ctx, repo, pipeline, truncated)
+	assert.Equal(t, []string{"a.go", "old.go", "new.go", "b.go"}, pipeline.ChangedFiles)
+	assert.Equal(t, 1, requests)
+
+	// 提交完整或者新建分支时不需要比较
+	for _, payload := range []string{
+		`{"before":"aaa","after":"ccc","total_commits_count":1,"commits":[{"id":"ccc","modified":["b.go"]}]}`,
+		`{"before":"` + emptyCommit + `","after":"ccc","total_commits_count":30,"commits":[{"id":"ccc","modified":["b.go"]}]}`,
+	} {
+		pipeline = &model.Pipeline{Event: model.EventPush, ChangedFiles: []string{"b.go"}}
+		c.completePushChangedFiles(ctx, repo, pipeline, []byte(payload))
+		assert.Equal(t, []string{"b.go"}, pipeline.ChangedFiles)
+	}
+	assert.Equal(t, 1, requests)
+
+	// 比较失败时保留 webhook 中的变更文件
+	status = http.StatusInternalServerError
+	pipeline = &model.Pipeline{Event: model.EventPush, ChangedFiles: []string{"b.go"}}
+	c.completePushChangedFiles(ctx, repo, pipeline, truncated)
+	assert.Equal(t, []string{"b.go"}, pipeline.ChangedFiles)
+}
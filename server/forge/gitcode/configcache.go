@@ -0,0 +1,116 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// configCacheTTL 是缓存的配置文件的有效期。提交内容不可变，过期仅用于释放内存
+const configCacheTTL = time.Hour
+
+// configCache 按仓库、提交 SHA 和路径缓存流水线配置文件及目录树，
+// 重启工作流或重新计算状态时无需再次下载相同的内容
+type configCache struct {
+	files *ttlcache.Cache[string, []byte]
+	trees *ttlcache.Cache[string, []TreeEntry]
+}
+
+// newConfigCache 创建最多保存 size 个条目的缓存，size 不大于 0 时返回 nil，即不缓存
+func newConfigCache(size int) *configCache {
+	if size <= 0 {
+		return nil
+	}
+	return &configCache{
+		files: ttlcache.New(
+			ttlcache.WithTTL[string, []byte](configCacheTTL),
+			ttlcache.WithCapacity[string, []byte](uint64(size)),
+			ttlcache.WithDisableTouchOnHit[string, []byte](),
+		),
+		trees: ttlcache.New(
+			ttlcache.WithTTL[string, []TreeEntry](configCacheTTL),
+			ttlcache.WithCapacity[string, []TreeEntry](uint64(size)),
+			ttlcache.WithDisableTouchOnHit[string, []TreeEntry](),
+		),
+	}
+}
+
+// configCacheKey 返回缓存键。只有完整的提交 SHA 指向不可变的内容，分支名等引用不缓存
+func configCacheKey(r *model.Repo, ref, path string) (string, bool) {
+	if !isCommitSHA(ref) {
+		return "", false
+	}
+	repo := string(r.ForgeRemoteID)
+	if repo == "" {
+		repo = r.Owner + "/" + r.Name
+	}
+	return repo + "@" + ref + ":" + strings.TrimPrefix(path, "/"), true
+}
+
+// isCommitSHA 判断引用是否为完整的 SHA-1 或 SHA-256 提交哈希
+func isCommitSHA(ref string) bool {
+	if len(ref) != 40 && len(ref) != 64 {
+		return false
+	}
+	for _, ch := range ref {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// file 返回缓存的文件内容
+func (cc *configCache) file(r *model.Repo, ref, path string) ([]byte, bool) {
+	key, ok := configCacheKey(r, ref, path)
+	if cc == nil || !ok {
+		return nil, false
+	}
+	if item := cc.files.Get(key); item != nil {
+		return item.Value(), true
+	}
+	return nil, false
+}
+
+// setFile 缓存文件内容
+func (cc *configCache) setFile(r *model.Repo, ref, path string, data []byte) {
+	if key, ok := configCacheKey(r, ref, path); cc != nil && ok {
+		cc.files.Set(key, data, ttlcache.DefaultTTL)
+	}
+}
+
+// tree 返回缓存的目录树
+func (cc *configCache) tree(r *model.Repo, ref string) ([]TreeEntry, bool) {
+	key, ok := configCacheKey(r, ref, "")
+	if cc == nil || !ok {
+		return nil, false
+	}
+	if item := cc.trees.Get(key); item != nil {
+		return item.Value(), true
+	}
+	return nil, false
+}
+
+// setTree 缓存目录树
+func (cc *configCache) setTree(r *model.Repo, ref string, entries []TreeEntry) {
+	if key, ok := configCacheKey(r, ref, ""); cc != nil && ok {
+		cc.trees.Set(key, entries, ttlcache.DefaultTTL)
+	}
+}
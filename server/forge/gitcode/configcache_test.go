@@ -0,0 +1,67 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+func TestConfigCache(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	requests := map[string]int{}
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		requests[req.URL.Path]++
+		if strings.Contains(req.URL.Path, "/git/trees/") {
+			return http.StatusOK, `{"tree":[{"path":".woodpecker/a.yaml","type":"blob"},{"path":".woodpecker/b.yaml","type":"blob"}]}`
+		}
+		return http.StatusOK, "steps: []"
+	})
+	c.configCache = newConfigCache(10)
+	user := &model.User{AccessToken: "token"}
+	repo := &model.Repo{ForgeRemoteID: "1", Owner: "owner", Name: "repo"}
+
+	for range 2 {
+		data, err := c.File(t.Context(), user, repo, &model.Pipeline{Commit: sha}, ".woodpecker.yaml")
+		assert.NoError(t, err)
+		assert.Equal(t, "steps: []", string(data))
+
+		files, err := c.Dir(t.Context(), user, repo, &model.Pipeline{Commit: sha}, ".woodpecker")
+		assert.NoError(t, err)
+		assert.Len(t, files, 2)
+	}
+	assert.Equal(t, 1, requests["/api/v5/repos/owner/repo/raw/.woodpecker.yaml"])
+	assert.Equal(t, 1, requests["/api/v5/repos/owner/repo/git/trees/"+sha])
+	assert.Equal(t, 1, requests["/api/v5/repos/owner/repo/raw/.woodpecker/a.yaml"])
+
+	// 分支名指向的内容会变化，不缓存
+	for range 2 {
+		_, err := c.File(t.Context(), user, repo, &model.Pipeline{Commit: "main"}, ".woodpecker.yaml")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, requests["/api/v5/repos/owner/repo/raw/.woodpecker.yaml"])
+}
+
+func TestIsCommitSHA(t *testing.T) {
+	assert.True(t, isCommitSHA("0123456789abcdef0123456789abcdef01234567"))
+	assert.False(t, isCommitSHA("main"))
+	assert.False(t, isCommitSHA("0123456789ABCDEF0123456789abcdef01234567"))
+	assert.False(t, isCommitSHA("0123456"))
+}
@@ -20,12 +20,13 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"go.woodpecker-ci.org/woodpecker/v3/server/model"
 )
 
 // toRepo 将 GitCode Repository 转换为 Woodpecker Repo
-func toRepo(from *Repository) *model.Repo {
+func toRepo(links linkBuilder, from *Repository) *model.Repo {
 	// GitCode 直接提供 full_name 字段
 	fullName := from.FullName
 
@@ -50,34 +51,31 @@ func toRepo(from *Repository) *model.Repo {
 	// GitCode 使用 web_url 作为仓库页面 URL
 	forgeURL := from.WebURL
 	if forgeURL == "" {
-		forgeURL = fmt.Sprintf("%s/%s", defaultURL, fullName)
+		forgeURL = links.repo(fullName)
 	}
 
 	// Clone URL 处理 - 优先使用 http_url_to_repo
 	cloneURL := from.HTTPURLToRepo
 	if cloneURL == "" {
-		cloneURL = fmt.Sprintf("%s/%s.git", defaultURL, fullName)
+		cloneURL = links.cloneHTTP(fullName)
 	}
 
 	// SSH URL
 	cloneSSH := from.SSHURLToRepo
 	if cloneSSH == "" {
-		cloneSSH = fmt.Sprintf("git@%s:%s.git", strings.TrimPrefix(defaultURL, "https://"), fullName)
+		cloneSSH = links.cloneSSH(fullName)
 	}
 
-	// 处理私有状态 - GitCode 使用 bool 类型
-	isPrivate := from.Private
+	// 处理私有状态 - GitCode 使用 bool 类型，内部仓库只对实例的登录用户可见，按私有仓库处理
+	isPrivate := from.Private || from.Internal
 
 	// 处理权限 - 使用 GitCode API 返回的权限信息
 	canPull := from.Permission.Pull
 	canPush := from.Permission.Push
 	canAdmin := from.Permission.Admin
 
-	// 获取头像 - 使用创建者的头像
-	avatar := ""
-	if from.Creator.Photo != "" {
-		avatar = expandAvatar(defaultURL, from.Creator.Photo)
-	}
+	// 获取头像 - 优先使用仓库头像，其次是创建者的头像
+	avatar := links.avatarOr(fullName, from.AvatarURL, from.Creator.Photo)
 
 	return &model.Repo{
 		ForgeRemoteID: model.ForgeRemoteID(fmt.Sprintf("%d", from.ID)),
@@ -91,6 +89,7 @@ func toRepo(from *Repository) *model.Repo {
 		Branch:        from.DefaultBranch,
 
 		IsSCMPrivate: isPrivate,
+		IsFork:       from.Fork,
 		Perm: &model.Perm{
 			Pull:  canPull,
 			Push:  canPush,
@@ -100,14 +99,26 @@ func toRepo(from *Repository) *model.Repo {
 }
 
 // toTeam 将 GitCode Organization 转换为 Woodpecker Team
-func toTeam(from *User, baseURL string) *model.Team {
-	avatar := expandAvatar(baseURL, from.AvatarURL)
+func toTeam(from *Organization, baseURL string) *model.Team {
+	avatar := newLinkBuilder(baseURL).avatarOr(from.Login, from.AvatarURL)
 	return &model.Team{
 		Login:  from.Login,
 		Avatar: avatar,
 	}
 }
 
+// toOrgPerm 将 GitCode 组织成员关系转换为 Woodpecker 组织权限，未激活的成员没有权限
+func toOrgPerm(from *OrgMembership) *model.OrgPerm {
+	if from.Active != nil && !*from.Active {
+		return &model.OrgPerm{}
+	}
+	role := strings.ToLower(from.Role)
+	return &model.OrgPerm{
+		Member: true,
+		Admin:  role == "admin" || role == "owner",
+	}
+}
+
 // expandAvatar 扩展头像 URL
 func expandAvatar(baseURL, avatarURL string) string {
 	if avatarURL == "" {
@@ -130,7 +141,7 @@ func expandAvatar(baseURL, avatarURL string) string {
 // convertStatus 将 Woodpecker 状态转换为 GitCode 状态
 func convertStatus(status model.StatusValue) string {
 	switch status {
-	case model.StatusPending, model.StatusBlocked:
+	case model.StatusPending, model.StatusBlocked, model.StatusCreated:
 		return "pending"
 	case model.StatusRunning:
 		return "running"
@@ -142,6 +153,9 @@ func convertStatus(status model.StatusValue) string {
 		return "cancelled"
 	case model.StatusDeclined:
 		return "cancelled"
+	// 依赖的工作流失败而跳过的工作流不单独报告为失败
+	case model.StatusSkipped:
+		return "cancelled"
 	case model.StatusError:
 		return "error"
 	default:
@@ -169,3 +183,16 @@ func convertCommit(sha, url string) *model.Commit {
 		ForgeURL: url,
 	}
 }
+
+// enrichCommit 使用 GitCode 提交详情补充 Woodpecker Commit 的作者、信息和时间
+func enrichCommit(to *model.Commit, from *Commit) {
+	to.Message = from.Commit.Message
+	to.Author = from.Commit.Author.Name
+	to.Email = from.Commit.Author.Email
+	if from.HTMLURL != "" {
+		to.ForgeURL = from.HTMLURL
+	}
+	if t, err := time.Parse(time.RFC3339, from.Commit.Author.Date); err == nil {
+		to.Timestamp = t.UTC().Unix()
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+func TestEnrichCommit(t *testing.T) {
+	var commit Commit
+	err := json.Unmarshal([]byte(`{
+		"sha": "6a6d29ba8df340a5df8c18bb08ab4ee6626476fd",
+		"html_url": "https://gitcode.com/jetsung/testci/commit/6a6d29ba8df340a5df8c18bb08ab4ee6626476fd",
+		"commit": {
+			"message": "fix build",
+			"author": {"name": "jetsung", "email": "i@jetsung.com", "date": "2025-10-01T17:33:54+08:00"}
+		}
+	}`), &commit)
+	assert.NoError(t, err)
+
+	result := &model.Commit{SHA: commit.SHA}
+	enrichCommit(result, &commit)
+	assert.Equal(t, "fix build", result.Message)
+	assert.Equal(t, "jetsung", result.Author)
+	assert.Equal(t, "i@jetsung.com", result.Email)
+	assert.Equal(t, "https://gitcode.com/jetsung/testci/commit/6a6d29ba8df340a5df8c18bb08ab4ee6626476fd", result.ForgeURL)
+	assert.EqualValues(t, 1759311234, result.Timestamp)
+}
+
+func TestToRepoVisibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected bool
+	}{
+		{name: "public", payload: `{"public": true}`, expected: false},
+		{name: "internal", payload: `{"internal": true}`, expected: true},
+		{name: "private", payload: `{"private": true}`, expected: true},
+	}
+	for _, tt := range tests {
+		var from Repository
+		assert.NoError(t, json.Unmarshal([]byte(tt.payload), &from), tt.name)
+		from.FullName = "owner/repo"
+		assert.Equal(t, tt.expected, toRepo(newLinkBuilder(defaultURL), &from).IsSCMPrivate, tt.name)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// operation 对 forge 调用分类，每类调用有各自的超时时间
+type operation int
+
+const (
+	// opDefault 交互式调用，例如获取配置和管理 webhook
+	opDefault operation = iota
+	// opHook 处理 webhook 时补充信息的调用，此时 webhook 投递正在等待响应
+	opHook
+	// opSync 后台列出仓库、分支和团队的调用
+	opSync
+	// opArchive 下载仓库归档等大文件的调用
+	opArchive
+)
+
+func (op operation) String() string {
+	for name, o := range operationNames {
+		if o == op {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// defaultOperationTimeouts 是未单独配置的操作的超时时间
+var defaultOperationTimeouts = operationTimeouts{
+	opDefault: 30 * time.Second,
+	opHook:    10 * time.Second,
+	opSync:    2 * time.Minute,
+	opArchive: 5 * time.Minute,
+}
+
+// operationTimeouts 覆盖部分操作的超时时间，其余操作保持默认值，
+// 为 nil 时全部使用默认值
+type operationTimeouts map[operation]time.Duration
+
+// parseOperationTimeouts 解析 "<operation>=<duration>" 格式的配置，例如 "archive=10m"
+func parseOperationTimeouts(entries []string) (operationTimeouts, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	timeouts := make(operationTimeouts, len(entries))
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid timeout %q, expected <operation>=<duration>", entry)
+		}
+		op, ok := operationNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("invalid timeout %q, unknown operation %q", entry, name)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q, expected a positive duration like 30s", entry)
+		}
+		timeouts[op] = timeout
+	}
+	return timeouts, nil
+}
+
+// timeout 返回操作 op 的超时时间
+func (t operationTimeouts) timeout(op operation) time.Duration {
+	if timeout, ok := t[op]; ok {
+		return timeout
+	}
+	if timeout, ok := defaultOperationTimeouts[op]; ok {
+		return timeout
+	}
+	return t.timeout(opDefault)
+}
+
+type operationKey struct{}
+
+// withOperation 按操作 op 的超时时间限制 ctx 并记录 op，供传输层选择对应的代理配置，
+// 调用方已设置的截止时间始终优先
+func (t operationTimeouts) withOperation(ctx context.Context, op operation) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, operationKey{}, op)
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, t.timeout(op))
+}
+
+// operationFromContext 返回 withOperation 记录的操作，未记录时返回 opDefault
+func operationFromContext(ctx context.Context) operation {
+	if op, ok := ctx.Value(operationKey{}).(operation); ok {
+		return op
+	}
+	return opDefault
+}
+
+// ensureDeadline 为 context 尚无截止时间的请求设置默认超时时间，
+// 保留 forge 按操作设置的截止时间
+func (t operationTimeouts) ensureDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return t.withOperation(ctx, opDefault)
+}
+
+// cancelOnClose 在关闭响应体后释放请求的 context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithOperation(t *testing.T) {
+	ctx, cancel := defaultOperationTimeouts.withOperation(context.Background(), opHook)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(defaultOperationTimeouts[opHook]), deadline, time.Second)
+
+	// a caller deadline wins, even a later one
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Hour)
+	defer parentCancel()
+	ctx, cancel = defaultOperationTimeouts.withOperation(parent, opArchive)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	parentDeadline, _ := parent.Deadline()
+	assert.Equal(t, parentDeadline, deadline)
+}
+
+func TestEnsureDeadline(t *testing.T) {
+	ctx, cancel := defaultOperationTimeouts.ensureDeadline(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(defaultOperationTimeouts[opDefault]), deadline, time.Second)
+
+	// a longer operation deadline set by the forge is kept
+	syncCtx, syncCancel := defaultOperationTimeouts.withOperation(context.Background(), opSync)
+	defer syncCancel()
+	ctx, cancel = defaultOperationTimeouts.ensureDeadline(syncCtx)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	syncDeadline, _ := syncCtx.Deadline()
+	assert.Equal(t, syncDeadline, deadline)
+}
+
+func TestParseOperationTimeouts(t *testing.T) {
+	timeouts, err := parseOperationTimeouts([]string{"archive=10m", " Hook = 5s "})
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, timeouts.timeout(opArchive))
+	assert.Equal(t, 5*time.Second, timeouts.timeout(opHook))
+	assert.Equal(t, defaultOperationTimeouts[opSync], timeouts.timeout(opSync))
+
+	timeouts, err = parseOperationTimeouts(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultOperationTimeouts[opDefault], timeouts.timeout(opDefault))
+
+	for _, entry := range []string{"archive", "upload=1m", "hook=soon", "sync=0s", "sync=-1m"} {
+		_, err := parseOperationTimeouts([]string{entry})
+		assert.Error(t, err, entry)
+	}
+}
+
+func TestClientTimeouts(t *testing.T) {
+	var deadline time.Time
+	client := NewGitCodeClient("token", false, WithTimeouts(operationTimeouts{opDefault: time.Minute}), WithMiddleware(func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			deadline, _ = req.Context().Deadline()
+			return okResponse(), nil
+		})
+	}))
+
+	_, _, err := client.fetch(t.Context(), userEndpoint(), nil)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+const (
+	// deliveryWindow 是记住已处理的 webhook 投递的时长，GitCode 的重试都在此时间内
+	deliveryWindow = time.Hour
+	// maxDeliveries 是同时记住的投递数量上限
+	maxDeliveries = 10000
+	// pendingDeliveryTTL 是等待服务端报告流水线是否创建的时长，超时后投递保持记录
+	pendingDeliveryTTL = 10 * time.Minute
+)
+
+// deliveries 记录已创建流水线的 webhook 投递。GitCode 在 webhook 未及时响应时会重试，
+// 重试携带相同的 uuid，每次重试都会创建一条重复的流水线。记录只保存在内存中，不在服务端实例间共享
+type deliveries struct {
+	seen *ttlcache.Cache[string, struct{}]
+	// pending 按 Hook 返回的流水线记录尚未确认的投递，服务端创建流水线失败时据此撤销记录
+	pending *ttlcache.Cache[*model.Pipeline, string]
+}
+
+func newDeliveries() *deliveries {
+	return &deliveries{
+		seen: ttlcache.New(
+			ttlcache.WithTTL[string, struct{}](deliveryWindow),
+			ttlcache.WithCapacity[string, struct{}](maxDeliveries),
+			ttlcache.WithDisableTouchOnHit[string, struct{}](),
+		),
+		pending: ttlcache.New(
+			ttlcache.WithTTL[*model.Pipeline, string](pendingDeliveryTTL),
+			ttlcache.WithCapacity[*model.Pipeline, string](maxDeliveries),
+		),
+	}
+}
+
+// deliveryKey 返回仓库中投递 id 的键，仓库改名后项目 ID 不变
+func deliveryKey(r *model.Repo, id string) string {
+	repo := string(r.ForgeRemoteID)
+	if repo == "" {
+		repo = r.FullName
+	}
+	return repo + "#" + id
+}
+
+// handled 判断投递是否已经创建过流水线，d 为 nil 时不去重
+func (d *deliveries) handled(r *model.Repo, id string) bool {
+	if d == nil {
+		return false
+	}
+	return d.seen.Has(deliveryKey(r, id))
+}
+
+// claim 为流水线 p 预留投递，已记录过时返回 false。同时到达的重试只有一个能成功预留，
+// 预留在 settle 报告流水线未创建时撤销
+func (d *deliveries) claim(r *model.Repo, id string, p *model.Pipeline) bool {
+	if d == nil {
+		return true
+	}
+	key := deliveryKey(r, id)
+	if _, found := d.seen.GetOrSet(key, struct{}{}); found {
+		return false
+	}
+	d.pending.Set(p, key, ttlcache.DefaultTTL)
+	return true
+}
+
+// settle 确认或撤销为流水线 p 预留的投递，撤销后该投递的重试会再次创建流水线
+func (d *deliveries) settle(p *model.Pipeline, created bool) {
+	if d == nil {
+		return
+	}
+	item, found := d.pending.GetAndDelete(p)
+	if found && !created {
+		d.seen.Delete(item.Value())
+	}
+}
+
+// deliveryID 返回 webhook 原始请求体中的 uuid，没有时返回空
+func deliveryID(payload []byte) string {
+	var delivery struct {
+		UUID string `json:"uuid"`
+	}
+	if json.Unmarshal(payload, &delivery) != nil {
+		return ""
+	}
+	return delivery.UUID
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func TestDeliveries(t *testing.T) {
+	d := newDeliveries()
+	repo := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo"}
+
+	first, second := &model.Pipeline{}, &model.Pipeline{}
+
+	assert.False(t, d.handled(repo, "a"))
+	assert.True(t, d.claim(repo, "a", first))
+	assert.True(t, d.handled(repo, "a"))
+	assert.False(t, d.claim(repo, "a", second))
+
+	// 流水线未创建时撤销预留
+	d.settle(first, false)
+	assert.False(t, d.handled(repo, "a"))
+	assert.True(t, d.claim(repo, "a", second))
+	d.settle(second, true)
+	assert.True(t, d.handled(repo, "a"))
+	// 已确认的投递不会再被撤销
+	d.settle(second, false)
+	assert.True(t, d.handled(repo, "a"))
+
+	// 按仓库区分
+	assert.True(t, d.claim(&model.Repo{ForgeRemoteID: "2", FullName: "owner/other"}, "a", first))
+
+	var disabled *deliveries
+	assert.False(t, disabled.handled(repo, "a"))
+	assert.True(t, disabled.claim(repo, "a", first))
+	disabled.settle(first, false)
+
+	assert.Equal(t, "4_d3e9", deliveryID([]byte(`{"uuid":"4_d3e9","object_kind":"push"}`)))
+	assert.Empty(t, deliveryID([]byte(`{"object_kind":"push"}`)))
+	assert.Empty(t, deliveryID([]byte(`not json`)))
+}
+
+func TestHookDropsDuplicateDeliveries(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "7720285", FullName: "jetsung/testci", Owner: "jetsung", Name: "testci", UserID: 1, Hash: "hash"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("7720285"), "jetsung/testci").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token", Expiry: 1 << 40}, nil).Maybe()
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	c := newStubGitCode(t, func(*http.Request) (int, string) {
+		return http.StatusOK, `[]`
+	})
+	hook := func(uuid string) (*model.Pipeline, error) {
+		payload := `{
+			"uuid": "` + uuid + `",
+			"user": {"username": "jetsung"},
+			"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci", "web_url": "https://gitcode.com/jetsung/testci"},
+			"merge_request": {"id": 1, "iid": 3, "action": "open", "state": "opened", "source_branch": "dev", "target_branch": "main",
+				"last_commit": {"id": "abc"}, "source": {"id": 7720285}, "target": {"id": 7720285}}
+		}`
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(payload))
+		req.Header.Set(hookEvent, hookMergeRequest)
+		req.Header.Set(hookToken, webhookSecret(repo))
+		_, pipeline, err := c.Hook(ctx, req)
+		return pipeline, err
+	}
+
+	pipeline, err := hook("4_d3e9")
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline)
+
+	// 服务端创建流水线失败后，重试仍会创建流水线
+	c.HookHandled(ctx, pipeline, false)
+	pipeline, err = hook("4_d3e9")
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline)
+
+	c.HookHandled(ctx, pipeline, true)
+	_, err = hook("4_d3e9")
+	assert.ErrorIs(t, err, &forge_types.ErrIgnoreEvent{})
+	assert.ErrorContains(t, err, "duplicate delivery 4_d3e9")
+
+	pipeline, err = hook("4_f00d")
+	assert.NoError(t, err)
+	assert.NotNil(t, pipeline)
+
+	// 没有 uuid 的投递不去重
+	for range 2 {
+		pipeline, err = hook("")
+		assert.NoError(t, err)
+		assert.NotNil(t, pipeline)
+	}
+}
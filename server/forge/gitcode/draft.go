@@ -0,0 +1,61 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"bytes"
+	"context"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+)
+
+// pullReasonReady 是草稿合并请求标记为就绪时流水线的事件原因
+const pullReasonReady = "ready_for_review"
+
+// skipsDrafts 判断激活的仓库是否设置为跳过草稿合并请求。webhook 未携带仓库令牌时从存储中读取仓库，
+// 作为 addon 运行时无法读取仓库设置，不跳过
+func skipsDrafts(ctx context.Context, repo, stored *model.Repo) bool {
+	if stored == nil {
+		_store, ok := store.TryFromContext(ctx)
+		if !ok {
+			return false
+		}
+		var err error
+		if stored, err = _store.GetRepoNameFallback(repo.ForgeRemoteID, repo.FullName); err != nil {
+			return false
+		}
+	}
+	return stored.SkipDraftPullRequests
+}
+
+// isDraftMergeRequest 判断合并请求 webhook 的原始请求体是否属于草稿合并请求
+func isDraftMergeRequest(payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+	pr, err := parsePullRequest(bytes.NewReader(payload))
+	return err == nil && pr.MergeRequest.WorkInProgress
+}
+
+// markedReady 判断合并请求 webhook 是否由草稿标记为就绪触发
+func markedReady(hook *pullRequestHook) bool {
+	for _, change := range []*boolChange{hook.Changes.Draft, hook.Changes.WorkInProgress} {
+		if change != nil && change.Previous && !change.Current {
+			return true
+		}
+	}
+	return false
+}
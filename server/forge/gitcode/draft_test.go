@@ -0,0 +1,103 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func draftPayload(action string, draft bool, changes string) string {
+	return `{
+		"user": {"username": "jetsung"},
+		"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci", "web_url": "https://gitcode.com/jetsung/testci"},
+		"merge_request": {"id": 1, "iid": 3, "action": "` + action + `", "state": "opened", "work_in_progress": ` + strconv.FormatBool(draft) + `,
+			"source_branch": "dev", "target_branch": "main", "last_commit": {"id": "abc"}, "source": {"id": 7720285}, "target": {"id": 7720285}},
+		"changes": {` + changes + `}
+	}`
+}
+
+func TestParseMarkedReady(t *testing.T) {
+	for _, changes := range []string{
+		`"work_in_progress": {"previous": true, "current": false}`,
+		`"draft": {"previous": true, "current": false}`,
+	} {
+		_, pipeline, err := parsePullRequestHook(newLinkBuilder(defaultURL), strings.NewReader(draftPayload("update", false, changes)))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{pullReasonReady}, pipeline.EventReason, changes)
+	}
+
+	_, pipeline, err := parsePullRequestHook(newLinkBuilder(defaultURL), strings.NewReader(draftPayload("update", true, `"draft": {"previous": false, "current": true}`)))
+	assert.NoError(t, err)
+	assert.Empty(t, pipeline.EventReason)
+}
+
+func TestHookSkipsDraftMergeRequests(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "7720285", FullName: "jetsung/testci", Owner: "jetsung", Name: "testci", UserID: 1, Hash: "hash"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("7720285"), "jetsung/testci").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token", Expiry: 1 << 40}, nil).Maybe()
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	c := newStubGitCode(t, func(*http.Request) (int, string) {
+		return http.StatusOK, `[]`
+	})
+	hookWithContext := func(ctx context.Context, payload string) (*model.Pipeline, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(payload))
+		req.Header.Set(hookEvent, hookMergeRequest)
+		req.Header.Set(hookToken, webhookSecret(repo))
+		_, pipeline, err := c.Hook(ctx, req)
+		return pipeline, err
+	}
+	hook := func(payload string) (*model.Pipeline, error) {
+		return hookWithContext(ctx, payload)
+	}
+
+	// 默认为草稿创建流水线
+	pipeline, err := hook(draftPayload("open", true, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, model.EventPull, pipeline.Event)
+
+	repo.SkipDraftPullRequests = true
+	_, err = hook(draftPayload("open", true, ""))
+	assert.ErrorIs(t, err, &forge_types.ErrIgnoreEvent{})
+	assert.ErrorContains(t, err, "draft")
+
+	// 标记为就绪后创建流水线
+	pipeline, err = hook(draftPayload("update", false, `"work_in_progress": {"previous": true, "current": false}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{pullReasonReady}, pipeline.EventReason)
+
+	// 无法读取仓库设置时不跳过
+	pipeline, err = hookWithContext(t.Context(), draftPayload("open", true, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, model.EventPull, pipeline.Event)
+
+	// 草稿合并请求关闭时仍会通知
+	pipeline, err = hook(strings.Replace(draftPayload("close", true, ""), `"state": "opened"`, `"state": "closed"`, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, model.EventPullClosed, pipeline.Event)
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// escapeSegment URL 编码单个路径段，使其既不能增加路径层级、开始查询参数或片段，也不能跳到上级目录
+func escapeSegment(s string) string {
+	if s == "." || s == ".." {
+		return strings.ReplaceAll(s, ".", "%2E")
+	}
+	return url.PathEscape(s)
+}
+
+// escapePath URL 编码文件路径的每一段，保留目录分隔符
+func escapePath(p string) string {
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i := range segments {
+		segments[i] = escapeSegment(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+// repoEndpoint 构造 "/repos/{owner}/{repo}/{segments...}"，每一段都经过编码
+func repoEndpoint(owner, repo string, segments ...string) string {
+	var b strings.Builder
+	b.WriteString("/repos/")
+	b.WriteString(escapeSegment(owner))
+	b.WriteString("/")
+	b.WriteString(escapeSegment(repo))
+	for _, segment := range segments {
+		b.WriteString("/")
+		b.WriteString(escapeSegment(segment))
+	}
+	return b.String()
+}
+
+func userEndpoint() string {
+	return "/user"
+}
+
+func userReposEndpoint() string {
+	return "/user/repos"
+}
+
+func userOrgsEndpoint() string {
+	return "/user/orgs"
+}
+
+func usersEndpoint(login string) string {
+	return "/users/" + escapeSegment(login)
+}
+
+func orgEndpoint(org string) string {
+	return "/orgs/" + escapeSegment(org)
+}
+
+func orgMembershipEndpoint(org, username string) string {
+	return orgEndpoint(org) + "/memberships/" + escapeSegment(username)
+}
+
+func repositoryEndpoint(id string) string {
+	return "/repositories/" + escapeSegment(id)
+}
+
+func collaboratorPermissionEndpoint(owner, repo, username string) string {
+	return repoEndpoint(owner, repo, "collaborators", username, "permission")
+}
+
+func branchesEndpoint(owner, repo string) string {
+	return repoEndpoint(owner, repo, "branches")
+}
+
+func branchEndpoint(owner, repo, branch string) string {
+	return repoEndpoint(owner, repo, "branches", branch)
+}
+
+func protectedTagsEndpoint(owner, repo string) string {
+	return repoEndpoint(owner, repo, "protected_tags")
+}
+
+func commitEndpoint(owner, repo, sha string) string {
+	return repoEndpoint(owner, repo, "commits", sha)
+}
+
+func commitStatusesEndpoint(owner, repo, sha string) string {
+	return repoEndpoint(owner, repo, "statuses", sha)
+}
+
+func commitCommentsEndpoint(owner, repo, sha string) string {
+	return repoEndpoint(owner, repo, "commits", sha, "comments")
+}
+
+func issuesEndpoint(owner, repo string) string {
+	return repoEndpoint(owner, repo, "issues")
+}
+
+// createIssueEndpoint 是 "/repos/{owner}/issues"，仓库放在请求体中
+func createIssueEndpoint(owner string) string {
+	return "/repos/" + escapeSegment(owner) + "/issues"
+}
+
+func issueCommentsEndpoint(owner, repo, number string) string {
+	return repoEndpoint(owner, repo, "issues", number, "comments")
+}
+
+func compareEndpoint(owner, repo, base, head string) string {
+	return repoEndpoint(owner, repo, "compare", base+"..."+head)
+}
+
+func pullsEndpoint(owner, repo string) string {
+	return repoEndpoint(owner, repo, "pulls")
+}
+
+func pullEndpoint(owner, repo string, number int64) string {
+	return repoEndpoint(owner, repo, "pulls", strconv.FormatInt(number, 10))
+}
+
+func pullFilesEndpoint(owner, repo string, number int64) string {
+	return repoEndpoint(owner, repo, "pulls", strconv.FormatInt(number, 10), "files")
+}
+
+func pullLabelsEndpoint(owner, repo string, number int64) string {
+	return repoEndpoint(owner, repo, "pulls", strconv.FormatInt(number, 10), "labels")
+}
+
+// rawFileEndpoint 保留 path 中的目录分隔符，见 escapePath
+func rawFileEndpoint(owner, repo, path string) string {
+	return repoEndpoint(owner, repo, "raw") + "/" + escapePath(path)
+}
+
+// contentsEndpoint 保留 path 中的目录分隔符，见 escapePath。path 为空时列出根目录
+func contentsEndpoint(owner, repo, path string) string {
+	if strings.Trim(path, "/") == "" {
+		return repoEndpoint(owner, repo, "contents")
+	}
+	return repoEndpoint(owner, repo, "contents") + "/" + escapePath(strings.TrimSuffix(path, "/"))
+}
+
+func archiveEndpoint(owner, repo string) string {
+	return repoEndpoint(owner, repo, "tarball")
+}
+
+func treeEndpoint(owner, repo, sha string) string {
+	return repoEndpoint(owner, repo, "git", "trees", sha)
+}
+
+func hooksEndpoint(owner, repo string) string {
+	return repoEndpoint(owner, repo, "hooks")
+}
+
+func hookEndpoint(owner, repo string, id int64) string {
+	return repoEndpoint(owner, repo, "hooks", strconv.FormatInt(id, 10))
+}
+
+func releaseUploadURLEndpoint(owner, repo, tag string) string {
+	return repoEndpoint(owner, repo, "releases", tag, "upload_url")
+}
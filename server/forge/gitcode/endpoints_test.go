@@ -0,0 +1,126 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "plain", input: ".woodpecker/build.yaml", expected: ".woodpecker/build.yaml"},
+		{name: "leading slash", input: "/.woodpecker.yaml", expected: ".woodpecker.yaml"},
+		{name: "space", input: "ci config/build.yaml", expected: "ci%20config/build.yaml"},
+		{name: "hash and question mark", input: "a#b/c?d.yaml", expected: "a%23b/c%3Fd.yaml"},
+		{name: "unicode", input: "流水线/构建.yaml", expected: "%E6%B5%81%E6%B0%B4%E7%BA%BF/%E6%9E%84%E5%BB%BA.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, escapePath(tt.input))
+		})
+	}
+}
+
+func TestEndpointsEscapeHostileInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		expected string
+	}{
+		{
+			name:     "plain repo",
+			endpoint: repoEndpoint("jetsung", "testci"),
+			expected: "/repos/jetsung/testci",
+		},
+		{
+			name:     "traversal in owner",
+			endpoint: repoEndpoint("..", "admin"),
+			expected: "/repos/%2E%2E/admin",
+		},
+		{
+			name:     "extra path level in repo",
+			endpoint: repoEndpoint("owner", "repo/hooks"),
+			expected: "/repos/owner/repo%2Fhooks",
+		},
+		{
+			name:     "query injection in repo",
+			endpoint: repoEndpoint("owner", "repo?access_token=evil"),
+			expected: "/repos/owner/repo%3Faccess_token=evil",
+		},
+		{
+			name:     "traversal in org member",
+			endpoint: orgMembershipEndpoint("org", ".."),
+			expected: "/orgs/org/memberships/%2E%2E",
+		},
+		{
+			name:     "traversal in contents path",
+			endpoint: contentsEndpoint("owner", "repo", "../../user/keys/"),
+			expected: "/repos/owner/repo/contents/%2E%2E/%2E%2E/user/keys",
+		},
+		{
+			name:     "contents of root",
+			endpoint: contentsEndpoint("owner", "repo", "/"),
+			expected: "/repos/owner/repo/contents",
+		},
+		{
+			name:     "fragment in branch",
+			endpoint: branchEndpoint("owner", "repo", "main#x"),
+			expected: "/repos/owner/repo/branches/main%23x",
+		},
+		{
+			name:     "slash in branch",
+			endpoint: branchEndpoint("owner", "repo", "feature/login"),
+			expected: "/repos/owner/repo/branches/feature%2Flogin",
+		},
+		{
+			name:     "traversal in file path",
+			endpoint: rawFileEndpoint("owner", "repo", "../../user/keys"),
+			expected: "/repos/owner/repo/raw/%2E%2E/%2E%2E/user/keys",
+		},
+		{
+			name:     "compare range",
+			endpoint: compareEndpoint("owner", "repo", "aaa", "ccc"),
+			expected: "/repos/owner/repo/compare/aaa...ccc",
+		},
+		{
+			name:     "hook id",
+			endpoint: hookEndpoint("owner", "repo", 42),
+			expected: "/repos/owner/repo/hooks/42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.endpoint)
+
+			// the escaping must survive URL parsing in buildURL
+			reqURL, err := NewGitCodeClient("", false).buildURL(tt.endpoint, nil)
+			assert.NoError(t, err)
+			u, err := url.Parse(reqURL)
+			assert.NoError(t, err)
+			assert.Empty(t, u.RawQuery)
+			assert.Empty(t, u.Fragment)
+			assert.Equal(t, defaultAPI+tt.expected, reqURL)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodySize 限制保留的错误响应大小
+const maxErrorBodySize = 64 << 10
+
+// ResponseTooLargeError 表示响应体超过了配置的大小限制
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+// readLimited 读取 r 的全部内容，超过 limit 字节时返回错误，
+// limit 小于等于 0 时不限制
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &ResponseTooLargeError{Limit: limit}
+	}
+	return data, nil
+}
+
+// APIError 可以通过 errors.Is 匹配的哨兵错误，调用方无需查看状态码即可区分常见错误
+var (
+	// ErrNotFound 匹配 404 响应，token 无权查看的资源 GitCode 也返回 404
+	ErrNotFound = errors.New("gitcode: not found")
+	// ErrUnauthorized 匹配 token 被拒绝或权限不足的 401 和 403 响应
+	ErrUnauthorized = errors.New("gitcode: unauthorized")
+	// ErrRateLimited 匹配等待限流重置后仍被拒绝的 429 响应
+	ErrRateLimited = errors.New("gitcode: rate limited")
+)
+
+// APIError 是 GitCode API 返回的错误响应
+type APIError struct {
+	StatusCode int
+	// Code 是 GitCode 的错误名称，例如 "NOT_FOUND"
+	Code string
+	// Number 是 GitCode 返回的数字错误码，可能为空
+	Number  int
+	Message string
+	TraceID string
+	// Retries 是返回该错误前请求重试的次数
+	Retries int
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+	if e.Code != "" {
+		msg = fmt.Sprintf("API error %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	if e.Retries > 0 {
+		msg += fmt.Sprintf(" (after %d retries)", e.Retries)
+	}
+	return msg
+}
+
+// HTTPStatus 返回响应的状态码，见 forge_types.HTTPStatusError
+func (e *APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// Is 按状态码匹配哨兵错误
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// apiErrorBody 是 GitCode API 的错误响应格式
+type apiErrorBody struct {
+	ErrorCode     json.Number `json:"error_code"`
+	ErrorCodeName string      `json:"error_code_name"`
+	ErrorMessage  string      `json:"error_message"`
+	Message       string      `json:"message"`
+	TraceID       string      `json:"trace_id"`
+}
+
+// parseAPIError 将错误响应体解析为 APIError，不是 GitCode 错误格式的响应体原样作为错误信息
+func parseAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+
+	var raw apiErrorBody
+	if err := json.Unmarshal(body, &raw); err != nil {
+		apiErr.Message = redactText(strings.TrimSpace(string(body)))
+		return apiErr
+	}
+
+	apiErr.Code = raw.ErrorCodeName
+	if n, err := raw.ErrorCode.Int64(); err == nil {
+		apiErr.Number = int(n)
+	} else if apiErr.Code == "" {
+		apiErr.Code = raw.ErrorCode.String()
+	}
+	apiErr.Message = redactText(raw.ErrorMessage)
+	if apiErr.Message == "" {
+		apiErr.Message = redactText(raw.Message)
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = redactText(strings.TrimSpace(string(body)))
+	}
+	apiErr.TraceID = raw.TraceID
+	return apiErr
+}
+
+// errorEnvelope 检测成功响应中的 GitCode 错误信息，部分接口会以 200 OK 返回错误
+func errorEnvelope(statusCode int, body []byte) *APIError {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		// 不是对象，例如列表或原始内容
+		return nil
+	}
+	_, hasCode := fields["error_code"]
+	_, hasMessage := fields["error_message"]
+	if !hasCode && !hasMessage {
+		return nil
+	}
+
+	apiErr := parseAPIError(statusCode, body)
+	// 优先使用错误信息中类似 HTTP 状态码的错误码，调用方看到的是 404 而不是 200
+	if apiErr.Number >= http.StatusBadRequest && apiErr.Number < 600 {
+		apiErr.StatusCode = apiErr.Number
+	}
+	return apiErr
+}
+
+// newAPIError 将失败响应的响应体读取为 APIError
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	apiErr := parseAPIError(resp.StatusCode, body)
+	apiErr.Retries = retriesOf(resp)
+	return apiErr
+}
+
+// IsErrorCode 判断 err 是否为带有指定 GitCode 错误名称的 APIError
+func IsErrorCode(err error, code string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// isStatus 判断 err 是否为带有指定 HTTP 状态码的 APIError
+func isStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
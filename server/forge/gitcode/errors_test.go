@@ -0,0 +1,133 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+)
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected *APIError
+	}{
+		{
+			name:   "gitcode envelope",
+			status: 404,
+			body:   `{"error_code": 404, "error_code_name": "NOT_FOUND", "error_message": "Branch Not Found", "trace_id": "abc"}`,
+			expected: &APIError{
+				StatusCode: 404,
+				Code:       "NOT_FOUND",
+				Number:     404,
+				Message:    "Branch Not Found",
+				TraceID:    "abc",
+			},
+		},
+		{
+			name:     "message only",
+			status:   403,
+			body:     `{"message": "insufficient scope"}`,
+			expected: &APIError{StatusCode: 403, Message: "insufficient scope"},
+		},
+		{
+			name:     "plain text",
+			status:   502,
+			body:     "Bad Gateway\n",
+			expected: &APIError{StatusCode: 502, Message: "Bad Gateway"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseAPIError(tt.status, []byte(tt.body)))
+		})
+	}
+}
+
+func TestIsErrorCode(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: 404, Code: "NOT_FOUND"})
+	assert.True(t, IsErrorCode(err, "NOT_FOUND"))
+	assert.False(t, IsErrorCode(err, "FORBIDDEN"))
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestSentinelErrors(t *testing.T) {
+	notFound := fmt.Errorf("wrapped: %w", &APIError{StatusCode: 404})
+	assert.ErrorIs(t, notFound, ErrNotFound)
+	assert.NotErrorIs(t, notFound, ErrUnauthorized)
+
+	assert.ErrorIs(t, &APIError{StatusCode: 401}, ErrUnauthorized)
+	assert.ErrorIs(t, &APIError{StatusCode: 403}, ErrUnauthorized)
+	assert.ErrorIs(t, &APIError{StatusCode: 429}, ErrRateLimited)
+	assert.NotErrorIs(t, &APIError{StatusCode: 500}, ErrNotFound)
+	assert.NotErrorIs(t, errors.New("404 page not found"), ErrNotFound)
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	apiErr := errorEnvelope(200, []byte(`{"error_code": 404, "error_code_name": "NOT_FOUND", "error_message": "Project Not Found"}`))
+	if assert.NotNil(t, apiErr) {
+		assert.Equal(t, 404, apiErr.StatusCode)
+		assert.Equal(t, "Project Not Found", apiErr.Message)
+	}
+
+	apiErr = errorEnvelope(200, []byte(`{"error_message": "token expired"}`))
+	if assert.NotNil(t, apiErr) {
+		assert.Equal(t, 200, apiErr.StatusCode)
+	}
+
+	assert.Nil(t, errorEnvelope(200, []byte(`{"id": 1, "login": "jetsung"}`)))
+	assert.Nil(t, errorEnvelope(200, []byte(`[{"name": "main"}]`)))
+}
+
+func TestReadLimited(t *testing.T) {
+	data, err := readLimited(strings.NewReader("steps: []"), 9)
+	assert.NoError(t, err)
+	assert.Equal(t, "steps: []", string(data))
+
+	_, err = readLimited(strings.NewReader("steps: [] "), 9)
+	var tooLarge *ResponseTooLargeError
+	assert.ErrorAs(t, err, &tooLarge)
+	assert.EqualValues(t, 9, tooLarge.Limit)
+
+	data, err = readLimited(strings.NewReader("unlimited"), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "unlimited", string(data))
+}
+
+func TestRepoError(t *testing.T) {
+	notFound := repoError("owner/repo", &APIError{StatusCode: http.StatusNotFound})
+	assert.ErrorIs(t, notFound, forge_types.ErrRepoNotFound)
+	assert.Contains(t, notFound.Error(), "owner/repo")
+
+	forbidden := repoError("owner/repo", &APIError{StatusCode: http.StatusForbidden})
+	assert.ErrorIs(t, forbidden, forge_types.ErrRepoNoPermission)
+	assert.NotErrorIs(t, forbidden, forge_types.ErrRepoNotFound)
+
+	var apiErr *APIError
+	assert.ErrorAs(t, forbidden, &apiErr)
+
+	other := &APIError{StatusCode: http.StatusBadGateway}
+	assert.Equal(t, error(other), repoError("owner/repo", other))
+}
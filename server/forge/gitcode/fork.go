@@ -0,0 +1,57 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// verifyFork 通过 API 再次确认合并请求是否来自复刻仓库。webhook 中的项目信息可能缺失或不准确，
+// API 确认为复刻时标记流水线，使其经过复刻审批并且拿不到受保护的密钥。
+// 只会将流水线标记为复刻，查询失败时保留 webhook 的判断
+func (c *GitCode) verifyFork(ctx context.Context, repo *model.Repo, pipeline *model.Pipeline) {
+	if pipeline.FromFork {
+		return
+	}
+	index, err := pullRequestIndex(pipeline)
+	if err != nil {
+		return
+	}
+
+	client, stored, err := c.repoOwnerClient(ctx, repo)
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not verify the source of PR %s#%d", repo.FullName, index)
+		return
+	}
+	pr, err := client.GetPullRequest(ctx, stored.Owner, stored.Name, index)
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not verify the source of PR %s#%d", repo.FullName, index)
+		return
+	}
+	if pr.Head.Repo == nil || pr.Base.Repo == nil {
+		return
+	}
+
+	head := mergeRequestProject{id: pr.Head.Repo.ID, path: pr.Head.Repo.FullName}
+	base := mergeRequestProject{id: pr.Base.Repo.ID, path: pr.Base.Repo.FullName}
+	if isForkMergeRequest(head, base) {
+		log.Debug().Msgf("PR %s#%d comes from fork %s", repo.FullName, index, pr.Head.Repo.FullName)
+		pipeline.FromFork = true
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func TestVerifyFork(t *testing.T) {
+	stored := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Owner: "owner", Name: "repo", UserID: 1}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("1"), "owner/repo").Return(stored, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token", Expiry: 1 << 40}, nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	status, head := http.StatusOK, `{"id":1,"full_name":"owner/repo"}`
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/api/v5/repos/owner/repo/pulls/3", req.URL.Path)
+		return status, `{"number":3,"head":{"ref":"dev","repo":` + head + `},"base":{"ref":"main","repo":{"id":1,"full_name":"owner/repo"}}}`
+	})
+	verify := func() bool {
+		pipeline := &model.Pipeline{Event: model.EventPull, Ref: "refs/pull/3/head"}
+		c.verifyFork(ctx, &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo"}, pipeline)
+		return pipeline.FromFork
+	}
+
+	assert.False(t, verify())
+
+	head = `{"id":2,"full_name":"contributor/repo"}`
+	assert.True(t, verify())
+
+	// 查询失败时保留 webhook 的判断
+	status = http.StatusInternalServerError
+	assert.False(t, verify())
+}
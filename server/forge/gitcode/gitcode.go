@@ -15,23 +15,33 @@
 package gitcode
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"go.woodpecker-ci.org/woodpecker/v3/server"
 	"go.woodpecker-ci.org/woodpecker/v3/server/forge"
 	"go.woodpecker-ci.org/woodpecker/v3/server/forge/common"
 	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
 	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
 	shared_utils "go.woodpecker-ci.org/woodpecker/v3/shared/utils"
 )
 
@@ -42,27 +52,250 @@ const (
 	// OAuth 端点
 	authorizeTokenURL = "%s/oauth/authorize"
 	accessTokenURL    = "%s/oauth/token"
+	// defaultRefreshMargin 是默认提前刷新 token 的时间
+	defaultRefreshMargin = 30 * time.Minute
 
 	// API 配置
-	defaultPageSize = 50
+	defaultPageSize        = 50
+	maxPageSize            = 100      // GitCode API 允许的最大分页大小
+	defaultMaxResponseSize = 10 << 20 // 10 MiB
 )
 
 type Opts struct {
+	URL               string // GitCode url, defaults to https://gitcode.com
+	APIURL            string // GitCode API v5 url, defaults to <URL>/api/v5 or https://api.gitcode.com/api/v5
+	SkipVerify        bool   // Skip ssl verification, for instances with self-signed certificates
 	OAuthClientID     string
 	OAuthClientSecret string
+	DomainAliases     []string // other domains serving the same instance, e.g. gitcode.net
+	OAuthRedirectHost string   // woodpecker host for the OAuth callback, defaults to the server config
+	// FailureIssueThreshold opens an issue after this many consecutive failures
+	// of a cron or default branch pipeline, 0 disables it.
+	FailureIssueThreshold int
+	// Proxies selects an egress proxy per operation class, e.g. "sync=http://proxy:3128" or "hook=direct".
+	Proxies []string
+	// ActivationCheck verifies that the webhook url is reachable before a repo is activated.
+	ActivationCheck bool
+	// MaxChangedFiles caps the changed files stored per pipeline, 0 disables the cap.
+	MaxChangedFiles int
+	// ForkConfigFromTarget reads the config of fork pull requests from the target branch.
+	ForkConfigFromTarget bool
+	// RepoAffiliation limits the synced repos to owner, collaborator and/or organization_member.
+	RepoAffiliation []string
+	// LogSampleRate logs only every n-th successful read request, 0 or 1 log all of them.
+	LogSampleRate int
+	// PageSize is the number of items requested per page when listing, 0 uses the default of 50.
+	PageSize int
+	// ConfigCacheSize is the number of pipeline config files and trees cached in memory per commit, 0 disables the cache.
+	ConfigCacheSize int
+	// ResponseCacheSize is the number of API responses kept for conditional requests, 0 disables the cache.
+	ResponseCacheSize int
+	// AuthMode selects how the access token is sent: bearer (default), private-token or query.
+	AuthMode string
+	// GitUsername and GitToken are the machine account used to clone all repos instead of the activating user's token.
+	GitUsername string
+	GitToken    string
+	// RepoCredentials sets the machine account per repo as owner/repo=login:token, owner/* matches all repos of owner.
+	RepoCredentials []string
+	// ReleaseActions are the release hook actions that start a pipeline, empty only starts pipelines for published releases.
+	ReleaseActions []string
+	// SystemHookSecret is the secret of an instance-wide system hook sent to /api/hook?forge_id=<id>, empty disables system hooks.
+	SystemHookSecret string
+	// RefreshMargin refreshes OAuth tokens this long before they expire, 0 uses the default of 30 minutes.
+	RefreshMargin time.Duration
+	// HookEvents are the events registered for the webhook, empty registers push, tag_push, pull_request and release.
+	HookEvents []string
+	// BranchHeadMachineAccount looks up branch heads, e.g. for cron pipelines, with the machine account instead of the repo owner's token.
+	BranchHeadMachineAccount bool
+	// StatusContext is the template of commit status contexts, e.g. "ci/woodpecker/%event/%workflow", empty uses the server's status context format.
+	StatusContext string
+	// MaxRetries is how often failed GET and DELETE requests are retried, 0 uses the default of 2 and a negative value disables retries.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled for every further retry, 0 uses the default of 500ms.
+	RetryBackoff time.Duration
+	// Timeouts overrides the time budget per operation class, e.g. "archive=10m" or "hook=5s".
+	Timeouts []string
+	// DebugTrace logs every API request with its status, duration and truncated, redacted bodies.
+	DebugTrace bool
+	// MaxResponseSize is the largest API response or file in bytes that is read, 0 uses the default of 10 MiB and a negative value disables the limit.
+	MaxResponseSize int
 }
 
 type GitCode struct {
 	oAuthClientID     string
 	oAuthClientSecret string
-	pageSize          int
+	oAuthRedirectHost string
+	url               string
+	apiURL            string
+	skipVerify        bool
+	aliases           []string
+	// failureIssueThreshold 见 Opts.FailureIssueThreshold
+	failureIssueThreshold int
+	pageSize              int
+	// api 是启动时在后台探测到的 API 版本，探测完成前为 nil，使用 v5
+	api atomic.Pointer[apiAdapter]
+	// apiProbed 在 API 版本探测结束后关闭
+	apiProbed chan struct{}
+	// inflight 在所有客户端之间共享，用于合并相同的并发 GET 请求
+	inflight *singleflight.Group
+	// rateLimits 在所有客户端之间共享，同一令牌的请求在限流重置前排队等待
+	rateLimits *rateLimits
+	// proxies 见 Opts.Proxies
+	proxies proxyProfiles
+	// activationCheck 见 Opts.ActivationCheck
+	activationCheck bool
+	// maxChangedFiles 见 Opts.MaxChangedFiles
+	maxChangedFiles int
+	// forkConfigFromTarget 见 Opts.ForkConfigFromTarget
+	forkConfigFromTarget bool
+	// repoAffiliation 见 Opts.RepoAffiliation
+	repoAffiliation []string
+	// logSampler 在所有客户端之间共享，见 Opts.LogSampleRate
+	logSampler zerolog.Sampler
+	// configCache 见 Opts.ConfigCacheSize，为 nil 时不缓存
+	configCache *configCache
+	// responses 见 Opts.ResponseCacheSize，在所有客户端之间共享，为 nil 时不缓存
+	responses *responseCache
+	// authMode 见 Opts.AuthMode
+	authMode AuthMode
+	// machineAccount 见 Opts.GitUsername 和 Opts.GitToken
+	machineAccount credentials
+	// repoCredentials 见 Opts.RepoCredentials
+	repoCredentials repoCredentials
+	// releaseActions 见 Opts.ReleaseActions
+	releaseActions []string
+	// systemHookSecret 见 Opts.SystemHookSecret，为空时不接受系统 webhook
+	systemHookSecret string
+	// deliveries 记录已处理的 webhook 投递，用于丢弃 GitCode 重试的重复投递
+	deliveries *deliveries
+	// refreshMargin 见 Opts.RefreshMargin
+	refreshMargin time.Duration
+	// hookEvents 见 Opts.HookEvents
+	hookEvents []string
+	// branchHeadMachineAccount 见 Opts.BranchHeadMachineAccount
+	branchHeadMachineAccount bool
+	// statusContext 见 Opts.StatusContext
+	statusContext string
+	// maxRetries 见 Opts.MaxRetries
+	maxRetries int
+	// retryBackoff 见 Opts.RetryBackoff
+	retryBackoff time.Duration
+	// timeouts 见 Opts.Timeouts
+	timeouts operationTimeouts
+	// debugTrace 见 Opts.DebugTrace
+	debugTrace bool
+	// maxResponseSize 见 Opts.MaxResponseSize，小于等于 0 表示不限制
+	maxResponseSize int64
+	// clientOptions 追加到每个客户端，测试中用于替换传输层
+	clientOptions []ClientOption
 }
 
 func New(opts Opts) (forge.Forge, error) {
-	return &GitCode{
-		oAuthClientID:     opts.OAuthClientID,
-		oAuthClientSecret: opts.OAuthClientSecret,
-	}, nil
+	c, err := newGitCode(opts)
+	if err != nil {
+		return nil, err
+	}
+	go c.probeAPI(context.Background())
+	return c, nil
+}
+
+// newGitCode 按配置创建 GitCode，不探测 API 版本
+func newGitCode(opts Opts) (*GitCode, error) {
+	proxies, err := parseProxyProfiles(opts.Proxies)
+	if err != nil {
+		return nil, err
+	}
+	affiliation, err := parseRepoAffiliation(opts.RepoAffiliation)
+	if err != nil {
+		return nil, err
+	}
+	authMode, err := parseAuthMode(opts.AuthMode)
+	if err != nil {
+		return nil, err
+	}
+	repoCreds, err := parseRepoCredentials(opts.RepoCredentials)
+	if err != nil {
+		return nil, err
+	}
+	events, err := parseHookEvents(opts.HookEvents)
+	if err != nil {
+		return nil, err
+	}
+	releases, err := parseReleaseActions(opts.ReleaseActions)
+	if err != nil {
+		return nil, err
+	}
+	statusContext, err := parseStatusContext(opts.StatusContext)
+	if err != nil {
+		return nil, err
+	}
+	timeouts, err := parseOperationTimeouts(opts.Timeouts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.GitToken != "" && opts.GitUsername == "" {
+		return nil, errors.New("gitcode git username is required when a git token is set")
+	}
+
+	c := &GitCode{
+		oAuthClientID:            opts.OAuthClientID,
+		oAuthClientSecret:        opts.OAuthClientSecret,
+		oAuthRedirectHost:        opts.OAuthRedirectHost,
+		failureIssueThreshold:    opts.FailureIssueThreshold,
+		url:                      defaultURL,
+		apiURL:                   defaultAPI,
+		skipVerify:               opts.SkipVerify,
+		inflight:                 &singleflight.Group{},
+		rateLimits:               newRateLimits(),
+		proxies:                  proxies,
+		timeouts:                 timeouts,
+		debugTrace:               opts.DebugTrace,
+		activationCheck:          opts.ActivationCheck,
+		maxChangedFiles:          opts.MaxChangedFiles,
+		forkConfigFromTarget:     opts.ForkConfigFromTarget,
+		repoAffiliation:          affiliation,
+		pageSize:                 min(opts.PageSize, maxPageSize),
+		configCache:              newConfigCache(opts.ConfigCacheSize),
+		responses:                newResponseCache(opts.ResponseCacheSize),
+		authMode:                 authMode,
+		machineAccount:           credentials{login: opts.GitUsername, token: opts.GitToken},
+		repoCredentials:          repoCreds,
+		releaseActions:           releases,
+		systemHookSecret:         opts.SystemHookSecret,
+		deliveries:               newDeliveries(),
+		apiProbed:                make(chan struct{}),
+		refreshMargin:            defaultRefreshMargin,
+		hookEvents:               events,
+		branchHeadMachineAccount: opts.BranchHeadMachineAccount,
+		statusContext:            statusContext,
+		maxRetries:               defaultMaxRetries,
+		maxResponseSize:          defaultMaxResponseSize,
+		retryBackoff:             defaultRetryBackoff,
+	}
+	if opts.URL != "" && strings.TrimRight(opts.URL, "/") != defaultURL {
+		c.url = strings.TrimRight(opts.URL, "/")
+		c.apiURL = c.url + "/api/" + apiV5
+	}
+	if opts.APIURL != "" {
+		c.apiURL = strings.TrimRight(opts.APIURL, "/")
+	}
+	if opts.RefreshMargin > 0 {
+		c.refreshMargin = opts.RefreshMargin
+	}
+	if opts.MaxRetries != 0 {
+		c.maxRetries = max(opts.MaxRetries, 0)
+	}
+	if opts.MaxResponseSize != 0 {
+		c.maxResponseSize = max(int64(opts.MaxResponseSize), 0)
+	}
+	if opts.RetryBackoff > 0 {
+		c.retryBackoff = opts.RetryBackoff
+	}
+	if opts.LogSampleRate > 1 {
+		c.logSampler = &zerolog.BasicSampler{N: uint32(opts.LogSampleRate)}
+	}
+	c.aliases = domainAliases(c.links().hostname(), opts.DomainAliases)
+	return c, nil
 }
 
 func (c *GitCode) Name() string {
@@ -70,7 +303,12 @@ func (c *GitCode) Name() string {
 }
 
 func (c *GitCode) URL() string {
-	return defaultURL
+	return c.url
+}
+
+// links 返回当前实例的 URL 构建器
+func (c *GitCode) links() linkBuilder {
+	return newLinkBuilder(c.url)
 }
 
 func (c *GitCode) oauth2Config(ctx context.Context) (*oauth2.Config, context.Context) {
@@ -78,18 +316,26 @@ func (c *GitCode) oauth2Config(ctx context.Context) (*oauth2.Config, context.Con
 			ClientID:     c.oAuthClientID,
 			ClientSecret: c.oAuthClientSecret,
 			Endpoint: oauth2.Endpoint{
-				AuthURL:  fmt.Sprintf(authorizeTokenURL, defaultURL),
-				TokenURL: fmt.Sprintf(accessTokenURL, defaultURL),
+				AuthURL:  fmt.Sprintf(authorizeTokenURL, c.url),
+				TokenURL: fmt.Sprintf(accessTokenURL, c.url),
 			},
-			RedirectURL: fmt.Sprintf("%s/authorize", server.Config.Server.OAuthHost),
+			RedirectURL: fmt.Sprintf("%s/authorize", c.redirectHost()),
 		},
 
 		context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: c.skipVerify},
+			Proxy:           c.proxies.proxy,
 		}})
 }
 
+// redirectHost 返回 OAuth 回调使用的 Woodpecker 地址；作为 addon 运行时无法读取服务端配置
+func (c *GitCode) redirectHost() string {
+	if c.oAuthRedirectHost != "" {
+		return c.oAuthRedirectHost
+	}
+	return server.Config.Server.OAuthHost
+}
+
 func (c *GitCode) Login(ctx context.Context, req *forge_types.OAuthRequest) (*model.User, string, error) {
 	config, oauth2Ctx := c.oauth2Config(ctx)
 	redirectURL := config.AuthCodeURL(req.State)
@@ -108,7 +354,7 @@ func (c *GitCode) Login(ctx context.Context, req *forge_types.OAuthRequest) (*mo
 		return nil, redirectURL, err
 	}
 
-	client := NewGitCodeClient(token.AccessToken, false)
+	client := c.newGitCodeClient(token.AccessToken)
 	account, err := client.GetUser(ctx)
 	if err != nil {
 		return nil, redirectURL, err
@@ -120,89 +366,234 @@ func (c *GitCode) Login(ctx context.Context, req *forge_types.OAuthRequest) (*mo
 		Expiry:        token.Expiry.UTC().Unix(),
 		Login:         account.Login,
 		Email:         account.Email,
+		DisplayName:   account.displayName(),
 		ForgeRemoteID: model.ForgeRemoteID(fmt.Sprint(account.ID)),
-		Avatar:        expandAvatar(defaultURL, account.AvatarURL),
+		Avatar:        c.links().avatarOr(orDefault(account.Email, account.Login), account.AvatarURL),
 	}, redirectURL, nil
 }
 
-func (c *GitCode) Auth(ctx context.Context, token, _ string) (string, error) {
-	client := NewGitCodeClient(token, false)
-	user, err := client.GetUser(ctx)
+// Auth 校验 token 并返回对应的登录名；token 被拒绝且提供了 refresh token 时，
+// 先换取新的 access token 再重试一次
+func (c *GitCode) Auth(ctx context.Context, token, refreshToken string) (string, error) {
+	user, err := c.newGitCodeClient(token).GetUser(ctx)
+	if isStatus(err, http.StatusUnauthorized) && refreshToken != "" {
+		// 过期时间设为过去，强制 oauth2 使用 refresh token
+		refreshed, refreshErr := c.refreshToken(ctx, &oauth2.Token{
+			AccessToken:  token,
+			RefreshToken: refreshToken,
+			Expiry:       time.Unix(1, 0),
+		})
+		if refreshErr != nil {
+			return "", errors.Join(err, fmt.Errorf("refresh access token: %w", refreshErr))
+		}
+		user, err = c.newGitCodeClient(refreshed.AccessToken).GetUser(ctx)
+		if err == nil {
+			c.saveRefreshedToken(ctx, user, refreshToken, refreshed)
+		}
+	}
 	if err != nil {
 		return "", err
 	}
 	return user.Login, nil
 }
 
-func (c *GitCode) Refresh(ctx context.Context, user *model.User) (bool, error) {
+// saveRefreshedToken 保存 Auth 换取的新 token。GitCode 的 refresh token 使用后即失效，
+// 不保存的话存储中用户的 refresh token 将无法再刷新
+func (c *GitCode) saveRefreshedToken(ctx context.Context, account *User, refreshToken string, token *oauth2.Token) {
+	_store, ok := store.TryFromContext(ctx)
+	if !ok {
+		log.Warn().Msgf("GitCode: could not save the refreshed token of %s, no store in context", account.Login)
+		return
+	}
+	user, err := _store.GetUserRemoteID(model.ForgeRemoteID(fmt.Sprint(account.ID)), account.Login)
+	if err != nil {
+		log.Warn().Err(err).Msgf("GitCode: could not save the refreshed token of %s", account.Login)
+		return
+	}
+	// 只替换刚刚使用过的 refresh token，用户期间重新登录过时保留新的 token
+	if user.RefreshToken != refreshToken {
+		return
+	}
+	user.AccessToken = token.AccessToken
+	user.RefreshToken = token.RefreshToken
+	user.Expiry = token.Expiry.UTC().Unix()
+	if err := _store.UpdateUser(user); err != nil {
+		log.Warn().Err(err).Msgf("GitCode: could not save the refreshed token of %s", account.Login)
+	}
+}
+
+// refreshToken 在 token 过期时通过 refresh token 换取新的 access token，未过期时原样返回
+func (c *GitCode) refreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
 	config, oauth2Ctx := c.oauth2Config(ctx)
 	config.RedirectURL = ""
 
-	source := config.TokenSource(oauth2Ctx, &oauth2.Token{
+	refreshed, err := config.TokenSource(oauth2Ctx, token).Token()
+	if err != nil {
+		return nil, err
+	}
+	if len(refreshed.AccessToken) == 0 {
+		return nil, errors.New("empty access token")
+	}
+	return refreshed, nil
+}
+
+// Refresh 在 token 距过期不足 refreshMargin 时主动换取新 token，
+// 而不是等到 oauth2 认为其已过期，避免流水线运行过程中 token 失效
+func (c *GitCode) Refresh(ctx context.Context, user *model.User) (bool, error) {
+	if user.RefreshToken == "" || !c.tokenExpiresSoon(user) {
+		return false, nil
+	}
+	// 过期时间设为过去，强制 oauth2 使用 refresh token
+	token, err := c.refreshToken(ctx, &oauth2.Token{
 		AccessToken:  user.AccessToken,
 		RefreshToken: user.RefreshToken,
-		Expiry:       time.Unix(user.Expiry, 0),
+		Expiry:       time.Now().Add(-time.Second),
 	})
-
-	token, err := source.Token()
-	if err != nil || len(token.AccessToken) == 0 {
+	if err != nil {
 		return false, err
 	}
 
 	user.AccessToken = token.AccessToken
 	user.RefreshToken = token.RefreshToken
 	user.Expiry = token.Expiry.UTC().Unix()
+
+	// 顺便同步昵称，失败不影响 token 刷新
+	if account, err := c.newGitCodeClient(user.AccessToken).GetUser(ctx); err == nil {
+		user.DisplayName = account.displayName()
+	} else {
+		log.Debug().Err(err).Msgf("GitCode: could not update display name of %s", user.Login)
+	}
 	return true, nil
 }
 
+// tokenExpiresSoon 判断用户的 token 是否将在 refreshMargin 内过期
+func (c *GitCode) tokenExpiresSoon(user *model.User) bool {
+	return time.Until(time.Unix(user.Expiry, 0)) < c.refreshMargin
+}
+
+// ensureFreshToken 在使用存储中用户的 token 调用 API 前按需刷新并保存新 token，
+// 刷新失败时继续使用原 token
+func (c *GitCode) ensureFreshToken(ctx context.Context, _store store.Store, user *model.User) {
+	refreshed, err := c.Refresh(ctx, user)
+	if err != nil {
+		log.Debug().Err(err).Msgf("GitCode: could not refresh token of %s", user.Login)
+		return
+	}
+	if !refreshed {
+		return
+	}
+	if err := _store.UpdateUser(user); err != nil {
+		log.Error().Err(err).Msgf("GitCode: could not save refreshed token of %s", user.Login)
+	}
+}
+
+// Teams 返回用户所属的 GitCode 组织
 func (c *GitCode) Teams(ctx context.Context, u *model.User) ([]*model.Team, error) {
-	// GitCode 暂时不支持组织列表，返回空列表
-	// TODO: 实现 GitCode 组织 API 支持
-	return []*model.Team{}, nil
+	ctx, cancel := c.timeouts.withOperation(ctx, opSync)
+	defer cancel()
+	client := c.newGitCodeClient(u.AccessToken)
+
+	orgs, err := shared_utils.Paginate(func(page int) ([]*Organization, error) {
+		return client.GetUserOrgs(ctx, page, c.perPage(ctx))
+	}, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make([]*model.Team, 0, len(orgs))
+	for _, org := range orgs {
+		teams = append(teams, toTeam(org, c.url))
+	}
+	return teams, nil
 }
 
-func (c *GitCode) TeamPerm(_ *model.User, _ string) (*model.Perm, error) {
-	return nil, nil
+// TeamPerm 根据用户在组织中的角色返回权限：成员可读，管理员拥有全部权限
+func (c *GitCode) TeamPerm(u *model.User, org string) (*model.Perm, error) {
+	perm, err := c.OrgMembership(context.Background(), u, org)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Perm{
+		Pull:  perm.Member,
+		Push:  perm.Admin,
+		Admin: perm.Admin,
+	}, nil
 }
 
 func (c *GitCode) Repo(ctx context.Context, u *model.User, remoteID model.ForgeRemoteID, owner, name string) (*model.Repo, error) {
-	client := NewGitCodeClient(u.AccessToken, false)
+	client := c.newGitCodeClient(u.AccessToken)
 
 	if remoteID.IsValid() {
-		// GitCode 不支持直接通过 ID 获取仓库，需要从用户仓库列表中查找
-		repos, err := shared_utils.Paginate(func(page int) ([]*Repository, error) {
-			repos, err := client.GetUserRepos(ctx, page, defaultPageSize)
-			return repos, err
-		}, -1)
+		repo, err := c.repoByID(ctx, client, string(remoteID), owner, name)
 		if err != nil {
 			return nil, err
 		}
-
-		// 查找匹配的仓库
-		targetID := string(remoteID)
-		for _, repo := range repos {
-			if strconv.FormatInt(repo.ID, 10) == targetID {
-				return toRepo(repo), nil
-			}
-		}
-		return nil, fmt.Errorf("repository with ID %s not found", targetID)
+		result := toRepo(c.links(), repo)
+		c.canonicalizeRepo(result)
+		return result, nil
 	}
 
 	// 通过 owner/name 获取仓库信息
 	repo, err := client.GetRepo(ctx, owner, name)
+	if err != nil {
+		return nil, repoError(owner+"/"+name, err)
+	}
+	result := toRepo(c.links(), repo)
+	c.canonicalizeRepo(result)
+	return result, nil
+}
+
+// repoByID 按 ID 查找仓库：先按已知的 owner/name 查询并核对 ID（仓库可能已改名），
+// 再使用按 ID 查询的接口，实例不支持该接口时才遍历用户的仓库列表
+func (c *GitCode) repoByID(ctx context.Context, client *GitCodeClient, id, owner, name string) (*Repository, error) {
+	if owner != "" && name != "" {
+		if repo, err := client.GetRepo(ctx, owner, name); err == nil && strconv.FormatInt(repo.ID, 10) == id {
+			return repo, nil
+		}
+	}
+
+	repo, err := client.GetRepoByID(ctx, id)
+	if err == nil {
+		return repo, nil
+	}
+	if !isStatus(err, http.StatusNotFound) {
+		return nil, repoError(id, err)
+	}
+
+	repos, err := shared_utils.Paginate(func(page int) ([]*Repository, error) {
+		return client.GetUserRepos(ctx, nil, page, c.perPage(ctx))
+	}, -1)
 	if err != nil {
 		return nil, err
 	}
-	return toRepo(repo), nil
+	for _, repo := range repos {
+		if strconv.FormatInt(repo.ID, 10) == id {
+			return repo, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no repository with ID %s", forge_types.ErrRepoNotFound, id)
+}
+
+// repoError 将仓库查询的 404 和 401/403 错误映射为 forge 通用的错误类型
+func repoError(fullName string, err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return fmt.Errorf("%w: %s: %w", forge_types.ErrRepoNotFound, fullName, err)
+	case errors.Is(err, ErrUnauthorized):
+		return fmt.Errorf("%w: %s: %w", forge_types.ErrRepoNoPermission, fullName, err)
+	}
+	return err
 }
 
 func (c *GitCode) Repos(ctx context.Context, u *model.User) ([]*model.Repo, error) {
-	client := NewGitCodeClient(u.AccessToken, false)
+	ctx, cancel := c.timeouts.withOperation(ctx, opSync)
+	defer cancel()
+	client := c.newGitCodeClient(u.AccessToken)
 
 	log.Debug().Msgf("GitCode: Getting repos for user %s", u.Login)
 
 	repos, err := shared_utils.Paginate(func(page int) ([]*Repository, error) {
-		repos, err := client.GetUserRepos(ctx, page, defaultPageSize)
+		repos, err := client.GetUserRepos(ctx, c.repoAffiliation, page, c.perPage(ctx))
 		return repos, err
 	}, -1)
 
@@ -215,21 +606,67 @@ func (c *GitCode) Repos(ctx context.Context, u *model.User) ([]*model.Repo, erro
 
 	result := make([]*model.Repo, 0, len(repos))
 	for i, repo := range repos {
-		convertedRepo := toRepo(repo)
+		convertedRepo := toRepo(c.links(), repo)
+		c.canonicalizeRepo(convertedRepo)
 		log.Debug().Msgf("GitCode: Repo %d - Original: ID=%v, Name=%s, FullName=%s", i, repo.ID, repo.Name, repo.FullName)
 		log.Debug().Msgf("GitCode: Repo %d - Converted: Owner=%s, Name=%s, FullName=%s, ForgeURL=%s", i, convertedRepo.Owner, convertedRepo.Name, convertedRepo.FullName, convertedRepo.ForgeURL)
 		result = append(result, convertedRepo)
 	}
 
+	sortRepos(result)
+
 	log.Debug().Msgf("GitCode: Returning %d converted repos for user %s", len(result), u.Login)
 	return result, err
 }
 
+// repoAffiliations 是 GitCode 用户仓库列表支持的 affiliation 取值
+var repoAffiliations = []string{"owner", "collaborator", "organization_member"}
+
+// parseRepoAffiliation 校验并去重仓库关系过滤条件，为空时不过滤
+func parseRepoAffiliation(values []string) ([]string, error) {
+	var affiliation []string
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if !slices.Contains(repoAffiliations, value) {
+			return nil, fmt.Errorf("unknown repo affiliation %q, expected one of %s", value, strings.Join(repoAffiliations, ", "))
+		}
+		affiliation = append(affiliation, value)
+	}
+	return shared_utils.Deduplicate(affiliation), nil
+}
+
+// sortRepos 按名称稳定排序，同名仓库中源仓库排在 fork 之前，避免 fork 遮盖源仓库
+func sortRepos(repos []*model.Repo) {
+	slices.SortStableFunc(repos, func(a, b *model.Repo) int {
+		return cmp.Or(
+			cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+			compareBool(a.IsFork, b.IsFork),
+			cmp.Compare(strings.ToLower(a.FullName), strings.ToLower(b.FullName)),
+			cmp.Compare(a.FullName, b.FullName),
+		)
+	})
+}
+
+// compareBool 将 false 排在 true 之前
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
 func (c *GitCode) File(ctx context.Context, u *model.User, r *model.Repo, b *model.Pipeline, f string) ([]byte, error) {
-	client := NewGitCodeClient(u.AccessToken, false)
+	ctx = withPipeline(ctx, b)
+	client := c.newGitCodeClient(u.AccessToken)
 
 	// 确定要使用的 commit SHA 或分支名
-	ref := b.Commit
+	ref := c.configRef(b)
 	if ref == "" {
 		// 如果没有指定 commit，使用默认分支
 		ref = r.Branch
@@ -238,117 +675,191 @@ func (c *GitCode) File(ctx context.Context, u *model.User, r *model.Repo, b *mod
 		}
 	}
 
+	if cfg, ok := c.configCache.file(r, ref, f); ok {
+		return cfg, nil
+	}
+
 	cfg, err := client.GetFileContent(ctx, r.Owner, r.Name, f, ref)
-	if err != nil {
-		if strings.Contains(err.Error(), "404") {
-			return nil, errors.Join(err, &forge_types.ErrConfigNotFound{Configs: []string{f}})
-		}
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, errors.Join(err, &forge_types.ErrConfigNotFound{Configs: []string{f}})
+	case errors.Is(err, ErrRateLimited):
+		return nil, fmt.Errorf("could not read %s of %s, the GitCode API rate limit is exhausted: %w", f, r.FullName, err)
+	case errors.As(err, new(*ResponseTooLargeError)):
+		return nil, fmt.Errorf("could not read %s of %s: %w", f, r.FullName, err)
+	case err != nil:
 		return nil, err
 	}
+	c.configCache.setFile(r, ref, f, cfg)
 	return cfg, nil
 }
 
+// configRef 返回读取流水线配置使用的引用。启用 forkConfigFromTarget 时，来自派生仓库的
+// 合并请求从目标分支读取配置，构建的仍是派生仓库的代码，合并请求无法修改流水线本身
+func (c *GitCode) configRef(b *model.Pipeline) string {
+	if c.forkConfigFromTarget && b.FromFork && b.IsPullRequest() && b.Branch != "" {
+		return b.Branch
+	}
+	return b.Commit
+}
+
 func (c *GitCode) Dir(ctx context.Context, u *model.User, r *model.Repo, b *model.Pipeline, f string) ([]*forge_types.FileMeta, error) {
-	client := NewGitCodeClient(u.AccessToken, false)
+	ctx = withPipeline(ctx, b)
+	client := c.newGitCodeClient(u.AccessToken)
 
-	// 确定要使用的 commit SHA
-	commitSHA := b.Commit
+	// 确定要使用的 commit SHA，未指定时解析分支的最新提交，使读取的配置与构建的提交一致
+	commitSHA := c.configRef(b)
 	if commitSHA == "" {
-		// 如果没有指定 commit，使用默认分支
-		branchName := r.Branch
-		if branchName == "" {
-			branchName = "main" // 默认分支
-		}
-
+		branchName := cmp.Or(b.Branch, r.Branch, "main")
 		branch, err := client.GetBranch(ctx, r.Owner, r.Name, branchName)
 		if err != nil {
-			log.Debug().Err(err).Msgf("GitCode: Failed to get branch %s for %s/%s", branchName, r.Owner, r.Name)
-			return []*forge_types.FileMeta{}, nil
+			return nil, dirError(f, fmt.Errorf("resolve branch %s: %w", branchName, err))
 		}
 		commitSHA = branch.Commit.ID
 	}
 
-	// 使用 commit SHA 获取目录树（递归获取）
-	tree, err := client.GetTree(ctx, r.Owner, r.Name, commitSHA, true)
-	if err != nil {
-		log.Debug().Err(err).Msgf("GitCode: Failed to get tree for %s/%s at %s", r.Owner, r.Name, commitSHA)
-		// 如果获取树失败，尝试直接获取文件内容
-		// 对于手动触发的流水线，我们可以返回空的文件列表，让 Woodpecker 使用默认配置
-		log.Debug().Msgf("GitCode: Returning empty file list for manual pipeline trigger")
-		return []*forge_types.FileMeta{}, nil
-	}
-
-	var files []*forge_types.FileMeta
-
 	// 标准化目录路径
 	targetDir := strings.TrimPrefix(f, "/")
 	if targetDir != "" && !strings.HasSuffix(targetDir, "/") {
 		targetDir += "/"
 	}
 
-	for _, entry := range tree.Tree {
+	// 使用 commit SHA 获取目录树，优先下载一次归档同时得到目录树和配置文件，
+	// 归档不可用时（如实例不支持或仓库过大）递归获取目录树并逐个下载文件
+	treeEntries, ok := c.configCache.tree(r, commitSHA)
+	var archived map[string][]byte
+	if !ok {
+		var err error
+		treeEntries, archived, err = c.readArchive(ctx, client, r, commitSHA, targetDir)
+		if err != nil {
+			log.Debug().Err(err).Msgf("GitCode: could not read archive of %s at %s, walking the tree instead", r.FullName, commitSHA)
+			tree, err := client.GetTree(ctx, r.Owner, r.Name, commitSHA, true)
+			if err != nil {
+				return nil, dirError(f, fmt.Errorf("get tree at %s: %w", commitSHA, err))
+			}
+			treeEntries = tree.Tree
+		}
+		c.configCache.setTree(r, commitSHA, treeEntries)
+	}
+
+	var entries []TreeEntry
+	for _, entry := range treeEntries {
 		// 只处理文件类型
 		if entry.Type != "blob" {
 			continue
 		}
 
-		// 检查文件是否在目标目录中
-		if targetDir == "" {
-			// 根目录：只要文件不在子目录中
-			if !strings.Contains(entry.Path, "/") {
-				// 获取文件内容
-				data, err := client.GetFileContent(ctx, r.Owner, r.Name, entry.Path, commitSHA)
-				if err != nil {
-					log.Debug().Err(err).Msgf("GitCode: Failed to get file content for %s", entry.Path)
-					continue
-				}
-
-				files = append(files, &forge_types.FileMeta{
-					Name: entry.Path,
-					Data: data,
-				})
-			}
-		} else {
-			// 指定目录：文件路径必须以目标目录开头，且不在更深的子目录中
-			if strings.HasPrefix(entry.Path, targetDir) {
-				relativePath := strings.TrimPrefix(entry.Path, targetDir)
-				// 确保文件直接在目标目录中，不在子目录中
-				if !strings.Contains(relativePath, "/") {
-					// 获取文件内容
-					data, err := client.GetFileContent(ctx, r.Owner, r.Name, entry.Path, commitSHA)
-					if err != nil {
-						log.Debug().Err(err).Msgf("GitCode: Failed to get file content for %s", entry.Path)
-						continue
-					}
-
-					files = append(files, &forge_types.FileMeta{
-						Name: entry.Path,
-						Data: data,
-					})
-				}
-			}
+		// 检查文件是否直接位于目标目录中，不在更深的子目录中
+		if !inDir(entry.Path, targetDir) {
+			continue
 		}
+
+		// 跳过图片、二进制等不可能是流水线配置的文件
+		if !isConfigCandidate(entry.Path, entry.Size) {
+			log.Trace().Msgf("GitCode: Skipping non-config entry %s", entry.Path)
+			continue
+		}
+
+		entries = append(entries, entry)
 	}
 
-	// log.Debug().Msgf("GitCode: Found %d files in directory %s for repo %s", len(files), f, r.FullName)
-	return files, nil
+	if archived == nil {
+		entries = withContentSizes(ctx, client, r, commitSHA, targetDir, entries)
+	}
+	return c.fetchFiles(ctx, client, r, commitSHA, entries, archived), nil
 }
 
-func (c *GitCode) Status(ctx context.Context, user *model.User, repo *model.Repo, pipeline *model.Pipeline, workflow *model.Workflow) error {
-	// GitCode 暂时不支持状态更新，跳过
-	// TODO: 实现 GitCode 状态 API 支持
-	// log.Debug().Msgf("GitCode status update not implemented for repo %s", repo.FullName)
-	return nil
+// inDir 判断文件是否直接位于以 / 结尾的目录 dir 中，不在更深的子目录中
+func inDir(name, dir string) bool {
+	return strings.HasPrefix(name, dir) && !strings.Contains(strings.TrimPrefix(name, dir), "/")
 }
 
-func (c *GitCode) Netrc(u *model.User, r *model.Repo) (*model.Netrc, error) {
-	login := ""
-	token := ""
+// withContentSizes 在目录树未返回文件大小时，通过 contents 接口一次性列出目录补全大小，
+// 并跳过超过 maxConfigFileSize 的文件；列出失败时保留原有条目
+func withContentSizes(ctx context.Context, client *GitCodeClient, r *model.Repo, ref, dir string, entries []TreeEntry) []TreeEntry {
+	if !slices.ContainsFunc(entries, func(entry TreeEntry) bool { return entry.Size == 0 }) {
+		return entries
+	}
+	listing, err := client.ListContents(ctx, r.Owner, r.Name, dir, ref)
+	if err != nil {
+		log.Debug().Err(err).Msgf("GitCode: could not list contents of %q to get file sizes", dir)
+		return entries
+	}
+
+	contents := make(map[string]*ContentEntry, len(listing))
+	for _, content := range listing {
+		contents[content.Path] = content
+	}
+	sized := make([]TreeEntry, 0, len(entries))
+	for _, entry := range entries {
+		if content, ok := contents[entry.Path]; ok && entry.Size == 0 {
+			entry.Size = content.Size
+			entry.SHA = cmp.Or(entry.SHA, content.SHA)
+		}
+		if entry.Size > maxConfigFileSize {
+			log.Trace().Msgf("GitCode: Skipping oversized entry %s (%d bytes)", entry.Path, entry.Size)
+			continue
+		}
+		sized = append(sized, entry)
+	}
+	return sized
+}
+
+// dirError 将 404 映射为 ErrConfigNotFound，以便继续查找其他配置；其他错误原样返回，不再被当作空目录
+func dirError(dir string, err error) error {
+	if isStatus(err, http.StatusNotFound) {
+		return errors.Join(err, &forge_types.ErrConfigNotFound{Configs: []string{dir}})
+	}
+	return err
+}
 
-	if u != nil {
-		login = u.Login
-		token = u.AccessToken
+// dirFetchConcurrency 限制 Dir 同时下载的文件数，避免触发 API 限流
+const dirFetchConcurrency = 4
+
+// fetchFiles 并发下载目录中的配置文件，结果保持目录树中的顺序，下载失败的文件被跳过，
+// 已从归档中读取或已缓存的文件不再下载
+func (c *GitCode) fetchFiles(ctx context.Context, client *GitCodeClient, r *model.Repo, ref string, entries []TreeEntry, archived map[string][]byte) []*forge_types.FileMeta {
+	fetched := make([]*forge_types.FileMeta, len(entries))
+	var g errgroup.Group
+	g.SetLimit(dirFetchConcurrency)
+	for i, entry := range entries {
+		if data, ok := archived[entry.Path]; ok {
+			c.configCache.setFile(r, ref, entry.Path, data)
+			fetched[i] = &forge_types.FileMeta{Name: entry.Path, Data: data, SHA: entry.SHA}
+			continue
+		}
+		if data, ok := c.configCache.file(r, ref, entry.Path); ok {
+			fetched[i] = &forge_types.FileMeta{Name: entry.Path, Data: data, SHA: entry.SHA}
+			continue
+		}
+		g.Go(func() error {
+			data, err := client.GetFileContent(ctx, r.Owner, r.Name, entry.Path, ref)
+			if err != nil {
+				log.Debug().Err(err).Msgf("GitCode: Failed to get file content for %s", entry.Path)
+				return nil
+			}
+			c.configCache.setFile(r, ref, entry.Path, data)
+			fetched[i] = &forge_types.FileMeta{
+				Name: entry.Path,
+				Data: data,
+				SHA:  entry.SHA,
+			}
+			return nil
+		})
 	}
+	_ = g.Wait()
+
+	files := make([]*forge_types.FileMeta, 0, len(fetched))
+	for _, file := range fetched {
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func (c *GitCode) Netrc(u *model.User, r *model.Repo) (*model.Netrc, error) {
+	creds := c.cloneCredentials(u, r)
 
 	host, err := common.ExtractHostFromCloneURL(r.Clone)
 	if err != nil {
@@ -356,55 +867,130 @@ func (c *GitCode) Netrc(u *model.User, r *model.Repo) (*model.Netrc, error) {
 	}
 
 	return &model.Netrc{
-		Login:    login,
-		Password: token,
+		Login:    creds.login,
+		Password: creds.token,
 		Machine:  host,
 		Type:     model.ForgeTypeGitCode,
 	}, nil
 }
 
 func (c *GitCode) Activate(ctx context.Context, u *model.User, r *model.Repo, link string) error {
-	client := NewGitCodeClient(u.AccessToken, false)
+	if c.activationCheck {
+		transport := &http.Transport{Proxy: c.proxies.proxy}
+		defer transport.CloseIdleConnections()
+		checkCtx, cancel := c.timeouts.withOperation(ctx, opHook)
+		defer cancel()
+		if err := checkReachable(checkCtx, transport, link); err != nil {
+			return err
+		}
+	}
+
+	client := c.newGitCodeClient(u.AccessToken)
 
 	hook := &CreateHookRequest{
 		URL:         link,
 		ContentType: "json",
-		Events:      []string{"push", "pull_request", "release"},
+		Events:      c.hookEvents,
 		Active:      true,
+		Password:    webhookSecret(r),
+	}
+
+	// 已有指向 link 的 webhook 时更新其事件和密码，修改配置后修复仓库即可生效，不会重复创建
+	if existing := c.findHook(ctx, client, r, link); existing != nil {
+		if _, err := client.UpdateHook(ctx, r.Owner, r.Name, existing.ID, hook); err != nil {
+			return activationError(ctx, client, u, r, err)
+		}
+		return nil
 	}
 
 	_, err := client.CreateHook(ctx, r.Owner, r.Name, hook)
 	if err != nil {
-		if strings.Contains(err.Error(), "404") {
-			return fmt.Errorf("could not find repository")
-		}
-		return err
+		return activationError(ctx, client, u, r, err)
 	}
 	return nil
 }
 
+// activationError 区分仓库不存在和缺少 webhook 管理权限两种情况；
+// GitCode 对无权限访问的 hooks 接口同样返回 404，因此需要再查询一次仓库权限
+func activationError(ctx context.Context, client *GitCodeClient, u *model.User, r *model.Repo, err error) error {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return missingAdminPermission(u, r, err)
+	case errors.Is(err, ErrNotFound):
+		repo, repoErr := client.GetRepo(ctx, r.Owner, r.Name)
+		if repoErr != nil {
+			return fmt.Errorf("could not find repository %s: %w", r.FullName, err)
+		}
+		if !repo.Permission.Admin {
+			return missingAdminPermission(u, r, err)
+		}
+	}
+	return err
+}
+
+func missingAdminPermission(u *model.User, r *model.Repo, err error) error {
+	return fmt.Errorf("user %s lacks the admin permission on repository %s which is required to create webhooks, "+
+		"ask a repository owner to grant it or to activate the repository: %w", u.Login, r.FullName, err)
+}
+
+// Deactivate 删除所有指向 Woodpecker webhook 地址的 webhook，包括服务端改名前注册的和重复的；
+// 仓库或 webhook 已被删除时视为成功
 func (c *GitCode) Deactivate(ctx context.Context, u *model.User, r *model.Repo, link string) error {
-	client := NewGitCodeClient(u.AccessToken, false)
+	client := c.newGitCodeClient(u.AccessToken)
 
-	hooks, err := client.GetHooks(ctx, r.Owner, r.Name)
+	hooks, err := shared_utils.Paginate(func(page int) ([]*Hook, error) {
+		return client.GetHooks(ctx, r.Owner, r.Name, page, c.perPage(ctx))
+	}, -1)
+	if isStatus(err, http.StatusNotFound) {
+		log.Debug().Msgf("repository %s no longer exists on GitCode, nothing to deactivate", r.FullName)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
+	var errs []error
 	for _, hook := range hooks {
-		if hook.URL == link {
-			return client.DeleteHook(ctx, r.Owner, r.Name, hook.ID)
+		if !isWoodpeckerHook(hook.URL, link) {
+			continue
+		}
+		err := client.DeleteHook(ctx, r.Owner, r.Name, hook.ID)
+		if isStatus(err, http.StatusNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delete webhook %d: %w", hook.ID, err))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func (c *GitCode) Branches(ctx context.Context, u *model.User, r *model.Repo, p *model.ListOptions) ([]string, error) {
+	return c.SearchBranches(ctx, u, r, "", p)
+}
+
+// SearchBranches returns the names of the branches matching search, filtered server-side by GitCode.
+// All branches are returned when p is nil or asks for all of them, otherwise only the requested page.
+func (c *GitCode) SearchBranches(ctx context.Context, u *model.User, r *model.Repo, search string, p *model.ListOptions) ([]string, error) {
+	ctx, cancel := c.timeouts.withOperation(ctx, opSync)
+	defer cancel()
 	token := common.UserToken(ctx, r, u)
-	client := NewGitCodeClient(token, false)
+	client := c.newGitCodeClient(token)
 
-	branches, err := client.GetBranches(ctx, r.Owner, r.Name)
+	var branches []*Branch
+	var err error
+	if p == nil || p.All || p.Page <= 0 {
+		branches, err = shared_utils.Paginate(func(page int) ([]*Branch, error) {
+			return client.GetBranches(ctx, r.Owner, r.Name, search, page, c.perPage(ctx))
+		}, -1)
+	} else {
+		perPage := c.perPage(ctx)
+		if p.PerPage > 0 {
+			perPage = min(p.PerPage, maxPageSize)
+		}
+		branches, err = client.GetBranches(ctx, r.Owner, r.Name, search, p.Page, perPage)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -416,28 +1002,48 @@ func (c *GitCode) Branches(ctx context.Context, u *model.User, r *model.Repo, p
 }
 
 func (c *GitCode) BranchHead(ctx context.Context, u *model.User, r *model.Repo, branch string) (*model.Commit, error) {
-	token := common.UserToken(ctx, r, u)
-	client := NewGitCodeClient(token, false)
+	client := c.newGitCodeClient(c.branchHeadToken(ctx, u, r))
 
 	b, err := client.GetBranch(ctx, r.Owner, r.Name, branch)
 	if err != nil {
 		return nil, err
 	}
-	return &model.Commit{
+
+	result := &model.Commit{
 		SHA:      b.Commit.ID,
-		ForgeURL: fmt.Sprintf("%s/%s/%s/commit/%s", defaultURL, r.Owner, r.Name, b.Commit.ID),
-	}, nil
+		ForgeURL: c.links().commit(r.FullName, b.Commit.ID),
+	}
+
+	// 补充提交信息，失败时只返回 SHA
+	commit, err := client.GetCommit(ctx, r.Owner, r.Name, b.Commit.ID)
+	if err != nil {
+		log.Debug().Err(err).Msgf("GitCode: Failed to get commit %s for %s", b.Commit.ID, r.FullName)
+		return result, nil
+	}
+	enrichCommit(result, commit)
+	return result, nil
 }
 
 func (c *GitCode) PullRequests(ctx context.Context, u *model.User, r *model.Repo, p *model.ListOptions) ([]*model.PullRequest, error) {
 	token := common.UserToken(ctx, r, u)
-	client := NewGitCodeClient(token, false)
+	client := c.newGitCodeClient(token)
 
-	pullRequests, err := client.GetPullRequests(ctx, r.Owner, r.Name)
+	var pullRequests []*PullRequest
+	var err error
+	if p == nil || p.All {
+		pullRequests, err = shared_utils.Paginate(func(page int) ([]*PullRequest, error) {
+			return client.GetPullRequests(ctx, r.Owner, r.Name, page, c.perPage(ctx))
+		}, -1)
+	} else {
+		pullRequests, err = client.GetPullRequests(ctx, r.Owner, r.Name, max(p.Page, 1), c.listPerPage(ctx, p))
+	}
 	if err != nil {
+		switch {
 		// Repositories without commits return empty list with status code 404
-		if strings.Contains(err.Error(), "404") {
+		case errors.Is(err, ErrNotFound):
 			return []*model.PullRequest{}, nil
+		case errors.Is(err, ErrUnauthorized):
+			return nil, fmt.Errorf("%w: %s: %w", forge_types.ErrRepoNoPermission, r.FullName, err)
 		}
 		return nil, err
 	}
@@ -450,11 +1056,86 @@ func (c *GitCode) PullRequests(ctx context.Context, u *model.User, r *model.Repo
 }
 
 func (c *GitCode) Hook(ctx context.Context, r *http.Request) (*model.Repo, *model.Pipeline, error) {
-	repo, pipeline, err := parseHook(r)
+	fromComment := r.Header.Get(hookEvent) == hookNote
+	// 系统 webhook 以系统密码校验，服务端已按项目 ID 找到对应的仓库
+	system := isSystemHook(r)
+	var stored *model.Repo
+	if system {
+		if err := c.verifySystemHook(r); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var err error
+		if stored, err = authenticateHook(ctx, r); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 保留请求体以便解析之后再做判断，例如推送事件的提交列表是否被截断、合并请求是否为草稿以及投递的 uuid
+	var payload bytes.Buffer
+	r.Body = io.NopCloser(io.TeeReader(r.Body, &payload))
+	repo, pipeline, err := parseHook(r, c.links())
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// 别名域名（如 gitcode.net）视为同一实例，其他来源的 webhook 直接拒绝
+	if repo != nil {
+		if u, err := url.Parse(repo.ForgeURL); err == nil && u.Host != "" && !c.isInstanceHost(u.Hostname()) {
+			return nil, nil, fmt.Errorf("webhook for %s does not originate from %s", repo.ForgeURL, c.url)
+		}
+		c.canonicalizeRepo(repo)
+		switch {
+		case stored != nil:
+			if err := verifyRepoIdentity(repo, stored); err != nil {
+				rejectedHooks.WithLabelValues("repo_mismatch").Inc()
+				return nil, nil, err
+			}
+		case !system:
+			if err := verifyHookRepo(ctx, r, repo); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if pipeline != nil {
+		pipeline.ForgeURL = c.canonicalURL(pipeline.ForgeURL)
+	}
+
+	// GitCode 会重试未及时响应的投递，已创建过流水线的投递直接丢弃
+	delivery := deliveryID(payload.Bytes())
+	if repo != nil && pipeline != nil && delivery != "" && c.deliveries.handled(repo, delivery) {
+		return nil, nil, duplicateDelivery(r, delivery)
+	}
+
+	// 仓库设置为跳过草稿时不为草稿合并请求创建流水线，标记为就绪时的更新事件会正常触发
+	if repo != nil && pipeline != nil && pipeline.Event == model.EventPull && isDraftMergeRequest(payload.Bytes()) && skipsDrafts(ctx, repo, stored) {
+		return nil, nil, &forge_types.ErrIgnoreEvent{Event: r.Header.Get(hookEvent), Reason: "merge request is a draft"}
+	}
+
+	// 默认只有发布发行版时创建流水线，其他动作（如编辑）需要在 Opts.ReleaseActions 中开启
+	if pipeline != nil && pipeline.Event == model.EventRelease && !c.triggersRelease(pipeline) {
+		return nil, nil, &forge_types.ErrIgnoreEvent{Event: r.Header.Get(hookEvent), Reason: "release action " + pipeline.EventReason[0] + " doesn't start pipelines"}
+	}
+
+	// 补充信息的 API 调用发生在 webhook 请求处理期间，使用较短的超时
+	ctx, cancel := c.timeouts.withOperation(ctx, opHook)
+	defer cancel()
+
+	if repo != nil && pipeline != nil {
+		c.syncDefaultBranch(ctx, repo)
+	}
+
+	// 评论中的命令只有拥有仓库写权限的用户才能触发
+	if pipeline != nil && fromComment {
+		if err := c.verifyCommenter(ctx, repo, pipeline); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if pipeline != nil && pipeline.IsPullRequest() {
+		c.verifyFork(ctx, repo, pipeline)
+	}
+
 	if pipeline != nil && pipeline.Event == model.EventRelease && pipeline.Commit == "" {
 		tagName := strings.Split(pipeline.Ref, "/")[2]
 		sha, err := c.getTagCommitSHA(ctx, repo, tagName)
@@ -464,8 +1145,17 @@ func (c *GitCode) Hook(ctx context.Context, r *http.Request) (*model.Repo, *mode
 		pipeline.Commit = sha
 	}
 
-	if pipeline != nil && (pipeline.Event == model.EventPull || pipeline.Event == model.EventPullClosed) && len(pipeline.ChangedFiles) == 0 {
-		index, err := strconv.ParseInt(strings.Split(pipeline.Ref, "/")[2], 10, 64)
+	if pipeline != nil && pipeline.Event == model.EventPush {
+		c.useSquashMergeMessage(ctx, repo, pipeline)
+		c.completePushChangedFiles(ctx, repo, pipeline, payload.Bytes())
+	}
+
+	if pipeline != nil && (pipeline.Event == model.EventPush || pipeline.Event == model.EventTag) {
+		c.backfillCommitAuthor(ctx, repo, pipeline)
+	}
+
+	if pipeline != nil && pipeline.IsPullRequest() && len(pipeline.ChangedFiles) == 0 {
+		index, err := pullRequestIndex(pipeline)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -475,35 +1165,196 @@ func (c *GitCode) Hook(ctx context.Context, r *http.Request) (*model.Repo, *mode
 		}
 	}
 
+	// 标签变化时 webhook 中的标签可能尚未更新，以 API 返回的为准
+	if pipeline != nil && pipeline.IsPullRequest() && isLabelChange(pipeline) {
+		index, err := pullRequestIndex(pipeline)
+		if err != nil {
+			return nil, nil, err
+		}
+		if labels, err := c.getPullRequestLabels(ctx, repo, index); err == nil {
+			pipeline.PullRequestLabels = labels
+		} else {
+			log.Debug().Err(err).Msgf("could not get labels of PR %s#%d", repo.FullName, index)
+		}
+	}
+
+	if pipeline != nil {
+		pipeline.ChangedFiles = capChangedFiles(pipeline.ChangedFiles, c.maxChangedFiles)
+	}
+
+	// 投递在此只是预留，服务端通过 HookHandled 报告流水线未创建时撤销，重试仍能创建流水线
+	if repo != nil && pipeline != nil && delivery != "" && !c.deliveries.claim(repo, delivery, pipeline) {
+		return nil, nil, duplicateDelivery(r, delivery)
+	}
+
 	return repo, pipeline, nil
 }
 
+// HookHandled 在服务端处理完 Hook 返回的流水线后调用，流水线未创建时撤销预留的投递
+func (c *GitCode) HookHandled(_ context.Context, p *model.Pipeline, created bool) {
+	c.deliveries.settle(p, created)
+}
+
+// duplicateDelivery 返回丢弃重复投递的错误，服务端以 200 响应，GitCode 不再重试
+func duplicateDelivery(r *http.Request, id string) error {
+	return &forge_types.ErrIgnoreEvent{Event: r.Header.Get(hookEvent), Reason: "duplicate delivery " + id}
+}
+
+// OrgMembership 返回用户是否为组织成员以及是否为组织管理员
 func (c *GitCode) OrgMembership(ctx context.Context, u *model.User, owner string) (*model.OrgPerm, error) {
-	// GitCode 暂时不支持组织成员检查，返回默认权限
-	// TODO: 实现 GitCode 组织成员 API 支持
-	return &model.OrgPerm{}, nil
+	membership, err := c.newGitCodeClient(u.AccessToken).GetOrgMembership(ctx, owner, u.Login)
+	if isStatus(err, http.StatusNotFound) {
+		return &model.OrgPerm{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toOrgPerm(membership), nil
 }
 
+// Org 返回仓库所有者的组织信息，owner 不是组织时按用户查询
 func (c *GitCode) Org(ctx context.Context, u *model.User, owner string) (*model.Org, error) {
-	// GitCode 暂时不支持组织信息，返回用户信息
-	// TODO: 实现 GitCode 组织 API 支持
+	client := c.newGitCodeClient(u.AccessToken)
+
+	org, orgErr := client.GetOrg(ctx, owner)
+	if orgErr == nil {
+		return &model.Org{
+			Name:    org.Login,
+			Private: org.Public != nil && !*org.Public,
+		}, nil
+	}
+
+	user, err := client.GetUserByLogin(ctx, owner)
+	if err != nil {
+		if !isStatus(orgErr, http.StatusNotFound) {
+			err = errors.Join(orgErr, err)
+		}
+		return nil, err
+	}
 	return &model.Org{
-		Name:    owner,
-		IsUser:  true,
-		Private: false,
+		Name:   user.Login,
+		IsUser: true,
 	}, nil
 }
 
+// probeAPI 探测实例支持的 API 版本，由 New 在后台调用，请求不会因等待探测而阻塞
+func (c *GitCode) probeAPI(ctx context.Context) {
+	defer close(c.apiProbed)
+	api := probeAPIVersion(ctx, c.apiURL, func(adapter apiAdapter) *GitCodeClient {
+		return NewGitCodeClient("", c.skipVerify, c.clientOptionsFor(adapter)...)
+	})
+	c.api.Store(&api)
+	log.Debug().Msgf("GitCode: using API %s", api.version())
+}
+
+// negotiatedAPI 返回探测到的 API 版本，探测完成前使用 v5
+func (c *GitCode) negotiatedAPI() apiAdapter {
+	if api := c.api.Load(); api != nil {
+		return *api
+	}
+	return v5Adapter{apiURL: c.apiURL}
+}
+
+// maxReleaseAssetSize 限制单个发行版附件的大小
+const maxReleaseAssetSize = 100 << 20 // 100 MiB
+
+// PublishReleaseAsset 将流水线产物上传为触发该流水线的发行版附件
+func (c *GitCode) PublishReleaseAsset(ctx context.Context, u *model.User, r *model.Repo, p *model.Pipeline, name string, content io.ReadSeeker, size int64) error {
+	ctx = withPipeline(ctx, p)
+	if p.Event != model.EventRelease {
+		return fmt.Errorf("pipeline #%d was not triggered by a release", p.Number)
+	}
+	if size > maxReleaseAssetSize {
+		return fmt.Errorf("release asset %s is %d bytes, the limit is %d bytes", name, size, maxReleaseAssetSize)
+	}
+
+	ctx, cancel := c.timeouts.withOperation(ctx, opArchive)
+	defer cancel()
+
+	client := c.newGitCodeClient(common.UserToken(ctx, r, u))
+	tag := strings.TrimPrefix(p.Ref, "refs/tags/")
+	upload, err := client.GetReleaseUploadURL(ctx, r.Owner, r.Name, tag, name)
+	if err != nil {
+		return fmt.Errorf("get upload url for release %s: %w", tag, err)
+	}
+	return client.UploadReleaseAsset(ctx, upload, content, size)
+}
+
 // newGitCodeClient 创建新的 GitCode 客户端
 func (c *GitCode) newGitCodeClient(token string) *GitCodeClient {
-	return NewGitCodeClient(token, false)
+	return NewGitCodeClient(token, c.skipVerify, c.clientOptionsFor(c.negotiatedAPI())...)
+}
+
+// clientOptionsFor 返回使用 adapter 的客户端的选项
+func (c *GitCode) clientOptionsFor(adapter apiAdapter) []ClientOption {
+	opts := []ClientOption{WithAPIAdapter(adapter), WithProxyProfiles(c.proxies), WithAuthMode(c.authMode), WithRetry(c.maxRetries, c.retryBackoff), WithTimeouts(c.timeouts), WithDebugTrace(c.debugTrace), WithMaxResponseSize(c.maxResponseSize)}
+	if c.inflight != nil {
+		opts = append(opts, WithSingleflight(c.inflight))
+	}
+	if c.rateLimits != nil {
+		opts = append(opts, WithRateLimits(c.rateLimits))
+	}
+	if c.responses != nil {
+		opts = append(opts, WithResponseCache(c.responses))
+	}
+	if c.logSampler != nil {
+		opts = append(opts, WithLogSampler(c.logSampler))
+	}
+	return append(opts, c.clientOptions...)
+}
+
+// repoOwnerClient 使用仓库所有者的令牌创建客户端，webhook 请求本身不携带用户
+func (c *GitCode) repoOwnerClient(ctx context.Context, repo *model.Repo) (*GitCodeClient, *model.Repo, error) {
+	_store, ok := store.TryFromContext(ctx)
+	if !ok {
+		return nil, nil, errors.New("could not get store from context")
+	}
+
+	repo, err := _store.GetRepoNameFallback(repo.ForgeRemoteID, repo.FullName)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := _store.GetUser(repo.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.ensureFreshToken(ctx, _store, user)
+	return c.newGitCodeClient(user.AccessToken), repo, nil
+}
+
+// getPullRequestLabels 返回合并请求当前的标签
+func (c *GitCode) getPullRequestLabels(ctx context.Context, repo *model.Repo, index int64) ([]string, error) {
+	client, repo, err := c.repoOwnerClient(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := client.GetPullRequestLabels(ctx, repo.Owner, repo.Name, index)
+	if err != nil {
+		return nil, err
+	}
+	return convertLabels(labels), nil
 }
 
+// getChangedFilesForPR 返回合并请求修改的文件，重命名的文件同时包含新旧路径
 func (c *GitCode) getChangedFilesForPR(ctx context.Context, repo *model.Repo, index int64) ([]string, error) {
-	// GitCode 暂时不支持 PR 文件变更列表，返回空列表
-	// TODO: 实现 GitCode PR 文件变更 API 支持
-	log.Debug().Msgf("GitCode PR changed files not implemented for repo %s PR #%d", repo.FullName, index)
-	return []string{}, nil
+	client, repo, err := c.repoOwnerClient(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := client.GetPullRequestFiles(ctx, repo.Owner, repo.Name, index)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.PreviousFilename != "" {
+			changed = append(changed, file.PreviousFilename)
+		}
+		changed = append(changed, file.Filename)
+	}
+	return shared_utils.Deduplicate(changed), nil
 }
 
 func (c *GitCode) getTagCommitSHA(ctx context.Context, repo *model.Repo, tagName string) (string, error) {
@@ -513,9 +1364,18 @@ func (c *GitCode) getTagCommitSHA(ctx context.Context, repo *model.Repo, tagName
 	return "", nil
 }
 
-func (c *GitCode) perPage(ctx context.Context) int {
-	if c.pageSize == 0 {
-		c.pageSize = defaultPageSize
+// perPage 返回列表请求的默认分页大小，与服务端 API 的默认分页保持一致
+func (c *GitCode) perPage(_ context.Context) int {
+	if c.pageSize <= 0 {
+		return defaultPageSize
 	}
 	return c.pageSize
 }
+
+// listPerPage 返回调用方请求的分页大小，不超过 API 允许的最大分页大小，未指定时使用默认分页大小
+func (c *GitCode) listPerPage(ctx context.Context, p *model.ListOptions) int {
+	if p != nil && p.PerPage > 0 {
+		return min(p.PerPage, maxPageSize)
+	}
+	return c.perPage(ctx)
+}
@@ -15,11 +15,21 @@
 package gitcode
 
 import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
 	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
 )
 
 func TestGitCode(t *testing.T) {
@@ -38,6 +48,49 @@ func TestGitCode(t *testing.T) {
 	assert.Equal(t, "https://gitcode.com", gitcode.URL())
 }
 
+func TestGitCodeSelfHosted(t *testing.T) {
+	forge, err := New(Opts{URL: "https://git.example.com/"})
+	assert.NoError(t, err)
+	c, _ := forge.(*GitCode)
+	assert.Equal(t, "https://git.example.com", c.URL())
+	assert.Equal(t, "https://git.example.com/api/v5", c.apiURL)
+	assert.True(t, c.isInstanceHost("git.example.com"))
+	assert.False(t, c.isInstanceHost("gitcode.net"))
+
+	config, _ := c.oauth2Config(t.Context())
+	assert.Equal(t, "https://git.example.com/oauth/authorize", config.Endpoint.AuthURL)
+
+	forge, err = New(Opts{URL: "https://git.example.com", APIURL: "https://api.example.com/api/v5/"})
+	assert.NoError(t, err)
+	c, _ = forge.(*GitCode)
+	assert.Equal(t, "https://api.example.com/api/v5", c.apiURL)
+}
+
+func TestGitCodeSkipVerify(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v6/user" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"login":"octocat"}`))
+	}))
+	defer srv.Close()
+
+	forge, err := New(Opts{URL: srv.URL, SkipVerify: true})
+	assert.NoError(t, err)
+	c, _ := forge.(*GitCode)
+	<-c.apiProbed
+	assert.Equal(t, apiV6, c.negotiatedAPI().version())
+	user, err := c.newGitCodeClient("token").GetUser(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "octocat", user.Login)
+
+	forge, err = New(Opts{URL: srv.URL})
+	assert.NoError(t, err)
+	_, err = forge.(*GitCode).newGitCodeClient("token").GetUser(t.Context())
+	assert.ErrorContains(t, err, "certificate")
+}
+
 func TestGitCodeNetrc(t *testing.T) {
 	opts := Opts{
 		OAuthClientID:     "test-client-id",
@@ -63,3 +116,724 @@ func TestGitCodeNetrc(t *testing.T) {
 	assert.Equal(t, "gitcode.com", netrc.Machine)
 	assert.Equal(t, model.ForgeTypeGitCode, netrc.Type)
 }
+
+func TestGitCodeNetrcMachineAccount(t *testing.T) {
+	forge, err := New(Opts{
+		GitUsername:     "ci-bot",
+		GitToken:        "bot-token",
+		RepoCredentials: []string{"Org/Special=deploy:deploy-token", "team/*=team-bot:team-token"},
+	})
+	assert.NoError(t, err)
+
+	user := &model.User{Login: "testuser", AccessToken: "test-token"}
+	tests := []struct {
+		owner, name  string
+		login, token string
+	}{
+		{owner: "org", name: "special", login: "deploy", token: "deploy-token"},
+		{owner: "team", name: "app", login: "team-bot", token: "team-token"},
+		{owner: "org", name: "other", login: "ci-bot", token: "bot-token"},
+	}
+	for _, tt := range tests {
+		repo := &model.Repo{Owner: tt.owner, Name: tt.name, Clone: "https://gitcode.com/" + tt.owner + "/" + tt.name + ".git"}
+		netrc, err := forge.Netrc(user, repo)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.login, netrc.Login)
+		assert.Equal(t, tt.token, netrc.Password)
+	}
+
+	_, err = New(Opts{RepoCredentials: []string{"org/repo=secret"}})
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+	_, err = New(Opts{GitToken: "bot-token"})
+	assert.Error(t, err)
+}
+
+func TestBranchHeadMachineAccount(t *testing.T) {
+	user := &model.User{Login: "owner", AccessToken: "owner-token"}
+	repo := &model.Repo{Owner: "org", Name: "app", FullName: "org/app"}
+
+	var auth string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		if req.URL.Path == "/api/v5/repos/org/app/branches/main" {
+			auth = req.Header.Get("Authorization")
+			return http.StatusOK, `{"name":"main","commit":{"id":"abc"}}`
+		}
+		return http.StatusNotFound, `{}`
+	})
+	c.repoCredentials, _ = parseRepoCredentials([]string{"org/*=bot:bot-token"})
+
+	commit, err := c.BranchHead(t.Context(), user, repo, "main")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", commit.SHA)
+	assert.Equal(t, "Bearer owner-token", auth)
+
+	c.branchHeadMachineAccount = true
+	_, err = c.BranchHead(t.Context(), user, repo, "main")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer bot-token", auth)
+
+	// 没有机器账号的仓库继续使用所有者的令牌
+	c.repoCredentials = nil
+	_, err = c.BranchHead(t.Context(), user, repo, "main")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer owner-token", auth)
+}
+
+func TestGitCodeDomainAliases(t *testing.T) {
+	forge, err := New(Opts{DomainAliases: []string{"https://Mirror.example.com/"}})
+	assert.NoError(t, err)
+	c, _ := forge.(*GitCode)
+
+	assert.True(t, c.isInstanceHost("gitcode.com"))
+	assert.True(t, c.isInstanceHost("gitcode.net"))
+	assert.True(t, c.isInstanceHost("mirror.example.com"))
+	assert.False(t, c.isInstanceHost("evil.example.com"))
+
+	repo := &model.Repo{
+		ForgeURL: "https://gitcode.net/owner/repo",
+		Clone:    "https://mirror.example.com/owner/repo.git",
+		CloneSSH: "git@gitcode.net:owner/repo.git",
+	}
+	c.canonicalizeRepo(repo)
+	assert.Equal(t, "https://gitcode.com/owner/repo", repo.ForgeURL)
+	assert.Equal(t, "https://gitcode.com/owner/repo.git", repo.Clone)
+	assert.Equal(t, "git@gitcode.com:owner/repo.git", repo.CloneSSH)
+
+	assert.Equal(t, "https://evil.example.com/owner/repo", c.canonicalURL("https://evil.example.com/owner/repo"))
+}
+
+func TestActivationError(t *testing.T) {
+	user := &model.User{Login: "alice"}
+	repo := &model.Repo{Owner: "owner", Name: "repo", FullName: "owner/repo"}
+
+	stubRepo := func(status int, body string) *GitCodeClient {
+		return NewGitCodeClient("token", false, WithMiddleware(func(http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "/api/v5/repos/owner/repo", req.URL.Path)
+				return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+			})
+		}))
+	}
+
+	forbidden := &APIError{StatusCode: http.StatusForbidden, Message: "forbidden"}
+	err := activationError(t.Context(), nil, user, repo, forbidden)
+	assert.ErrorIs(t, err, forbidden)
+	assert.Contains(t, err.Error(), "lacks the admin permission on repository owner/repo")
+
+	notFound := &APIError{StatusCode: http.StatusNotFound, Message: "not found"}
+	err = activationError(t.Context(), stubRepo(http.StatusNotFound, `{"message":"not found"}`), user, repo, notFound)
+	assert.Contains(t, err.Error(), "could not find repository owner/repo")
+
+	err = activationError(t.Context(), stubRepo(http.StatusOK, `{"full_name":"owner/repo","permission":{"pull":true,"push":true}}`), user, repo, notFound)
+	assert.Contains(t, err.Error(), "lacks the admin permission")
+
+	err = activationError(t.Context(), stubRepo(http.StatusOK, `{"full_name":"owner/repo","permission":{"admin":true}}`), user, repo, notFound)
+	assert.Equal(t, notFound, err)
+
+	network := errors.New("connection refused")
+	assert.Equal(t, network, activationError(t.Context(), nil, user, repo, network))
+}
+
+// newStubGitCode returns a forge whose clients send all requests to handler.
+func newStubGitCode(t *testing.T, handler func(req *http.Request) (int, string)) *GitCode {
+	c, err := newGitCode(Opts{})
+	assert.NoError(t, err)
+	c.clientOptions = []ClientOption{WithMiddleware(func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			status, body := handler(req)
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+		})
+	})}
+	return c
+}
+
+func TestDeactivate(t *testing.T) {
+	user := &model.User{AccessToken: "token"}
+	repo := &model.Repo{Owner: "owner", Name: "repo", FullName: "owner/repo"}
+	link := "https://ci.example.com/api/hook?access_token=abc"
+
+	var deleted []string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Query().Get("page") != "1":
+			return http.StatusOK, `[]`
+		case req.Method == http.MethodGet:
+			return http.StatusOK, `[{"id":1,"url":"https://other.example.com/hook"},{"id":2,"url":"` + link + `"},{"id":3,"url":"` + link + `"},` +
+				`{"id":4,"url":"https://old-ci.example.com/api/hook?access_token=old"}]`
+		case req.URL.Path == "/api/v5/repos/owner/repo/hooks/2":
+			deleted = append(deleted, req.URL.Path)
+			return http.StatusNotFound, `{"message":"hook not found"}`
+		default:
+			deleted = append(deleted, req.URL.Path)
+			return http.StatusNoContent, ""
+		}
+	})
+	assert.NoError(t, c.Deactivate(t.Context(), user, repo, link))
+	assert.Equal(t, []string{"/api/v5/repos/owner/repo/hooks/2", "/api/v5/repos/owner/repo/hooks/3", "/api/v5/repos/owner/repo/hooks/4"}, deleted)
+
+	gone := newStubGitCode(t, func(*http.Request) (int, string) {
+		return http.StatusNotFound, `{"message":"project not found"}`
+	})
+	assert.NoError(t, gone.Deactivate(t.Context(), user, repo, link))
+
+	failing := newStubGitCode(t, func(req *http.Request) (int, string) {
+		if req.Method == http.MethodGet && req.URL.Query().Get("page") != "1" {
+			return http.StatusOK, `[]`
+		}
+		if req.Method == http.MethodGet {
+			return http.StatusOK, `[{"id":2,"url":"` + link + `"}]`
+		}
+		return http.StatusForbidden, `{"message":"forbidden"}`
+	})
+	assert.ErrorContains(t, failing.Deactivate(t.Context(), user, repo, link), "delete webhook 2")
+}
+
+func TestSortRepos(t *testing.T) {
+	repos := []*model.Repo{
+		{Name: "woodpecker", FullName: "zoe/woodpecker", IsFork: true},
+		{Name: "Docs", FullName: "org/Docs"},
+		{Name: "woodpecker", FullName: "adam/woodpecker", IsFork: true},
+		{Name: "woodpecker", FullName: "woodpecker-ci/woodpecker"},
+		{Name: "api", FullName: "org/api"},
+	}
+	sortRepos(repos)
+
+	names := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		names = append(names, repo.FullName)
+	}
+	assert.Equal(t, []string{
+		"org/api",
+		"org/Docs",
+		"woodpecker-ci/woodpecker",
+		"adam/woodpecker",
+		"zoe/woodpecker",
+	}, names)
+}
+
+func TestAuthRefreshesRejectedToken(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/oauth/token", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.Form.Get("grant_type"))
+		assert.Equal(t, "refresh", r.Form.Get("refresh_token"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"access_token":"fresh","token_type":"bearer","refresh_token":"refresh2","expires_in":3600}`)
+	}))
+	defer tokenServer.Close()
+
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		if req.Header.Get("Authorization") != "Bearer fresh" {
+			return http.StatusUnauthorized, `{"message":"token expired"}`
+		}
+		return http.StatusOK, `{"id":"1","login":"alice"}`
+	})
+	c.url = tokenServer.URL
+
+	login, err := c.Auth(t.Context(), "expired", "refresh")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", login)
+
+	_, err = c.Auth(t.Context(), "expired", "")
+	assert.True(t, isStatus(err, http.StatusUnauthorized))
+}
+
+func TestAuthSavesRotatedRefreshToken(t *testing.T) {
+	// GitCode 的 refresh token 只能使用一次
+	rotated := map[string]string{"refresh": "refresh2", "refresh2": "refresh3"}
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		next, ok := rotated[r.Form.Get("refresh_token")]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		delete(rotated, r.Form.Get("refresh_token"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"access_token":"fresh-`+next+`","token_type":"bearer","refresh_token":"`+next+`","expires_in":60}`)
+	}))
+	defer tokenServer.Close()
+
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer fresh-") {
+			return http.StatusUnauthorized, `{"message":"token expired"}`
+		}
+		return http.StatusOK, `{"id":"1","login":"alice"}`
+	})
+	c.url = tokenServer.URL
+
+	stored := &model.User{ID: 1, Login: "alice", AccessToken: "expired", RefreshToken: "refresh"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetUserRemoteID", model.ForgeRemoteID("1"), "alice").Return(stored, nil)
+	mockStore.On("UpdateUser", stored).Return(nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	login, err := c.Auth(ctx, "expired", "refresh")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", login)
+	assert.Equal(t, "fresh-refresh2", stored.AccessToken)
+	assert.Equal(t, "refresh2", stored.RefreshToken)
+
+	// 之后的刷新使用轮换后的 refresh token
+	ok, err := c.Refresh(t.Context(), stored)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "refresh3", stored.RefreshToken)
+}
+
+func TestRefreshAheadOfExpiry(t *testing.T) {
+	var refreshes int
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"access_token":"fresh","token_type":"bearer","refresh_token":"refresh2","expires_in":7200}`)
+	}))
+	defer tokenServer.Close()
+
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		return http.StatusOK, `{"id":"1","login":"alice"}`
+	})
+	c.url = tokenServer.URL
+
+	// 距过期还早，不刷新
+	user := &model.User{Login: "alice", AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(2 * time.Hour).Unix()}
+	ok, err := c.Refresh(t.Context(), user)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0, refreshes)
+
+	// token 尚未过期，但已进入刷新窗口
+	user.Expiry = time.Now().Add(10 * time.Minute).Unix()
+	ok, err = c.Refresh(t.Context(), user)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, refreshes)
+	assert.Equal(t, "fresh", user.AccessToken)
+	assert.Equal(t, "refresh2", user.RefreshToken)
+
+	// 使用仓库所有者的 token 前刷新并保存
+	owner := &model.User{ID: 1, Login: "alice", AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(time.Minute).Unix()}
+	repo := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Owner: "owner", Name: "repo", UserID: 1}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("1"), "owner/repo").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(owner, nil)
+	mockStore.On("UpdateUser", owner).Return(nil)
+	client, _, err := c.repoOwnerClient(store.InjectToContext(t.Context(), mockStore), repo)
+	assert.NoError(t, err)
+	assert.Equal(t, "fresh", client.token)
+	assert.Equal(t, 2, refreshes)
+}
+
+func TestFileForkConfigFromTarget(t *testing.T) {
+	user := &model.User{AccessToken: "token"}
+	repo := &model.Repo{Owner: "owner", Name: "repo", FullName: "owner/repo"}
+	fork := &model.Pipeline{Event: model.EventPull, Commit: "forkcommit", Branch: "main", FromFork: true}
+
+	var refs []string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		refs = append(refs, req.URL.Query().Get("ref"))
+		return http.StatusOK, "steps: []"
+	})
+
+	_, err := c.File(t.Context(), user, repo, fork, ".woodpecker.yaml")
+	assert.NoError(t, err)
+
+	c.forkConfigFromTarget = true
+	_, err = c.File(t.Context(), user, repo, fork, ".woodpecker.yaml")
+	assert.NoError(t, err)
+
+	// 同仓库的合并请求仍使用自己的配置
+	_, err = c.File(t.Context(), user, repo, &model.Pipeline{Event: model.EventPull, Commit: "prcommit", Branch: "main"}, ".woodpecker.yaml")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"forkcommit", "main", "prcommit"}, refs)
+}
+
+func TestFileNotFound(t *testing.T) {
+	user := &model.User{AccessToken: "token"}
+	repo := &model.Repo{Owner: "owner", Name: "repo", FullName: "owner/repo"}
+	status := http.StatusNotFound
+	c := newStubGitCode(t, func(*http.Request) (int, string) {
+		return status, `{"error_code":404,"error_message":"Not Found"}`
+	})
+
+	_, err := c.File(t.Context(), user, repo, &model.Pipeline{Commit: "abc"}, ".woodpecker.yaml")
+	assert.ErrorIs(t, err, &forge_types.ErrConfigNotFound{})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// 其他错误即使消息中包含 404 也不视为配置不存在
+	status = http.StatusInternalServerError
+	_, err = c.File(t.Context(), user, repo, &model.Pipeline{Commit: "abc"}, ".woodpecker.yaml")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, &forge_types.ErrConfigNotFound{})
+}
+
+func TestFileTooLarge(t *testing.T) {
+	user := &model.User{AccessToken: "token"}
+	repo := &model.Repo{Owner: "owner", Name: "repo", FullName: "owner/repo"}
+	c := newStubGitCode(t, func(*http.Request) (int, string) {
+		return http.StatusOK, "steps: [] "
+	})
+	c.maxResponseSize = 9
+
+	_, err := c.File(t.Context(), user, repo, &model.Pipeline{Commit: "abc"}, ".woodpecker.yaml")
+	var tooLarge *ResponseTooLargeError
+	assert.ErrorAs(t, err, &tooLarge)
+	assert.EqualValues(t, 9, tooLarge.Limit)
+	assert.ErrorContains(t, err, ".woodpecker.yaml of owner/repo")
+
+	// 负数表示不限制
+	forge, err := New(Opts{MaxResponseSize: -1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, forge.(*GitCode).maxResponseSize)
+}
+
+func TestParseRepoAffiliation(t *testing.T) {
+	affiliation, err := parseRepoAffiliation([]string{" Owner", "organization_member", "owner", ""})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner", "organization_member"}, affiliation)
+
+	_, err = parseRepoAffiliation([]string{"admin"})
+	assert.Error(t, err)
+}
+
+func TestReposAffiliation(t *testing.T) {
+	var affiliations []string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		affiliations = append(affiliations, req.URL.Query().Get("affiliation"))
+		return http.StatusOK, `[]`
+	})
+	c.repoAffiliation = []string{"owner", "collaborator"}
+
+	_, err := c.Repos(t.Context(), &model.User{AccessToken: "token"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner,collaborator"}, affiliations)
+}
+
+func TestTeams(t *testing.T) {
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/api/v5/user/orgs", req.URL.Path)
+		if req.URL.Query().Get("page") != "1" {
+			return http.StatusOK, `[]`
+		}
+		return http.StatusOK, `[{"id":1,"login":"woodpecker","avatar_url":"https://gitcode.com/avatar.png"},{"id":2,"login":"empty"}]`
+	})
+
+	teams, err := c.Teams(t.Context(), &model.User{AccessToken: "token"})
+	assert.NoError(t, err)
+	assert.Len(t, teams, 2)
+	assert.Equal(t, "woodpecker", teams[0].Login)
+	assert.Equal(t, "https://gitcode.com/avatar.png", teams[0].Avatar)
+	assert.Equal(t, "empty", teams[1].Login)
+	assert.NotEmpty(t, teams[1].Avatar)
+}
+
+func TestOrgMembership(t *testing.T) {
+	responses := map[string]string{
+		"admin":    `{"active":true,"role":"admin"}`,
+		"member":   `{"role":"member"}`,
+		"inactive": `{"active":false,"role":"admin"}`,
+	}
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		login := strings.TrimPrefix(req.URL.Path, "/api/v5/orgs/woodpecker/memberships/")
+		if body, ok := responses[login]; ok {
+			return http.StatusOK, body
+		}
+		return http.StatusNotFound, `{"message":"404 Not Found"}`
+	})
+
+	tests := []struct {
+		login    string
+		expected *model.OrgPerm
+	}{
+		{login: "admin", expected: &model.OrgPerm{Member: true, Admin: true}},
+		{login: "member", expected: &model.OrgPerm{Member: true}},
+		{login: "inactive", expected: &model.OrgPerm{}},
+		{login: "stranger", expected: &model.OrgPerm{}},
+	}
+	for _, tt := range tests {
+		perm, err := c.OrgMembership(t.Context(), &model.User{Login: tt.login, AccessToken: "token"}, "woodpecker")
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, perm, tt.login)
+	}
+
+	perm, err := c.TeamPerm(&model.User{Login: "admin", AccessToken: "token"}, "woodpecker")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Perm{Pull: true, Push: true, Admin: true}, perm)
+	perm, err = c.TeamPerm(&model.User{Login: "member", AccessToken: "token"}, "woodpecker")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Perm{Pull: true}, perm)
+}
+
+func TestOrg(t *testing.T) {
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		switch req.URL.Path {
+		case "/api/v5/orgs/woodpecker":
+			return http.StatusOK, `{"id":1,"login":"woodpecker","public":false}`
+		case "/api/v5/users/octocat":
+			return http.StatusOK, `{"id":2,"login":"octocat"}`
+		}
+		return http.StatusNotFound, `{"message":"404 Not Found"}`
+	})
+	user := &model.User{AccessToken: "token"}
+
+	org, err := c.Org(t.Context(), user, "woodpecker")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Org{Name: "woodpecker", Private: true}, org)
+
+	org, err = c.Org(t.Context(), user, "octocat")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Org{Name: "octocat", IsUser: true}, org)
+
+	_, err = c.Org(t.Context(), user, "ghost")
+	assert.True(t, isStatus(err, http.StatusNotFound))
+}
+
+func TestChangedFilesForPR(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Owner: "owner", Name: "repo", UserID: 1}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("1"), "owner/repo").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token"}, nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/api/v5/repos/owner/repo/pulls/3/files", req.URL.Path)
+		return http.StatusOK, `[
+			{"filename":"main.go","status":"modified"},
+			{"filename":"docs/new.md","previous_filename":"docs/old.md","status":"renamed"},
+			{"filename":"main.go","status":"modified"}
+		]`
+	})
+
+	files, err := c.getChangedFilesForPR(ctx, repo, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main.go", "docs/old.md", "docs/new.md"}, files)
+}
+
+func TestHookFetchesLabelsOnLabelChange(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "7720285", FullName: "jetsung/testci", Owner: "jetsung", Name: "testci", UserID: 1, Hash: "hash"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("7720285"), "jetsung/testci").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token"}, nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		switch req.URL.Path {
+		case "/api/v5/repos/jetsung/testci/pulls/3/labels":
+			return http.StatusOK, `[{"id":1,"name":"bug"},{"id":2,"name":"deploy"}]`
+		case "/api/v5/repos/jetsung/testci/pulls/3/files":
+			return http.StatusOK, `[{"filename":"main.go"}]`
+		}
+		return http.StatusNotFound, `{}`
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(`{
+		"user": {"username": "jetsung"},
+		"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci", "web_url": "https://gitcode.com/jetsung/testci"},
+		"merge_request": {"id": 1, "iid": 3, "action": "update", "state": "opened", "source_branch": "dev", "target_branch": "main", "last_commit": {"id": "abc"}},
+		"labels": [{"id": 1, "title": "bug"}],
+		"changes": {"labels": {"previous": [{"id": 1, "title": "bug"}], "current": [{"id": 1, "title": "bug"}, {"id": 2, "title": "deploy"}]}}
+	}`))
+	req.Header.Set(hookEvent, hookMergeRequest)
+	req.Header.Set(hookToken, webhookSecret(repo))
+
+	_, pipeline, err := c.Hook(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, model.EventPullMetadata, pipeline.Event)
+	assert.Equal(t, []string{"label_updated"}, pipeline.EventReason)
+	assert.Equal(t, []string{"bug", "deploy"}, pipeline.PullRequestLabels)
+}
+
+func TestRepoByID(t *testing.T) {
+	var paths []string
+	byID := http.StatusOK
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		paths = append(paths, req.URL.Path)
+		switch req.URL.Path {
+		case "/api/v5/repos/owner/repo":
+			return http.StatusOK, `{"id":1,"full_name":"owner/repo","path":"repo","namespace":{"path":"owner"}}`
+		case "/api/v5/repositories/2":
+			return byID, `{"id":2,"full_name":"owner/renamed","path":"renamed","namespace":{"path":"owner"}}`
+		case "/api/v5/user/repos":
+			if req.URL.Query().Get("page") != "1" {
+				return http.StatusOK, `[]`
+			}
+			return http.StatusOK, `[{"id":2,"full_name":"owner/renamed","path":"renamed","namespace":{"path":"owner"}}]`
+		}
+		return http.StatusNotFound, `{}`
+	})
+	user := &model.User{AccessToken: "token"}
+
+	repo, err := c.Repo(t.Context(), user, "1", "owner", "repo")
+	assert.NoError(t, err)
+	assert.Equal(t, "owner/repo", repo.FullName)
+	assert.Equal(t, []string{"/api/v5/repos/owner/repo"}, paths)
+
+	// 仓库改名后 owner/name 对应其他仓库
+	paths = nil
+	repo, err = c.Repo(t.Context(), user, "2", "owner", "repo")
+	assert.NoError(t, err)
+	assert.Equal(t, "owner/renamed", repo.FullName)
+	assert.Equal(t, []string{"/api/v5/repos/owner/repo", "/api/v5/repositories/2"}, paths)
+
+	// 实例不支持按 ID 查询
+	byID = http.StatusNotFound
+	repo, err = c.Repo(t.Context(), user, "2", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "owner/renamed", repo.FullName)
+
+	_, err = c.Repo(t.Context(), user, "3", "", "")
+	assert.ErrorIs(t, err, forge_types.ErrRepoNotFound)
+}
+
+func TestPageSize(t *testing.T) {
+	for size, expected := range map[int]int{0: defaultPageSize, 20: 20, 500: maxPageSize} {
+		forge, err := New(Opts{PageSize: size})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, forge.(*GitCode).perPage(t.Context()), size)
+	}
+
+	var perPage []string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		perPage = append(perPage, req.URL.Query().Get("per_page"))
+		return http.StatusOK, `[]`
+	})
+	c.pageSize = 80
+	_, err := c.Repos(t.Context(), &model.User{AccessToken: "token"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"80"}, perPage)
+
+	for requested, expected := range map[int]int{0: 80, 30: 30, 500: maxPageSize} {
+		assert.Equal(t, expected, c.listPerPage(t.Context(), &model.ListOptions{PerPage: requested}), requested)
+	}
+}
+
+func TestBranchesPagination(t *testing.T) {
+	var queries []string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		queries = append(queries, req.URL.Query().Get("page")+"/"+req.URL.Query().Get("per_page"))
+		if req.URL.Query().Get("page") == "3" {
+			return http.StatusOK, `[]`
+		}
+		return http.StatusOK, `[{"name":"main"},{"name":"dev"}]`
+	})
+	user := &model.User{AccessToken: "token"}
+	repo := &model.Repo{Owner: "owner", Name: "repo"}
+
+	branches, err := c.Branches(t.Context(), user, repo, &model.ListOptions{Page: 2, PerPage: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main", "dev"}, branches)
+	assert.Equal(t, []string{"2/2"}, queries)
+
+	queries = nil
+	branches, err = c.Branches(t.Context(), user, repo, &model.ListOptions{All: true})
+	assert.NoError(t, err)
+	assert.Len(t, branches, 4)
+	assert.Equal(t, []string{"1/50", "2/50", "3/50"}, queries)
+}
+
+func TestDirFetchesConcurrently(t *testing.T) {
+	var inflight, peak atomic.Int32
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		if strings.HasSuffix(req.URL.Path, "/git/trees/abc") {
+			return http.StatusOK, `{"tree":[
+				{"path":".woodpecker/a.yaml","type":"blob"},
+				{"path":".woodpecker/b.yaml","type":"blob"},
+				{"path":".woodpecker/c.yaml","type":"blob"},
+				{"path":".woodpecker/d.yaml","type":"blob"},
+				{"path":".woodpecker/e.yaml","type":"blob"},
+				{"path":".woodpecker/nested/f.yaml","type":"blob"},
+				{"path":"README.md","type":"blob"}
+			]}`
+		}
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if strings.HasSuffix(req.URL.Path, "/c.yaml") {
+			return http.StatusInternalServerError, `{}`
+		}
+		return http.StatusOK, "steps: []"
+	})
+
+	files, err := c.Dir(t.Context(), &model.User{AccessToken: "token"}, &model.Repo{Owner: "owner", Name: "repo"}, &model.Pipeline{Commit: "abc"}, ".woodpecker")
+	assert.NoError(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{".woodpecker/a.yaml", ".woodpecker/b.yaml", ".woodpecker/d.yaml", ".woodpecker/e.yaml"}, names)
+	assert.LessOrEqual(t, peak.Load(), int32(dirFetchConcurrency))
+	assert.Greater(t, peak.Load(), int32(1))
+}
+
+func TestDirSkipsOversizedFiles(t *testing.T) {
+	var fetched []string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/tarball"):
+			return http.StatusNotFound, `{}`
+		case strings.HasSuffix(req.URL.Path, "/git/trees/abc"):
+			return http.StatusOK, `{"tree":[
+				{"path":".woodpecker/a.yaml","type":"blob","sha":"a1"},
+				{"path":".woodpecker/huge.yaml","type":"blob"},
+				{"path":".woodpecker/sized.yaml","type":"blob","size":2097152}
+			]}`
+		case strings.HasSuffix(req.URL.Path, "/contents/.woodpecker"):
+			assert.Equal(t, "abc", req.URL.Query().Get("ref"))
+			return http.StatusOK, `[
+				{"type":"file","path":".woodpecker/a.yaml","size":10,"sha":"a1"},
+				{"type":"file","path":".woodpecker/huge.yaml","size":5242880,"sha":"h1"}
+			]`
+		}
+		fetched = append(fetched, req.URL.Path)
+		return http.StatusOK, "steps: []"
+	})
+
+	files, err := c.Dir(t.Context(), &model.User{AccessToken: "token"}, &model.Repo{Owner: "owner", Name: "repo"}, &model.Pipeline{Commit: "abc"}, ".woodpecker")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, ".woodpecker/a.yaml", files[0].Name)
+	assert.Equal(t, "a1", files[0].SHA)
+	assert.Equal(t, []string{"/api/v5/repos/owner/repo/raw/.woodpecker/a.yaml"}, fetched)
+}
+
+func TestDirResolvesBranchHead(t *testing.T) {
+	var trees []string
+	treeStatus := http.StatusOK
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		switch {
+		case req.URL.Path == "/api/v5/repos/owner/repo/branches/dev":
+			return http.StatusOK, `{"name":"dev","commit":{"id":"def"}}`
+		case strings.Contains(req.URL.Path, "/git/trees/"):
+			trees = append(trees, req.URL.Path)
+			return treeStatus, `{"tree":[{"path":".woodpecker/build.yaml","type":"blob"}]}`
+		case strings.Contains(req.URL.Path, "/raw/"):
+			return http.StatusOK, "steps: []"
+		}
+		return http.StatusNotFound, `{}`
+	})
+	user := &model.User{AccessToken: "token"}
+	repo := &model.Repo{Owner: "owner", Name: "repo", Branch: "main"}
+
+	files, err := c.Dir(t.Context(), user, repo, &model.Pipeline{Branch: "dev"}, ".woodpecker")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, []string{"/api/v5/repos/owner/repo/git/trees/def"}, trees)
+
+	// 分支不存在时继续查找其他配置，其他错误不再被当作空目录
+	_, err = c.Dir(t.Context(), user, repo, &model.Pipeline{Branch: "gone"}, ".woodpecker")
+	assert.ErrorIs(t, err, &forge_types.ErrConfigNotFound{})
+	treeStatus = http.StatusInternalServerError
+	_, err = c.Dir(t.Context(), user, repo, &model.Pipeline{Commit: "abc"}, ".woodpecker")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, &forge_types.ErrConfigNotFound{})
+}
@@ -15,13 +15,18 @@
 package gitcode
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"io"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/common"
 	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	shared_utils "go.woodpecker-ci.org/woodpecker/v3/shared/utils"
 )
 
 // toRepo converts a GitCode repository to a Woodpecker repository.
@@ -33,20 +38,24 @@ import (
 // 这个函数在 convert.go 中已经定义，这里移除重复定义
 
 // pipelineFromPush extracts the Pipeline data from a GitCode push hook.
-func pipelineFromPush(hook *pushHook) *model.Pipeline {
+func pipelineFromPush(links linkBuilder, hook *pushHook) *model.Pipeline {
 	// 使用用户头像
-	avatar := expandAvatar(defaultURL, fixMalformedAvatar(hook.UserAvatar))
+	avatar := links.avatarOr(orDefault(hook.UserEmail, hook.UserUsername), fixMalformedAvatar(hook.UserAvatar))
 
 	var message string
 	var link string
+	email := hook.UserEmail
 
-	// 使用提交信息
+	// 使用提交信息，推送者未公开邮箱时使用提交作者的邮箱
 	if len(hook.Commits) > 0 {
 		message = hook.Commits[0].Message
 		link = hook.Commits[0].URL
+		email = orDefault(email, hook.Commits[0].Author.Email)
 	} else {
 		message = hook.Message
-		link = hook.Project.WebURL
+	}
+	if link == "" {
+		link = links.commit(hook.Project.PathWithNamespace, hook.After)
 	}
 
 	return &model.Pipeline{
@@ -58,8 +67,8 @@ func pipelineFromPush(hook *pushHook) *model.Pipeline {
 		Message:      message,
 		Avatar:       avatar,
 		Author:       hook.UserUsername,
-		Email:        hook.UserEmail,
-		Timestamp:    time.Now().UTC().Unix(),
+		Email:        email,
+		Timestamp:    commitTimestamp(hook, hook.After),
 		Sender:       hook.UserUsername,
 		ChangedFiles: getChangedFilesFromPushHook(hook),
 	}
@@ -71,116 +80,365 @@ func getChangedFilesFromPushHook(hook *pushHook) []string {
 		files = append(files, commit.Added...)
 		files = append(files, commit.Modified...)
 		files = append(files, commit.Removed...)
-	}
-
-	// 去重
-	seen := make(map[string]bool)
-	var uniqueFiles []string
-	for _, file := range files {
-		if !seen[file] {
-			seen[file] = true
-			uniqueFiles = append(uniqueFiles, file)
+		// 重命名同时记录新旧路径，使路径过滤对两者都生效
+		for _, rename := range commit.Renamed {
+			files = append(files, rename.OldPath, rename.NewPath)
 		}
 	}
 
-	return uniqueFiles
+	return shared_utils.Deduplicate(files)
+}
+
+// changedFilesOverflow 标记变更文件列表因超过上限而被截断
+const changedFilesOverflow = "..."
+
+// capChangedFiles 将变更文件列表限制为 limit 个，截断时在末尾追加 changedFilesOverflow；
+// limit 小于等于 0 时不限制
+func capChangedFiles(files []string, limit int) []string {
+	if limit <= 0 || len(files) <= limit {
+		return files
+	}
+	capped, truncated := shared_utils.DeduplicateLimit(files, limit)
+	if truncated {
+		capped = append(capped, changedFilesOverflow)
+	}
+	return capped
 }
 
 // pipelineFromTag extracts the Pipeline data from a GitCode tag hook.
-func pipelineFromTag(hook *pushHook) *model.Pipeline {
-	avatar := expandAvatar(defaultURL, fixMalformedAvatar(hook.UserAvatar))
+func pipelineFromTag(links linkBuilder, hook *pushHook) *model.Pipeline {
+	avatar := links.avatarOr(orDefault(hook.UserEmail, hook.UserUsername), fixMalformedAvatar(hook.UserAvatar))
 	ref := strings.TrimPrefix(hook.Ref, "refs/tags/")
 
 	return &model.Pipeline{
-		Event:     model.EventTag,
-		Commit:    hook.After,
+		Event: model.EventTag,
+		// 附注标签的 after 是标签对象，checkout_sha 才是被标记的提交
+		Commit:    orDefault(hook.CheckoutSha, hook.After),
 		Ref:       fmt.Sprintf("refs/tags/%s", ref),
-		ForgeURL:  fmt.Sprintf("%s/src/tag/%s", hook.Project.WebURL, ref),
+		ForgeURL:  links.tree(hook.Project.PathWithNamespace, ref),
 		Message:   fmt.Sprintf("created tag %s", ref),
 		Avatar:    avatar,
 		Author:    hook.UserUsername,
 		Sender:    hook.UserUsername,
 		Email:     hook.UserEmail,
-		Timestamp: time.Now().UTC().Unix(),
+		Timestamp: commitTimestamp(hook, orDefault(hook.CheckoutSha, hook.After)),
+	}
+}
+
+// hookTimeLayouts 是 webhook 中时间字段可能使用的格式
+var hookTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05 -0700", "2006-01-02 15:04:05 MST"}
+
+// commitTimestamp 返回推送中提交 sha 的时间，找不到该提交时使用第一个提交的时间。
+// webhook 可能延迟送达，使用收到的时间会使流水线的排队时长等统计产生偏差，没有可用的时间时才使用当前时间
+func commitTimestamp(hook *pushHook, sha string) int64 {
+	var timestamp string
+	for i, commit := range hook.Commits {
+		if i == 0 || commit.ID == sha {
+			timestamp = commit.Timestamp
+		}
+		if commit.ID == sha {
+			break
+		}
 	}
+	if t, ok := parseHookTime(timestamp); ok {
+		return t.UTC().Unix()
+	}
+	return time.Now().UTC().Unix()
+}
+
+// parseHookTime 按 hookTimeLayouts 解析 webhook 中的时间
+func parseHookTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range hookTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// 关闭合并请求的流水线通过 CI_PIPELINE_EVENT_REASON 区分合并与直接关闭
+const (
+	pullReasonMerged = "merged"
+	pullReasonClosed = "closed"
+)
+
+// pullReasonApproved 是合并请求被批准时 pull_request_metadata 流水线的事件原因
+const pullReasonApproved = "approved"
+
+// isApprovalAction 判断合并请求 webhook 的动作是否为批准，不同实例使用 approved 或 approval
+func isApprovalAction(action string) bool {
+	return action == "approved" || action == "approval"
 }
 
 // pipelineFromPullRequestHook extracts the Pipeline data from a GitCode pull_request hook.
-func pipelineFromPullRequestHook(hook *pullRequestHook) *model.Pipeline {
-	avatar := expandAvatar(
-		hook.Project.WebURL,
-		fixMalformedAvatar(hook.User.AvatarURL),
-	)
+func pipelineFromPullRequestHook(links linkBuilder, hook *pullRequestHook) *model.Pipeline {
+	avatar := links.avatarOr(orDefault(hook.User.Email, hook.User.Username), fixMalformedAvatar(hook.User.AvatarURL))
+
+	link := hook.MergeRequest.URL
+	if link == "" {
+		link = links.mergeRequest(hook.Project.PathWithNamespace, hook.MergeRequest.IID)
+	}
 
 	event := model.EventPull
-	if hook.MergeRequest.Action == "close" || hook.MergeRequest.State == "closed" {
+	var reason []string
+	switch {
+	// 批准时合并请求仍为打开状态，需先于状态判断
+	case isApprovalAction(hook.MergeRequest.Action):
+		event = model.EventPullMetadata
+		reason = []string{pullReasonApproved}
+	case hook.MergeRequest.Action == "merge" || hook.MergeRequest.State == "merged":
+		event = model.EventPullClosed
+		reason = []string{pullReasonMerged}
+	case hook.MergeRequest.Action == "close" || hook.MergeRequest.State == "closed":
 		event = model.EventPullClosed
+		reason = []string{pullReasonClosed}
+	}
+
+	mr := hook.MergeRequest
+	commit := mr.LastCommit.ID
+	ref := fmt.Sprintf("refs/pull/%d/head", mr.IID)
+	refspec := fmt.Sprintf("%s:%s", mr.SourceBranch, mr.TargetBranch)
+	// 合并产生的提交只在目标分支上，克隆时需要拉取目标分支而不是合并请求的引用
+	if event == model.EventPullClosed && reason[0] == pullReasonMerged {
+		commit = mergedCommit(hook)
+		ref = "refs/heads/" + mr.TargetBranch
+		refspec = fmt.Sprintf("%s:%s", mr.TargetBranch, mr.TargetBranch)
 	}
 
 	pipeline := &model.Pipeline{
-		Event:    event,
-		Commit:   hook.MergeRequest.LastCommit.ID,
-		ForgeURL: hook.MergeRequest.URL,
-		Ref:      fmt.Sprintf("refs/pull/%d/head", hook.MergeRequest.IID),
-		Branch:   hook.MergeRequest.TargetBranch,
-		Message:  hook.MergeRequest.Title,
-		Author:   hook.User.Username,
-		Avatar:   avatar,
-		Sender:   hook.User.Username,
-		Email:    hook.User.Email,
-		Title:    hook.MergeRequest.Title,
-		Refspec: fmt.Sprintf("%s:%s",
-			hook.MergeRequest.SourceBranch,
-			hook.MergeRequest.TargetBranch,
+		Event:             event,
+		EventReason:       reason,
+		Commit:            commit,
+		ForgeURL:          link,
+		Ref:               ref,
+		Branch:            hook.MergeRequest.TargetBranch,
+		Message:           hook.MergeRequest.Title,
+		Author:            orDefault(hook.MergeRequest.Author.Username, hook.User.Username),
+		Avatar:            avatar,
+		Sender:            hook.User.Username,
+		Email:             hook.User.Email,
+		Title:             hook.MergeRequest.Title,
+		Refspec:           refspec,
+		PullRequestLabels: convertLabels(hook.Labels),
+		FromFork: isForkMergeRequest(
+			mergeRequestProject{id: cmp.Or(mr.Source.ID, mr.SourceProjectID), path: mr.Source.PathWithNamespace},
+			mergeRequestProject{id: cmp.Or(mr.Target.ID, mr.TargetProjectID, hook.Project.ID), path: cmp.Or(mr.Target.PathWithNamespace, hook.Project.PathWithNamespace)},
 		),
-		PullRequestLabels: []string{}, // GitCode 暂时不支持标签
-		FromFork:          hook.MergeRequest.Source.ID != hook.MergeRequest.Target.ID,
 	}
 
+	if event == model.EventPull {
+		pipeline.EventReason = labelChangeReason(hook)
+		if markedReady(hook) {
+			pipeline.EventReason = append(pipeline.EventReason, pullReasonReady)
+		} else if len(pipeline.EventReason) != 0 {
+			// 与其他 forge 一致，只修改标签时触发 pull_request_metadata 流水线，不重新运行合并请求流水线
+			pipeline.Event = model.EventPullMetadata
+		}
+	}
 	return pipeline
 }
 
+// pullRequestIndex 返回合并请求流水线的合并请求编号。合并后的流水线使用目标分支的引用，
+// 此时从合并请求的链接中读取编号
+func pullRequestIndex(pipeline *model.Pipeline) (int64, error) {
+	if rest, ok := strings.CutPrefix(pipeline.Ref, "refs/pull/"); ok {
+		return strconv.ParseInt(strings.Split(rest, "/")[0], 10, 64)
+	}
+	return strconv.ParseInt(path.Base(pipeline.ForgeURL), 10, 64)
+}
+
+// mergeRequestProject 是合并请求源或目标项目的标识
+type mergeRequestProject struct {
+	id   int
+	path string
+}
+
+// isForkMergeRequest 判断合并请求是否来自复刻仓库。优先比较项目 ID，缺失时比较项目路径；
+// 两者都无法确定时按复刻处理，使流水线经过审批并且拿不到受保护的密钥
+func isForkMergeRequest(source, target mergeRequestProject) bool {
+	if source.id != 0 && target.id != 0 {
+		return source.id != target.id
+	}
+	if source.path != "" && target.path != "" {
+		return !strings.EqualFold(source.path, target.path)
+	}
+	return true
+}
+
+// mergedCommit 返回合并后目标分支上的提交。压缩合并后源分支的最新提交不在目标分支上，
+// 源分支也可能已被删除，因此优先使用合并产生的提交
+func mergedCommit(hook *pullRequestHook) string {
+	mr := hook.MergeRequest
+	return cmp.Or(mr.MergeCommitSHA, mr.TargetBranchCommit.ID, mr.LastCommit.ID)
+}
+
+// convertLabels 返回标签名称
+func convertLabels(from []*Label) []string {
+	labels := make([]string, 0, len(from))
+	for _, label := range from {
+		if name := orDefault(label.Title, label.Name); name != "" {
+			labels = append(labels, name)
+		}
+	}
+	return labels
+}
+
+// labelChangeReason 返回标签变化对应的事件原因，与其他 forge 保持一致
+func labelChangeReason(hook *pullRequestHook) []string {
+	change := hook.Changes.Labels
+	if change == nil {
+		return nil
+	}
+	switch {
+	case len(change.Current) != 0 && len(change.Previous) == 0:
+		return []string{common.NormalizeEventReason("labels_added")}
+	case len(change.Current) == 0 && len(change.Previous) != 0:
+		return []string{common.NormalizeEventReason("labels_cleared")}
+	case len(change.Current) != 0:
+		return []string{common.NormalizeEventReason("labels_updated")}
+	}
+	return nil
+}
+
+// isLabelChange 判断流水线是否由合并请求标签变化触发
+func isLabelChange(p *model.Pipeline) bool {
+	for _, reason := range p.EventReason {
+		if strings.HasPrefix(reason, "label_") {
+			return true
+		}
+	}
+	return false
+}
+
+// webhook 中非公开项目的可见性级别，与 GitLab 一致，公开项目为 20
+const (
+	visibilityPrivate  = 0
+	visibilityInternal = 10
+)
+
+// isPrivateVisibility 判断可见性级别是否为非公开。内部仓库只对实例的登录用户可见，
+// 按私有仓库处理，避免匿名用户看到徽章、日志和流水线
+func isPrivateVisibility(level int) bool {
+	return level == visibilityPrivate || level == visibilityInternal
+}
+
 // repoFromPullRequestHook extracts the Repository data from a GitCode pull_request hook.
-func repoFromPullRequestHook(hook *pullRequestHook) *model.Repo {
+func repoFromPullRequestHook(links linkBuilder, hook *pullRequestHook) *model.Repo {
+	fullName := hook.Project.PathWithNamespace
 	return &model.Repo{
 		ForgeRemoteID: model.ForgeRemoteID(fmt.Sprintf("%d", hook.Project.ID)),
 		Owner:         hook.Project.Namespace,
 		Name:          hook.Project.Name,
-		FullName:      hook.Project.PathWithNamespace,
-		Avatar:        hook.Project.AvatarURL,
-		ForgeURL:      hook.Project.WebURL,
-		Clone:         hook.Project.GitHTTPURL,
-		CloneSSH:      hook.Project.GitSSHURL,
+		FullName:      fullName,
+		Avatar:        links.avatarOr(fullName, hook.Project.AvatarURL),
+		ForgeURL:      orDefault(hook.Project.WebURL, links.repo(fullName)),
+		Clone:         orDefault(hook.Project.GitHTTPURL, links.cloneHTTP(fullName)),
+		CloneSSH:      orDefault(hook.Project.GitSSHURL, links.cloneSSH(fullName)),
 		Branch:        hook.Project.DefaultBranch,
-		IsSCMPrivate:  hook.Project.VisibilityLevel == 0,
-		Perm: &model.Perm{
-			Pull:  true,
-			Push:  true,
-			Admin: false,
-		},
+		IsSCMPrivate:  isPrivateVisibility(hook.Project.VisibilityLevel),
 	}
 }
 
-func pipelineFromRelease(hook *releaseHook) *model.Pipeline {
-	avatar := expandAvatar(
-		hook.Repo.HTTPURLToRepo,
-		fixMalformedAvatar(hook.Sender.AvatarURL),
-	)
+// repoFromPushHook extracts the Repository data from a GitCode push or tag push hook.
+func repoFromPushHook(links linkBuilder, hook *pushHook) *model.Repo {
+	return repoFromProject(links, hook.ProjectID, &hook.Project)
+}
+
+// repoFromProject 由 webhook 中的项目信息构建仓库，id 为 webhook 顶层的项目 ID
+func repoFromProject(links linkBuilder, id int, project *hookProject) *model.Repo {
+	fullName := project.PathWithNamespace
+	return &model.Repo{
+		ForgeRemoteID: model.ForgeRemoteID(fmt.Sprintf("%d", id)),
+		Owner:         project.Namespace,
+		Name:          project.Name,
+		FullName:      fullName,
+		Avatar:        links.avatarOr(fullName, project.AvatarURL),
+		ForgeURL:      orDefault(project.WebURL, links.repo(fullName)),
+		Clone:         orDefault(project.GitHTTPURL, links.cloneHTTP(fullName)),
+		CloneSSH:      orDefault(project.GitSSHURL, links.cloneSSH(fullName)),
+		Branch:        project.DefaultBranch,
+		IsSCMPrivate:  isPrivateVisibility(project.VisibilityLevel),
+	}
+}
+
+// pipelineFromDeployment extracts the Pipeline data from a GitCode deployment hook.
+func pipelineFromDeployment(links linkBuilder, hook *deploymentHook) *model.Pipeline {
+	pipeline := &model.Pipeline{
+		Event:      model.EventDeploy,
+		Commit:     hook.SHA,
+		Ref:        hook.Ref,
+		Branch:     hook.Ref,
+		ForgeURL:   orDefault(hook.CommitURL, links.commit(hook.Project.PathWithNamespace, hook.SHA)),
+		Message:    orDefault(hook.Description, hook.CommitTitle),
+		Avatar:     links.avatarOr(orDefault(hook.User.Email, hook.User.Username), fixMalformedAvatar(hook.User.AvatarURL)),
+		Author:     hook.User.Username,
+		Sender:     hook.User.Username,
+		Email:      hook.User.Email,
+		DeployTo:   hook.Environment,
+		DeployTask: hook.Task,
+		Timestamp:  time.Now().UTC().Unix(),
+	}
+
+	switch {
+	// 部署提交时使用默认分支
+	case pipeline.Ref == "" || strings.HasPrefix(hook.SHA, pipeline.Ref):
+		pipeline.Branch = hook.Project.DefaultBranch
+		pipeline.Ref = "refs/heads/" + pipeline.Branch
+	case strings.HasPrefix(pipeline.Ref, "refs/heads/"):
+		pipeline.Branch = strings.TrimPrefix(pipeline.Ref, "refs/heads/")
+	case !strings.HasPrefix(pipeline.Ref, "refs/"):
+		pipeline.Ref = "refs/heads/" + pipeline.Ref
+	}
+	return pipeline
+}
+
+// orDefault returns value, or fallback if value is empty.
+func orDefault(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+func pipelineFromRelease(links linkBuilder, hook *releaseHook) *model.Pipeline {
+	avatar := links.avatarOr(orDefault(hook.Sender.Email, hook.Sender.Login), fixMalformedAvatar(hook.Sender.AvatarURL))
 
 	return &model.Pipeline{
-		Event:        model.EventRelease,
-		Ref:          fmt.Sprintf("refs/tags/%s", hook.Release.TagName),
-		ForgeURL:     fmt.Sprintf("%s/%s/releases/tag/%s", defaultURL, hook.Repo.FullName, hook.Release.TagName),
-		Branch:       hook.Repo.DefaultBranch,
-		Message:      fmt.Sprintf("created release %s", hook.Release.Name),
-		Avatar:       avatar,
-		Author:       hook.Sender.Login,
-		Sender:       hook.Sender.Login,
-		Email:        hook.Sender.Email,
-		IsPrerelease: hook.Release.Prerelease,
+		Event:         model.EventRelease,
+		EventReason:   []string{releaseAction(hook)},
+		Ref:           fmt.Sprintf("refs/tags/%s", hook.Release.TagName),
+		ForgeURL:      links.release(hook.Repo.FullName, hook.Release.TagName),
+		Branch:        hook.Repo.DefaultBranch,
+		Message:       fmt.Sprintf("%s release %s", releaseAction(hook), hook.Release.Name),
+		Avatar:        avatar,
+		Author:        hook.Sender.Login,
+		Sender:        hook.Sender.Login,
+		Email:         hook.Sender.Email,
+		IsPrerelease:  hook.Release.Prerelease,
+		ReleaseAssets: toReleaseAssets(hook.Release.Assets),
 	}
 }
 
+// toReleaseAssets 转换发行版附件，跳过没有下载地址的条目
+func toReleaseAssets(from []*ReleaseAsset) []*model.ReleaseAsset {
+	var assets []*model.ReleaseAsset
+	for _, asset := range from {
+		if asset == nil || asset.BrowserDownloadURL == "" {
+			continue
+		}
+		assets = append(assets, &model.ReleaseAsset{
+			Name: asset.Name,
+			URL:  asset.BrowserDownloadURL,
+			Size: asset.Size,
+		})
+	}
+	return assets
+}
+
 // parsePush parses a push hook from a read closer.
 func parsePush(r io.Reader) (*pushHook, error) {
 	push := new(pushHook)
@@ -194,12 +452,33 @@ func parsePullRequest(r io.Reader) (*pullRequestHook, error) {
 	return pr, err
 }
 
+func parseDeployment(r io.Reader) (*deploymentHook, error) {
+	deployment := new(deploymentHook)
+	err := json.NewDecoder(r).Decode(deployment)
+	return deployment, err
+}
+
 func parseRelease(r io.Reader) (*releaseHook, error) {
 	pr := new(releaseHook)
 	err := json.NewDecoder(r).Decode(pr)
 	return pr, err
 }
 
+// maxConfigFileSize is the largest blob Dir() will download as a pipeline config.
+const maxConfigFileSize = 1 << 20
+
+// isConfigCandidate reports whether a tree entry could be a pipeline config,
+// so images, binaries and other unrelated files are not downloaded.
+// A size of 0 means the API did not report it.
+func isConfigCandidate(name string, size int64) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yml", ".yaml":
+	default:
+		return false
+	}
+	return size <= maxConfigFileSize
+}
+
 // fixMalformedAvatar fixes an avatar url if malformed (currently a known bug with gitcode).
 func fixMalformedAvatar(url string) string {
 	index := strings.Index(url, "///")
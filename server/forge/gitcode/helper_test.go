@@ -15,9 +15,13 @@
 package gitcode
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
 )
 
 func TestFixMalformedAvatar(t *testing.T) {
@@ -82,6 +86,156 @@ func TestExpandAvatar(t *testing.T) {
 
 // TestConvertLabels 测试标签转换（GitCode 暂时不支持标签）
 func TestConvertLabels(t *testing.T) {
-	// GitCode 暂时不支持标签功能，跳过此测试
-	t.Skip("GitCode labels not implemented yet")
+	labels := convertLabels([]*Label{{Title: "bug"}, {Name: "ci"}, {}})
+	assert.Equal(t, []string{"bug", "ci"}, labels)
+	assert.Equal(t, []string{}, convertLabels(nil))
+}
+
+func TestIsConfigCandidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		size     int64
+		expected bool
+	}{
+		{name: "yaml", path: ".woodpecker/build.yaml", expected: true},
+		{name: "yml upper case", path: ".woodpecker/BUILD.YML", size: 120, expected: true},
+		{name: "image", path: ".woodpecker/logo.png", size: 120},
+		{name: "no extension", path: ".woodpecker/Makefile"},
+		{name: "too large", path: ".woodpecker/huge.yaml", size: maxConfigFileSize + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isConfigCandidate(tt.path, tt.size))
+		})
+	}
+}
+
+func TestInstanceLinks(t *testing.T) {
+	links := newLinkBuilder("https://git.example.com")
+
+	push := &pushHook{After: "abc123", Ref: "refs/heads/main"}
+	push.Project.PathWithNamespace = "group/sub/repo"
+
+	tag := &pushHook{After: "abc123", Ref: "refs/tags/v1.0.0"}
+	tag.Project.PathWithNamespace = "group/sub/repo"
+
+	pr := &pullRequestHook{}
+	pr.Project.PathWithNamespace = "group/sub/repo"
+	pr.MergeRequest.IID = 7
+
+	release := &releaseHook{
+		Repo:    &Repository{FullName: "owner/repo"},
+		Sender:  &User{},
+		Release: &Release{TagName: "v1.0.0"},
+	}
+
+	tests := []struct {
+		name     string
+		actual   string
+		expected string
+	}{
+		{name: "push", actual: pipelineFromPush(links, push).ForgeURL, expected: "https://git.example.com/group/sub/repo/commit/abc123"},
+		{name: "tag", actual: pipelineFromTag(links, tag).ForgeURL, expected: "https://git.example.com/group/sub/repo/tree/v1.0.0"},
+		{name: "pull request", actual: pipelineFromPullRequestHook(links, pr).ForgeURL, expected: "https://git.example.com/group/sub/repo/merge_requests/7"},
+		{name: "release", actual: pipelineFromRelease(links, release).ForgeURL, expected: "https://git.example.com/owner/repo/releases/tag/v1.0.0"},
+		{name: "push repo", actual: repoFromPushHook(links, push).ForgeURL, expected: "https://git.example.com/group/sub/repo"},
+		{name: "push repo clone", actual: repoFromPushHook(links, push).Clone, expected: "https://git.example.com/group/sub/repo.git"},
+		{name: "pull request repo ssh", actual: repoFromPullRequestHook(links, pr).CloneSSH, expected: "git@git.example.com:group/sub/repo.git"},
+		{name: "repo", actual: toRepo(links, &Repository{FullName: "owner/repo"}).ForgeURL, expected: "https://git.example.com/owner/repo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.actual)
+		})
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	hook := &pushHook{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"commits":[
+		{"added":["a.go"],"modified":["b.go"],"renamed":[{"old_path":"old/c.go","new_path":"new/c.go"}]},
+		{"modified":["a.go"],"removed":["d.go"]}
+	]}`), hook))
+
+	files := getChangedFilesFromPushHook(hook)
+	assert.ElementsMatch(t, []string{"a.go", "b.go", "old/c.go", "new/c.go", "d.go"}, files)
+
+	assert.Equal(t, files, capChangedFiles(files, 0))
+	assert.Equal(t, files, capChangedFiles(files, len(files)))
+	assert.Equal(t, []string{"a", "b", changedFilesOverflow}, capChangedFiles([]string{"a", "b", "a", "c"}, 2))
+	assert.Equal(t, []string{"a", "b"}, capChangedFiles([]string{"a", "b", "a"}, 2))
+}
+
+func TestCommitTimestamp(t *testing.T) {
+	hook := &pushHook{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"commits":[
+		{"id":"aaa","timestamp":"2025-10-01T09:33:54Z"},
+		{"id":"bbb","timestamp":"2025-10-01T17:50:05+08:00"},
+		{"id":"ccc","timestamp":"2025-10-01 09:55:00 +0800"}
+	]}`), hook))
+
+	assert.EqualValues(t, 1759312205, commitTimestamp(hook, "bbb"))
+	assert.EqualValues(t, 1759283700, commitTimestamp(hook, "ccc"))
+	// 找不到提交时使用第一个提交的时间
+	assert.EqualValues(t, 1759311234, commitTimestamp(hook, "ddd"))
+
+	// 没有可用的时间时使用当前时间
+	before := time.Now().Unix()
+	assert.GreaterOrEqual(t, commitTimestamp(&pushHook{}, "aaa"), before)
+	hook.Commits[0].Timestamp = "yesterday"
+	assert.GreaterOrEqual(t, commitTimestamp(hook, "aaa"), before)
+}
+
+func TestReleaseAssets(t *testing.T) {
+	release := &releaseHook{
+		Repo:   &Repository{FullName: "owner/repo"},
+		Sender: &User{Login: "alice"},
+		Release: &Release{TagName: "v1.0.0", Assets: []*ReleaseAsset{
+			{Name: "app.tar.gz", BrowserDownloadURL: "https://gitcode.com/owner/repo/releases/download/v1.0.0/app.tar.gz", Size: 1024},
+			{Name: "pending.zip"},
+			nil,
+		}},
+	}
+
+	pipeline := pipelineFromRelease(newLinkBuilder("https://gitcode.com"), release)
+	assert.Equal(t, []*model.ReleaseAsset{
+		{Name: "app.tar.gz", URL: "https://gitcode.com/owner/repo/releases/download/v1.0.0/app.tar.gz", Size: 1024},
+	}, pipeline.ReleaseAssets)
+
+	release.Release.Assets = nil
+	assert.Nil(t, pipelineFromRelease(newLinkBuilder("https://gitcode.com"), release).ReleaseAssets)
+}
+
+func TestIsPrivateVisibility(t *testing.T) {
+	assert.True(t, isPrivateVisibility(0))
+	assert.True(t, isPrivateVisibility(10))
+	assert.False(t, isPrivateVisibility(20))
+}
+
+func TestPipelineFromPullRequestHookRef(t *testing.T) {
+	tests := []struct {
+		name, mergeRequest, commit, ref, refspec string
+	}{
+		{name: "open", mergeRequest: `"action": "open", "state": "opened"`, commit: "source-head", ref: "refs/pull/3/head", refspec: "dev:main"},
+		{name: "closed", mergeRequest: `"action": "close", "state": "closed"`, commit: "source-head", ref: "refs/pull/3/head", refspec: "dev:main"},
+		{name: "squash merged", mergeRequest: `"action": "merge", "state": "merged", "merge_commit_sha": "squashed"`, commit: "squashed", ref: "refs/heads/main", refspec: "main:main"},
+		{name: "merged without merge commit", mergeRequest: `"action": "merge", "state": "merged", "target_branch_commit": {"id": "target-head"}`, commit: "target-head", ref: "refs/heads/main", refspec: "main:main"},
+	}
+	for _, tt := range tests {
+		hook := new(pullRequestHook)
+		assert.NoError(t, json.Unmarshal([]byte(`{"merge_request": {"iid": 3, "source_branch": "dev", "target_branch": "main",
+			"last_commit": {"id": "source-head"}, `+tt.mergeRequest+`}}`), hook), tt.name)
+
+		pipeline := pipelineFromPullRequestHook(newLinkBuilder(defaultURL), hook)
+		assert.Equal(t, tt.commit, pipeline.Commit, tt.name)
+		assert.Equal(t, tt.ref, pipeline.Ref, tt.name)
+		assert.Equal(t, tt.refspec, pipeline.Refspec, tt.name)
+
+		index, err := pullRequestIndex(pipeline)
+		assert.NoError(t, err, tt.name)
+		assert.EqualValues(t, 3, index, tt.name)
+	}
 }
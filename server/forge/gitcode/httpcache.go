@@ -0,0 +1,149 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+)
+
+const (
+	// responseCacheTTL 是未被使用的响应保留以供重新验证的时间
+	responseCacheTTL = 24 * time.Hour
+	// maxCachedBodySize 限制缓存的响应大小，归档等更大的响应不缓存
+	maxCachedBodySize = 1 << 20
+)
+
+// cachedResponse 是 GET 响应及其验证信息
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+// responseCache 缓存带有 ETag 或 Last-Modified 响应头的 GET 响应，之后对同一地址的请求
+// 以条件请求发送。资源未变化时 GitCode 返回不带响应体的 304 Not Modified，
+// 在频繁同步仓库时节省带宽
+type responseCache struct {
+	entries *ttlcache.Cache[string, *cachedResponse]
+}
+
+// newResponseCache 最多缓存 size 个响应，size 小于等于 0 时禁用缓存并返回 nil
+func newResponseCache(size int) *responseCache {
+	if size <= 0 {
+		return nil
+	}
+	return &responseCache{entries: ttlcache.New(
+		ttlcache.WithTTL[string, *cachedResponse](responseCacheTTL),
+		ttlcache.WithCapacity[string, *cachedResponse](uint64(size)),
+	)}
+}
+
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	if item := c.entries.Get(key); item != nil {
+		return item.Value(), true
+	}
+	return nil, false
+}
+
+func (c *responseCache) set(key string, cached *cachedResponse) {
+	c.entries.Set(key, cached, ttlcache.DefaultTTL)
+}
+
+// cacheMiddleware 将已缓存地址的 GET 请求以条件请求发送，GitCode 报告未修改时返回缓存的响应。
+// 响应取决于 token 的权限，因此按 token 分别缓存
+func cacheMiddleware(cache *responseCache, token string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if cache == nil {
+			return next
+		}
+		prefix := tokenKey(token) + " "
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet {
+				return next.RoundTrip(req)
+			}
+
+			key := prefix + req.URL.String()
+			cached, ok := cache.get(key)
+			if ok {
+				req = req.Clone(req.Context())
+				if cached.etag != "" {
+					req.Header.Set("If-None-Match", cached.etag)
+				}
+				if cached.lastModified != "" {
+					req.Header.Set("If-Modified-Since", cached.lastModified)
+				}
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			if ok && resp.StatusCode == http.StatusNotModified {
+				_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+				resp.Body.Close()
+				return cached.response(resp.Request), nil
+			}
+			return storeResponse(cache, key, resp)
+		})
+	}
+}
+
+// storeResponse 缓存带有验证信息的成功响应，并返回响应体仍可读取的 resp
+func storeResponse(cache *responseCache, key string, resp *http.Response) (*http.Response, error) {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBodySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCachedBodySize {
+		// 太大无法缓存，将已读取的部分与剩余内容一起交给调用方
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	cache.set(key, &cachedResponse{etag: etag, lastModified: lastModified, header: resp.Header.Clone(), body: body})
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// response 返回 req 对应的缓存响应
+func (r *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	login := "jetsung"
+	var conditional []string
+	stub := WithMiddleware(func(http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			etag := `"` + login + `"`
+			conditional = append(conditional, req.Header.Get("If-None-Match"))
+			if req.Header.Get("If-None-Match") == etag {
+				return &http.Response{StatusCode: http.StatusNotModified, Header: http.Header{"Etag": {etag}}, Body: http.NoBody, Request: req}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Etag": {etag}},
+				Body:       io.NopCloser(strings.NewReader(`{"login":"` + login + `"}`)),
+				Request:    req,
+			}, nil
+		})
+	})
+	cache := newResponseCache(10)
+	client := NewGitCodeClient("token", false, WithResponseCache(cache), stub)
+
+	for range 2 {
+		user, err := client.GetUser(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, "jetsung", user.Login)
+	}
+	assert.Equal(t, []string{"", `"jetsung"`}, conditional)
+
+	// a changed resource is fetched again and replaces the cached response
+	login = "renamed"
+	user, err := client.GetUser(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", user.Login)
+
+	// responses are not shared between tokens
+	conditional = nil
+	_, err = NewGitCodeClient("other", false, WithResponseCache(cache), stub).GetUser(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, conditional)
+
+	// without a cache no conditional requests are sent
+	conditional = nil
+	client = NewGitCodeClient("token", false, stub)
+	for range 2 {
+		_, err := client.GetUser(t.Context())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"", ""}, conditional)
+}
+
+func TestResponseCacheSkipsLargeBodies(t *testing.T) {
+	cache := newResponseCache(10)
+	body := strings.Repeat("a", maxCachedBodySize+10)
+	resp, err := storeResponse(cache, "key", &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {`"large"`}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	})
+	assert.NoError(t, err)
+
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(data))
+	_, ok := cache.get("key")
+	assert.False(t, ok)
+}
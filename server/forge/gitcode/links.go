@@ -0,0 +1,109 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// linkBuilder builds web and clone URLs for a GitCode instance.
+// Full names may contain nested namespaces ("group/subgroup/repo").
+type linkBuilder struct {
+	baseURL string
+}
+
+func newLinkBuilder(baseURL string) linkBuilder {
+	return linkBuilder{baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// host 返回实例的主机名（含端口）
+func (l linkBuilder) host() string {
+	u, err := url.Parse(l.baseURL)
+	if err != nil || u.Host == "" {
+		return strings.TrimPrefix(strings.TrimPrefix(l.baseURL, "https://"), "http://")
+	}
+	return u.Host
+}
+
+// hostname 返回实例的主机名（不含端口），SSH 不使用 HTTP 端口
+func (l linkBuilder) hostname() string {
+	u, err := url.Parse(l.baseURL)
+	if err != nil || u.Hostname() == "" {
+		return l.host()
+	}
+	return u.Hostname()
+}
+
+// repo 返回仓库主页地址
+func (l linkBuilder) repo(fullName string) string {
+	return l.baseURL + "/" + escapePath(fullName)
+}
+
+// commit 返回提交详情页地址
+func (l linkBuilder) commit(fullName, sha string) string {
+	return l.repo(fullName) + "/commit/" + escapeSegment(sha)
+}
+
+// tree 返回分支或标签的文件树地址
+func (l linkBuilder) tree(fullName, ref string) string {
+	return l.repo(fullName) + "/tree/" + escapePath(ref)
+}
+
+// release 返回发行版页面地址
+func (l linkBuilder) release(fullName, tag string) string {
+	return l.repo(fullName) + "/releases/tag/" + escapePath(tag)
+}
+
+// mergeRequest 返回合并请求页面地址
+func (l linkBuilder) mergeRequest(fullName string, index int) string {
+	return l.repo(fullName) + "/merge_requests/" + strconv.Itoa(index)
+}
+
+// cloneHTTP 返回 HTTPS 克隆地址
+func (l linkBuilder) cloneHTTP(fullName string) string {
+	return l.repo(fullName) + ".git"
+}
+
+// cloneSSH 返回 SSH 克隆地址
+func (l linkBuilder) cloneSSH(fullName string) string {
+	return "git@" + l.hostname() + ":" + fullName + ".git"
+}
+
+// avatar 将相对头像地址补全为实例上的绝对地址
+func (l linkBuilder) avatar(avatarURL string) string {
+	return expandAvatar(l.baseURL, avatarURL)
+}
+
+// identiconBase 为没有头像的用户和仓库生成 identicon
+const identiconBase = "https://www.gravatar.com/avatar"
+
+// avatarOr 返回第一个非空头像的绝对地址；都为空时根据 seed（邮箱、用户名或仓库全名）
+// 生成确定性的 identicon，避免前端显示损坏的图片
+func (l linkBuilder) avatarOr(seed string, avatarURLs ...string) string {
+	for _, avatarURL := range avatarURLs {
+		if avatarURL != "" {
+			return l.avatar(avatarURL)
+		}
+	}
+	if seed == "" {
+		return ""
+	}
+	hash := md5.Sum([]byte(strings.ToLower(strings.TrimSpace(seed))))
+	return identiconBase + "/" + hex.EncodeToString(hash[:]) + "?d=identicon&s=128"
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkBuilder(t *testing.T) {
+	links := newLinkBuilder("https://git.example.com:8443/")
+
+	assert.Equal(t, "git.example.com:8443", links.host())
+	assert.Equal(t, "https://git.example.com:8443/group/sub/repo", links.repo("group/sub/repo"))
+	assert.Equal(t, "https://git.example.com:8443/group/sub/repo/commit/abc123", links.commit("group/sub/repo", "abc123"))
+	assert.Equal(t, "https://git.example.com:8443/owner/repo/tree/feature/x%231", links.tree("owner/repo", "feature/x#1"))
+	assert.Equal(t, "https://git.example.com:8443/owner/repo/releases/tag/v1.0.0", links.release("owner/repo", "v1.0.0"))
+	assert.Equal(t, "https://git.example.com:8443/owner/repo/merge_requests/4", links.mergeRequest("owner/repo", 4))
+	assert.Equal(t, "https://git.example.com:8443/group/sub/repo.git", links.cloneHTTP("group/sub/repo"))
+	assert.Equal(t, "git@git.example.com:group/sub/repo.git", links.cloneSSH("group/sub/repo"))
+	assert.Equal(t, "https://git.example.com:8443/avatars/u.png", links.avatar("/avatars/u.png"))
+}
+
+func TestAvatarFallback(t *testing.T) {
+	links := newLinkBuilder("https://gitcode.com")
+
+	assert.Equal(t, "https://gitcode.com/avatars/repo.png", links.avatarOr("owner/repo", "", "/avatars/repo.png"))
+	assert.Equal(t, "https://cdn.example.com/u.png", links.avatarOr("owner/repo", "https://cdn.example.com/u.png", "/avatars/repo.png"))
+
+	identicon := links.avatarOr("Alice@Example.com ")
+	assert.Equal(t, "https://www.gravatar.com/avatar/c160f8cc69a4f0bf2b0362752353d060?d=identicon&s=128", identicon)
+	assert.Equal(t, identicon, links.avatarOr("alice@example.com", ""))
+	assert.NotEqual(t, identicon, links.avatarOr("owner/repo"))
+	assert.Empty(t, links.avatarOr(""))
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	prometheus_auto "github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	apiRequests = prometheus_auto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "woodpecker",
+		Subsystem: "gitcode",
+		Name:      "api_requests_total",
+		Help:      "Total number of GitCode API requests.",
+	}, []string{"method", "endpoint", "status"})
+	apiRequestDuration = prometheus_auto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "woodpecker",
+		Subsystem: "gitcode",
+		Name:      "api_request_duration_seconds",
+		Help:      "Duration of GitCode API requests including retries.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "endpoint"})
+	apiRetries = prometheus_auto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "woodpecker",
+		Subsystem: "gitcode",
+		Name:      "api_retries_total",
+		Help:      "Total number of retried GitCode API requests.",
+	}, []string{"method", "endpoint"})
+	rejectedHooks = prometheus_auto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "woodpecker",
+		Subsystem: "gitcode",
+		Name:      "webhooks_rejected_total",
+		Help:      "Total number of GitCode webhook deliveries rejected because of their token, secret or repository.",
+	}, []string{"reason"})
+	unsupportedHooks = prometheus_auto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "woodpecker",
+		Subsystem: "gitcode",
+		Name:      "webhooks_unsupported_total",
+		Help:      "Total number of ignored GitCode webhook deliveries of kinds that don't start pipelines.",
+	}, []string{"kind"})
+)
+
+// hookKindPattern 匹配可以原样作为标签值的 webhook 类型，例如 "Issue Hook"
+var hookKindPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z _]{0,31}$`)
+
+// hookKindLabel 在 webhook 类型形如 GitCode 的 webhook 名称时将其作为标签值。类型由调用方发送，
+// 其他值统一记为 "other"，以限制时间序列的数量
+func hookKindLabel(kind string) string {
+	switch {
+	case kind == "":
+		return "none"
+	case hookKindPattern.MatchString(kind):
+		return kind
+	default:
+		return "other"
+	}
+}
+
+// apiVersionPrefix 匹配 API 路径的版本前缀，例如 "/api/v5"
+var apiVersionPrefix = regexp.MustCompile(`^/api/v\d+`)
+
+// endpointLabel 将请求路径转换为接口，例如 "/repos/:owner/:repo/pulls/:id/files"，
+// 避免名称、ID 和 SHA 使时间序列的数量暴增
+func endpointLabel(path string) string {
+	segments := strings.Split(strings.Trim(apiVersionPrefix.ReplaceAllString(path, ""), "/"), "/")
+	switch segments[0] {
+	case "", "user":
+		return "/" + strings.Join(segments, "/")
+	case "repos":
+		if len(segments) == 3 && segments[2] == "issues" {
+			return "/repos/:owner/issues"
+		}
+		label := []string{"repos"}
+		for i, placeholder := range []string{":owner", ":repo"} {
+			if i+1 < len(segments) {
+				label = append(label, placeholder)
+			}
+		}
+		if len(segments) > 3 {
+			label = append(label, resourceLabel(segments[3:])...)
+		}
+		return "/" + strings.Join(label, "/")
+	default:
+		return "/" + strings.Join(resourceLabel(segments), "/")
+	}
+}
+
+// resourceLabel 替换每个资源后面的 ID，例如 "hooks/42" 变为 "hooks/:id"，
+// 原始文件的路径合并为 ":path"
+func resourceLabel(segments []string) []string {
+	label := make([]string, 0, len(segments))
+	for i := 0; i < len(segments); i++ {
+		label = append(label, segments[i])
+		switch segments[i] {
+		case "git":
+			continue
+		case "raw", "contents":
+			if i+1 < len(segments) {
+				label = append(label, ":path")
+			}
+			return label
+		}
+		if i+1 < len(segments) {
+			label = append(label, ":id")
+			i++
+		}
+	}
+	return label
+}
+
+// metricsMiddleware 按接口统计请求数、重试次数和请求耗时
+func metricsMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			endpoint := endpointLabel(req.URL.Path)
+			apiRequestDuration.WithLabelValues(req.Method, endpoint).Observe(time.Since(start).Seconds())
+
+			status, retries := "error", 0
+			var retryErr *RetryError
+			switch {
+			case err == nil:
+				status, retries = strconv.Itoa(resp.StatusCode), retriesOf(resp)
+			case errors.As(err, &retryErr):
+				retries = retryErr.Retries
+			}
+			apiRequests.WithLabelValues(req.Method, endpoint, status).Inc()
+			if retries > 0 {
+				apiRetries.WithLabelValues(req.Method, endpoint).Add(float64(retries))
+			}
+			return resp, err
+		})
+	}
+}
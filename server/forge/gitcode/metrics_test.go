@@ -0,0 +1,79 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointLabel(t *testing.T) {
+	for path, want := range map[string]string{
+		"/api/v5/user":                                            "/user",
+		"/api/v5/user/repos":                                      "/user/repos",
+		"/api/v5/users/jetsung":                                   "/users/:id",
+		"/api/v5/orgs/org/memberships/jetsung":                    "/orgs/:id/memberships/:id",
+		"/api/v5/repositories/42":                                 "/repositories/:id",
+		"/api/v5/repos/jetsung/testci":                            "/repos/:owner/:repo",
+		"/api/v5/repos/jetsung/issues":                            "/repos/:owner/issues",
+		"/api/v5/repos/jetsung/testci/pulls/3/files":              "/repos/:owner/:repo/pulls/:id/files",
+		"/api/v5/repos/jetsung/testci/collaborators/a/permission": "/repos/:owner/:repo/collaborators/:id/permission",
+		"/api/v5/repos/jetsung/testci/git/trees/abc":              "/repos/:owner/:repo/git/trees/:id",
+		"/api/v5/repos/jetsung/testci/raw/.woodpecker/build.yaml": "/repos/:owner/:repo/raw/:path",
+		"/api/v5/repos/jetsung/testci/contents/.woodpecker":       "/repos/:owner/:repo/contents/:path",
+		"/api/v5/repos/jetsung/testci/branches/feature%2Flogin":   "/repos/:owner/:repo/branches/:id",
+		"/api/v5/repos/jetsung/testci/releases/v1.0.0/upload_url": "/repos/:owner/:repo/releases/:id/upload_url",
+		"/api/v5/repos/jetsung/testci/commits/abc/comments":       "/repos/:owner/:repo/commits/:id/comments",
+	} {
+		assert.Equal(t, want, endpointLabel(path), path)
+	}
+}
+
+func TestHookKindLabel(t *testing.T) {
+	assert.Equal(t, "Issue Hook", hookKindLabel("Issue Hook"))
+	assert.Equal(t, "wiki_page", hookKindLabel("wiki_page"))
+	assert.Equal(t, "none", hookKindLabel(""))
+	assert.Equal(t, "other", hookKindLabel("<script>"))
+	assert.Equal(t, "other", hookKindLabel(strings.Repeat("a", 40)))
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	calls := 0
+	client := &http.Client{Transport: chain(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+		}
+		return okResponse(), nil
+	}), metricsMiddleware(), retryMiddleware(1, 0))}
+
+	endpoint := "/repos/:owner/:repo/hooks/:id"
+	requests := testutil.ToFloat64(apiRequests.WithLabelValues(http.MethodDelete, endpoint, "200"))
+	retries := testutil.ToFloat64(apiRetries.WithLabelValues(http.MethodDelete, endpoint))
+
+	req, _ := http.NewRequest(http.MethodDelete, "https://api.gitcode.com/api/v5/repos/owner/repo/hooks/7", nil)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, requests+1, testutil.ToFloat64(apiRequests.WithLabelValues(http.MethodDelete, endpoint, "200")))
+	assert.Equal(t, retries+1, testutil.ToFloat64(apiRetries.WithLabelValues(http.MethodDelete, endpoint)))
+}
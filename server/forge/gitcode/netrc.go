@@ -0,0 +1,96 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/common"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// credentials 是克隆代码使用的账号和令牌
+type credentials struct {
+	login string
+	token string
+}
+
+// repoCredentials 按仓库全名或 "owner/*" 指定克隆代码使用的机器账号
+type repoCredentials map[string]credentials
+
+// parseRepoCredentials 解析形如 "owner/repo=login:token" 或 "owner/*=login:token" 的配置
+func parseRepoCredentials(entries []string) (repoCredentials, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	result := make(repoCredentials, len(entries))
+	for _, entry := range entries {
+		repo, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		owner, name, validRepo := strings.Cut(strings.TrimSpace(repo), "/")
+		if !ok || !validRepo || owner == "" || name == "" {
+			return nil, fmt.Errorf("invalid repo credentials for %q, expected <owner>/<repo>=<login>:<token>", repo)
+		}
+		login, token, ok := strings.Cut(strings.TrimSpace(value), ":")
+		if !ok || login == "" || token == "" {
+			// 不在错误信息中输出令牌
+			return nil, fmt.Errorf("invalid repo credentials for %q, expected <owner>/<repo>=<login>:<token>", repo)
+		}
+		result[strings.ToLower(owner+"/"+name)] = credentials{login: login, token: token}
+	}
+	return result, nil
+}
+
+// lookup 返回仓库的机器账号，完整的仓库名优先于 "owner/*"
+func (rc repoCredentials) lookup(r *model.Repo) (credentials, bool) {
+	if creds, ok := rc[strings.ToLower(r.Owner+"/"+r.Name)]; ok {
+		return creds, true
+	}
+	creds, ok := rc[strings.ToLower(r.Owner+"/*")]
+	return creds, ok
+}
+
+// cloneCredentials 返回克隆仓库使用的凭据：依次使用仓库的机器账号、全局机器账号和用户的令牌。
+// 机器账号不依赖激活仓库的用户，该用户的令牌过期或离开组织后仍能克隆
+func (c *GitCode) cloneCredentials(u *model.User, r *model.Repo) credentials {
+	if creds, ok := c.machineCredentials(r); ok {
+		return creds
+	}
+	if u == nil {
+		return credentials{}
+	}
+	return credentials{login: u.Login, token: u.AccessToken}
+}
+
+// machineCredentials 返回仓库的机器账号，未配置仓库的机器账号时使用全局机器账号
+func (c *GitCode) machineCredentials(r *model.Repo) (credentials, bool) {
+	if creds, ok := c.repoCredentials.lookup(r); ok {
+		return creds, true
+	}
+	return c.machineAccount, c.machineAccount.token != ""
+}
+
+// branchHeadToken 返回查询分支最新提交使用的令牌。启用 branchHeadMachineAccount 时优先使用机器账号，
+// 定时任务以仓库所有者的身份运行，其令牌可能无权读取受保护分支
+func (c *GitCode) branchHeadToken(ctx context.Context, u *model.User, r *model.Repo) string {
+	if c.branchHeadMachineAccount {
+		if creds, ok := c.machineCredentials(r); ok {
+			return creds.token
+		}
+	}
+	return common.UserToken(ctx, r, u)
+}
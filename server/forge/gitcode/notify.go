@@ -0,0 +1,167 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/common"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	shared_utils "go.woodpecker-ci.org/woodpecker/v3/shared/utils"
+)
+
+// NotifyFailure 处理失败的流水线：评论默认分支上的失败提交，并在连续失败时创建或更新 Issue
+func (c *GitCode) NotifyFailure(ctx context.Context, u *model.User, r *model.Repo, p *model.Pipeline) error {
+	ctx, cancel := c.timeouts.withOperation(withPipeline(ctx, p), opHook)
+	defer cancel()
+
+	client := c.newGitCodeClient(common.UserToken(ctx, r, u))
+	return errors.Join(
+		c.commentOnFailure(ctx, client, r, p),
+		c.reportRepeatedFailure(ctx, client, r, p),
+	)
+}
+
+func (c *GitCode) commentOnFailure(ctx context.Context, client *GitCodeClient, r *model.Repo, p *model.Pipeline) error {
+	if !r.CommentOnFailure || p.Event != model.EventPush || p.Branch != r.Branch || p.Commit == "" {
+		return nil
+	}
+	_, err := client.CreateCommitComment(ctx, r.Owner, r.Name, p.Commit, failureComment(r, p))
+	return err
+}
+
+// reportRepeatedFailure 在定时任务或默认分支推送流水线连续失败 failureIssueThreshold 次后，
+// 创建 Issue；Issue 已存在时追加评论
+func (c *GitCode) reportRepeatedFailure(ctx context.Context, client *GitCodeClient, r *model.Repo, p *model.Pipeline) error {
+	if c.failureIssueThreshold <= 0 || !isTrackedForFailures(r, p) {
+		return nil
+	}
+
+	_store, ok := store.TryFromContext(ctx)
+	if !ok {
+		return errors.New("could not get store from context")
+	}
+	history, err := _store.GetPipelineList(r, &model.ListOptions{Page: 1, PerPage: failureHistorySize}, &model.PipelineFilter{
+		Branch: p.Branch,
+		Events: []model.WebhookEvent{p.Event},
+	})
+	if err != nil {
+		return err
+	}
+	if consecutiveFailures(p, history) < c.failureIssueThreshold {
+		return nil
+	}
+
+	title := failureIssueTitle(p)
+	link := common.GetPipelineStatusURL(r, p, nil)
+	issue, err := c.findOpenIssue(ctx, client, r, title)
+	if err != nil {
+		return err
+	}
+	if issue != nil {
+		_, err = client.CreateIssueComment(ctx, r.Owner, r.Name, string(issue.Number), fmt.Sprintf("Pipeline #%d failed again: %s", p.Number, link))
+		return err
+	}
+
+	body := fmt.Sprintf("The last %d pipelines failed. Latest failure: pipeline #%d %s", c.failureIssueThreshold, p.Number, link)
+	_, err = client.CreateIssue(ctx, r.Owner, r.Name, title, body)
+	return err
+}
+
+// failureHistorySize 是统计连续失败时读取的最近流水线数量
+const failureHistorySize = 50
+
+// isTrackedForFailures 只统计定时任务和默认分支上的推送
+func isTrackedForFailures(r *model.Repo, p *model.Pipeline) bool {
+	return p.Event == model.EventCron || (p.Event == model.EventPush && p.Branch == r.Branch)
+}
+
+// consecutiveFailures 统计与 p 同一来源（分支、事件、定时任务名）的最近连续失败次数，
+// 尚未结束的流水线被跳过
+func consecutiveFailures(p *model.Pipeline, history []*model.Pipeline) int {
+	count := 0
+	for _, h := range history {
+		if h.Event == model.EventCron && h.Sender != p.Sender {
+			continue
+		}
+		switch h.Status {
+		case model.StatusFailure, model.StatusError:
+			count++
+		case model.StatusPending, model.StatusRunning, model.StatusBlocked:
+			continue
+		default:
+			return count
+		}
+	}
+	return count
+}
+
+func failureIssueTitle(p *model.Pipeline) string {
+	if p.Event == model.EventCron {
+		return fmt.Sprintf("Woodpecker: cron %s keeps failing", p.Sender)
+	}
+	return fmt.Sprintf("Woodpecker: %s keeps failing", p.Branch)
+}
+
+func (c *GitCode) findOpenIssue(ctx context.Context, client *GitCodeClient, r *model.Repo, title string) (*Issue, error) {
+	issues, err := shared_utils.Paginate(func(page int) ([]*Issue, error) {
+		return client.GetIssues(ctx, r.Owner, r.Name, "open", page, c.perPage(ctx))
+	}, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		if issue.Title == title {
+			return issue, nil
+		}
+	}
+	return nil, nil
+}
+
+// failureComment 生成失败通知的评论内容，链接到第一个失败的步骤
+func failureComment(r *model.Repo, p *model.Pipeline) string {
+	link := common.GetPipelineStatusURL(r, p, nil)
+	stepName := ""
+	if step := firstFailedStep(p); step != nil {
+		link = fmt.Sprintf("%s/%d", link, step.PID)
+		stepName = step.Name
+	}
+
+	var b strings.Builder
+	if p.Author != "" {
+		fmt.Fprintf(&b, "@%s ", p.Author)
+	}
+	fmt.Fprintf(&b, "pipeline #%d failed on `%s`", p.Number, p.Branch)
+	if stepName != "" {
+		fmt.Fprintf(&b, " in step `%s`", stepName)
+	}
+	fmt.Fprintf(&b, ": %s", link)
+	return b.String()
+}
+
+func firstFailedStep(p *model.Pipeline) *model.Step {
+	for _, workflow := range p.Workflows {
+		for _, step := range workflow.Children {
+			if step.Failing() {
+				return step
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+func TestFailureComment(t *testing.T) {
+	server.Config.Server.Host = "https://ci.example.com"
+	repo := &model.Repo{ID: 3, Branch: "main"}
+	pipeline := &model.Pipeline{
+		Number: 12,
+		Branch: "main",
+		Author: "octocat",
+		Workflows: []*model.Workflow{{
+			Children: []*model.Step{
+				{PID: 2, Name: "build", State: model.StatusSuccess},
+				{PID: 3, Name: "test", State: model.StatusFailure, Failure: model.FailureFail},
+			},
+		}},
+	}
+
+	assert.Equal(t, "@octocat pipeline #12 failed on `main` in step `test`: https://ci.example.com/repos/3/pipeline/12/3", failureComment(repo, pipeline))
+
+	pipeline.Workflows = nil
+	pipeline.Author = ""
+	assert.Equal(t, "pipeline #12 failed on `main`: https://ci.example.com/repos/3/pipeline/12", failureComment(repo, pipeline))
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	current := &model.Pipeline{Event: model.EventCron, Sender: "nightly"}
+	history := []*model.Pipeline{
+		{Event: model.EventCron, Sender: "nightly", Status: model.StatusFailure},
+		{Event: model.EventCron, Sender: "weekly", Status: model.StatusSuccess},
+		{Event: model.EventCron, Sender: "nightly", Status: model.StatusRunning},
+		{Event: model.EventCron, Sender: "nightly", Status: model.StatusError},
+		{Event: model.EventCron, Sender: "nightly", Status: model.StatusSuccess},
+		{Event: model.EventCron, Sender: "nightly", Status: model.StatusFailure},
+	}
+	assert.Equal(t, 2, consecutiveFailures(current, history))
+
+	push := &model.Pipeline{Event: model.EventPush, Branch: "main"}
+	assert.Equal(t, 0, consecutiveFailures(push, []*model.Pipeline{{Event: model.EventPush, Status: model.StatusSuccess}}))
+	assert.True(t, isTrackedForFailures(&model.Repo{Branch: "main"}, push))
+	assert.False(t, isTrackedForFailures(&model.Repo{Branch: "main"}, &model.Pipeline{Event: model.EventPush, Branch: "dev"}))
+}
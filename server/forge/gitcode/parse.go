@@ -15,6 +15,7 @@
 package gitcode
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -34,6 +35,14 @@ const (
 	hookCreated      = "create"
 	hookPullRequest  = "pull_request"
 	hookRelease      = "release"
+	hookDeployment   = "Deployment Hook"
+	hookNote         = "Note Hook"
+
+	objectKindTagPush = "tag_push"
+	deploymentCreated = "created"
+
+	// emptyCommit 是删除分支或标签时推送事件中的 after
+	emptyCommit = "0000000000000000000000000000000000000000"
 
 	actionOpen   = "opened"
 	actionSync   = "synchronized"
@@ -42,66 +51,68 @@ const (
 )
 
 // parseHook parses a GitCode hook from an http.Request and returns
-// Repo and Pipeline detail. If a hook type is unsupported nil values are returned.
-func parseHook(r *http.Request) (*model.Repo, *model.Pipeline, error) {
+// Repo and Pipeline detail. Unsupported hook types return an ErrIgnoreEvent
+// naming the event, so the hook endpoint answers with 200.
+func parseHook(r *http.Request, links linkBuilder) (*model.Repo, *model.Pipeline, error) {
 	hookType := r.Header.Get(hookEvent)
 	switch hookType {
 	case hookPush:
-		return parsePushHook(r.Body)
+		return parsePushHook(links, r.Body)
 	case hookTagPush:
-		return parseTagPushHook(r.Body)
+		return parseTagPushHook(links, r.Body)
 	case hookMergeRequest:
-		return parseMergeRequestHook(r.Body)
+		return parseMergeRequestHook(links, r.Body)
 	case hookCreated:
-		return parseCreatedHook(r.Body)
+		return parseCreatedHook(links, r.Body)
 	case hookPullRequest:
-		return parsePullRequestHook(r.Body)
+		return parsePullRequestHook(links, r.Body)
 	case hookRelease:
-		return parseReleaseHook(r.Body)
+		return parseReleaseHook(links, r.Body)
+	case hookDeployment:
+		return parseDeploymentHook(links, r.Body)
+	case hookNote:
+		return parseNoteHook(links, r.Body)
 	}
-	log.Debug().Msgf("unsupported hook type: '%s'", hookType)
-	return nil, nil, &types.ErrIgnoreEvent{Event: hookType}
+	return nil, nil, unsupportedHook(hookType, r.Body)
+}
+
+// unsupportedHook 返回忽略不支持的 webhook 的错误。请求头中没有事件名时使用请求体中的 object_kind
+func unsupportedHook(hookType string, payload io.Reader) error {
+	event := hookType
+	if event == "" {
+		var kind struct {
+			ObjectKind string `json:"object_kind"`
+		}
+		_ = json.NewDecoder(payload).Decode(&kind)
+		event = kind.ObjectKind
+	}
+	unsupportedHooks.WithLabelValues(hookKindLabel(event)).Inc()
+	log.Debug().Msgf("unsupported hook type: '%s'", event)
+	return &types.ErrIgnoreEvent{Event: event, Reason: "GitCode hooks of this kind don't start pipelines"}
 }
 
 // parsePushHook parses a push hook and returns the Repo and Pipeline details.
 // If the commit type is unsupported nil values are returned.
-func parsePushHook(payload io.Reader) (repo *model.Repo, pipeline *model.Pipeline, err error) {
+func parsePushHook(links linkBuilder, payload io.Reader) (repo *model.Repo, pipeline *model.Pipeline, err error) {
 	push, err := parsePush(payload)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// ignore push events for tags
-	if strings.HasPrefix(push.Ref, "refs/tags/") {
-		return nil, nil, nil
+	// 部分实例以 Push Hook 发送 object_kind 为 tag_push 的标签推送
+	if isTagPush(push) {
+		return tagPushResult(links, push)
 	}
 
-	// 从 push hook 构建 Repository 对象
-	repo = &model.Repo{
-		ForgeRemoteID: model.ForgeRemoteID(fmt.Sprintf("%d", push.ProjectID)),
-		Owner:         push.Project.Namespace,
-		Name:          push.Project.Name,
-		FullName:      push.Project.PathWithNamespace,
-		Avatar:        push.Project.AvatarURL,
-		ForgeURL:      push.Project.WebURL,
-		Clone:         push.Project.GitHTTPURL,
-		CloneSSH:      push.Project.GitSSHURL,
-		Branch:        push.Project.DefaultBranch,
-		IsSCMPrivate:  push.Project.VisibilityLevel == 0,
-		Perm: &model.Perm{
-			Pull:  true,
-			Push:  true,
-			Admin: false,
-		},
-	}
-
-	pipeline = pipelineFromPush(push)
+	repo = repoFromPushHook(links, push)
+
+	pipeline = pipelineFromPush(links, push)
 	return repo, pipeline, err
 }
 
 // parseCreatedHook parses a push hook and returns the Repo and Pipeline details.
 // If the commit type is unsupported nil values are returned.
-func parseCreatedHook(payload io.Reader) (repo *model.Repo, pipeline *model.Pipeline, err error) {
+func parseCreatedHook(links linkBuilder, payload io.Reader) (repo *model.Repo, pipeline *model.Pipeline, err error) {
 	push, err := parsePush(payload)
 	if err != nil {
 		return nil, nil, err
@@ -112,31 +123,14 @@ func parseCreatedHook(payload io.Reader) (repo *model.Repo, pipeline *model.Pipe
 		return nil, nil, nil
 	}
 
-	// 从 push hook 构建 Repository 对象
-	repo = &model.Repo{
-		ForgeRemoteID: model.ForgeRemoteID(fmt.Sprintf("%d", push.ProjectID)),
-		Owner:         push.Project.Namespace,
-		Name:          push.Project.Name,
-		FullName:      push.Project.PathWithNamespace,
-		Avatar:        push.Project.AvatarURL,
-		ForgeURL:      push.Project.WebURL,
-		Clone:         push.Project.GitHTTPURL,
-		CloneSSH:      push.Project.GitSSHURL,
-		Branch:        push.Project.DefaultBranch,
-		IsSCMPrivate:  push.Project.VisibilityLevel == 0,
-		Perm: &model.Perm{
-			Pull:  true,
-			Push:  true,
-			Admin: false,
-		},
-	}
-
-	pipeline = pipelineFromTag(push)
+	repo = repoFromPushHook(links, push)
+
+	pipeline = pipelineFromTag(links, push)
 	return repo, pipeline, nil
 }
 
 // parsePullRequestHook parses a pull_request hook and returns the Repo and Pipeline details.
-func parsePullRequestHook(payload io.Reader) (*model.Repo, *model.Pipeline, error) {
+func parsePullRequestHook(links linkBuilder, payload io.Reader) (*model.Repo, *model.Pipeline, error) {
 	var (
 		repo     *model.Repo
 		pipeline *model.Pipeline
@@ -157,61 +151,76 @@ func parsePullRequestHook(payload io.Reader) (*model.Repo, *model.Pipeline, erro
 	if action != "open" &&
 		action != "update" &&
 		action != "close" &&
-		action != "reopen" {
+		action != "merge" &&
+		action != "reopen" &&
+		!isApprovalAction(action) {
 		log.Debug().Msgf("pull_request action is '%s' and not supported", action)
 		return nil, nil, nil
 	}
 
-	repo = repoFromPullRequestHook(pr)
-	pipeline = pipelineFromPullRequestHook(pr)
+	repo = repoFromPullRequestHook(links, pr)
+	pipeline = pipelineFromPullRequestHook(links, pr)
 	return repo, pipeline, err
 }
 
 // parseTagPushHook parses a tag push hook and returns the Repo and Pipeline details.
-func parseTagPushHook(payload io.Reader) (*model.Repo, *model.Pipeline, error) {
+func parseTagPushHook(links linkBuilder, payload io.Reader) (*model.Repo, *model.Pipeline, error) {
 	push, err := parsePush(payload)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// 确保这是标签推送
-	if !strings.HasPrefix(push.Ref, "refs/tags/") {
+	if !isTagPush(push) {
 		log.Debug().Msgf("Tag Push Hook received but ref is not a tag: %s", push.Ref)
 		return nil, nil, nil
 	}
 
-	// 从 push hook 构建 Repository 对象
-	repo := &model.Repo{
-		ForgeRemoteID: model.ForgeRemoteID(fmt.Sprintf("%d", push.ProjectID)),
-		Owner:         push.Project.Namespace,
-		Name:          push.Project.Name,
-		FullName:      push.Project.PathWithNamespace,
-		Avatar:        push.Project.AvatarURL,
-		ForgeURL:      push.Project.WebURL,
-		Clone:         push.Project.GitHTTPURL,
-		CloneSSH:      push.Project.GitSSHURL,
-		Branch:        push.Project.DefaultBranch,
-		IsSCMPrivate:  push.Project.VisibilityLevel == 0,
-		Perm: &model.Perm{
-			Pull:  true,
-			Push:  true,
-			Admin: false,
-		},
-	}
-
-	pipeline := pipelineFromTag(push)
-	return repo, pipeline, nil
+	return tagPushResult(links, push)
+}
+
+func isTagPush(push *pushHook) bool {
+	return push.ObjectKind == objectKindTagPush || strings.HasPrefix(push.Ref, "refs/tags/")
+}
+
+// tagPushResult 为标签推送创建流水线，删除标签时不触发
+func tagPushResult(links linkBuilder, push *pushHook) (*model.Repo, *model.Pipeline, error) {
+	if push.After == emptyCommit {
+		log.Debug().Msgf("ignoring deletion of tag %s", push.Ref)
+		return nil, nil, nil
+	}
+	return repoFromPushHook(links, push), pipelineFromTag(links, push), nil
 }
 
 // parseMergeRequestHook parses a merge request hook and returns the Repo and Pipeline details.
-func parseMergeRequestHook(payload io.Reader) (*model.Repo, *model.Pipeline, error) {
+func parseMergeRequestHook(links linkBuilder, payload io.Reader) (*model.Repo, *model.Pipeline, error) {
 	// GitCode 的 Merge Request Hook 可能与 Pull Request Hook 使用相似的数据结构
 	// 先尝试作为 Pull Request 解析
-	return parsePullRequestHook(payload)
+	return parsePullRequestHook(links, payload)
+}
+
+// parseDeploymentHook parses a deployment hook and returns the Repo and Pipeline details.
+// Only newly created deployments start a pipeline, later status updates are ignored
+// so reporting the deployment status back can't trigger another deployment.
+func parseDeploymentHook(links linkBuilder, payload io.Reader) (*model.Repo, *model.Pipeline, error) {
+	deployment, err := parseDeployment(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if deployment.Status != "" && deployment.Status != deploymentCreated {
+		log.Debug().Msgf("ignoring deployment %d with status %s", deployment.DeploymentID, deployment.Status)
+		return nil, nil, nil
+	}
+	if deployment.SHA == "" {
+		return nil, nil, fmt.Errorf("deployment %d does not contain a commit", deployment.DeploymentID)
+	}
+
+	return repoFromProject(links, deployment.Project.ID, &deployment.Project), pipelineFromDeployment(links, deployment), nil
 }
 
 // parseReleaseHook parses a release hook and returns the Repo and Pipeline details.
-func parseReleaseHook(payload io.Reader) (*model.Repo, *model.Pipeline, error) {
+func parseReleaseHook(links linkBuilder, payload io.Reader) (*model.Repo, *model.Pipeline, error) {
 	var (
 		repo     *model.Repo
 		pipeline *model.Pipeline
@@ -222,7 +231,7 @@ func parseReleaseHook(payload io.Reader) (*model.Repo, *model.Pipeline, error) {
 		return nil, nil, err
 	}
 
-	repo = toRepo(release.Repo)
-	pipeline = pipelineFromRelease(release)
+	repo = toRepo(links, release.Repo)
+	pipeline = pipelineFromRelease(links, release)
 	return repo, pipeline, err
 }
@@ -1,10 +1,16 @@
 package gitcode
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
 )
 
 func TestParsePullRequestHook(t *testing.T) {
@@ -274,7 +280,7 @@ func TestParsePullRequestHook(t *testing.T) {
 	}`
 
 	reader := strings.NewReader(webhookData)
-	repo, pipeline, err := parsePullRequestHook(reader)
+	repo, pipeline, err := parsePullRequestHook(newLinkBuilder(defaultURL), reader)
 
 	// 测试解析是否成功
 	assert.NoError(t, err)
@@ -303,3 +309,222 @@ func TestParsePullRequestHook(t *testing.T) {
 	assert.Equal(t, "dev:main", pipeline.Refspec)
 	assert.Equal(t, "https://gitcode.com/jetsung/testci/merge_requests/4", pipeline.ForgeURL)
 }
+
+func TestParseTagPushHook(t *testing.T) {
+	payload := func(objectKind, after string) string {
+		return `{
+			"object_kind": "` + objectKind + `",
+			"ref": "refs/tags/v1.0.0",
+			"before": "0000000000000000000000000000000000000000",
+			"after": "` + after + `",
+			"checkout_sha": "e0f538eaf7ded5a29cac7068497f455300b3a5ae",
+			"user_username": "jetsung",
+			"project": {"id": 7720285, "name": "testci", "path_with_namespace": "jetsung/testci", "web_url": "https://gitcode.com/jetsung/testci"}
+		}`
+	}
+
+	for _, header := range []string{hookTagPush, hookPush} {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(payload("tag_push", "4b2f8a7c1d3e5f60718293a4b5c6d7e8f9012345")))
+		req.Header.Set(hookEvent, header)
+		repo, pipeline, err := parseHook(req, newLinkBuilder(defaultURL))
+		assert.NoError(t, err, header)
+		assert.Equal(t, "jetsung/testci", repo.FullName, header)
+		assert.Equal(t, model.EventTag, pipeline.Event, header)
+		assert.Equal(t, "refs/tags/v1.0.0", pipeline.Ref, header)
+		assert.Equal(t, "e0f538eaf7ded5a29cac7068497f455300b3a5ae", pipeline.Commit, header)
+	}
+
+	// 删除标签不触发流水线
+	req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(payload("tag_push", emptyCommit)))
+	req.Header.Set(hookEvent, hookTagPush)
+	repo, pipeline, err := parseHook(req, newLinkBuilder(defaultURL))
+	assert.NoError(t, err)
+	assert.Nil(t, repo)
+	assert.Nil(t, pipeline)
+}
+
+func TestParsePullRequestClosedReason(t *testing.T) {
+	payload := func(action, state string) string {
+		return `{
+			"object_kind": "merge_request",
+			"user": {"username": "jetsung"},
+			"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci"},
+			"merge_request": {"id": 1, "iid": 3, "action": "` + action + `", "state": "` + state + `", "source_branch": "dev", "target_branch": "main", "last_commit": {"id": "abc"}}
+		}`
+	}
+
+	tests := []struct {
+		action, state string
+		event         model.WebhookEvent
+		reason        []string
+	}{
+		{action: "open", state: "opened", event: model.EventPull},
+		{action: "merge", state: "merged", event: model.EventPullClosed, reason: []string{"merged"}},
+		{action: "close", state: "closed", event: model.EventPullClosed, reason: []string{"closed"}},
+	}
+	for _, tt := range tests {
+		_, pipeline, err := parsePullRequestHook(newLinkBuilder(defaultURL), strings.NewReader(payload(tt.action, tt.state)))
+		assert.NoError(t, err, tt.action)
+		assert.Equal(t, tt.event, pipeline.Event, tt.action)
+		assert.Equal(t, tt.reason, pipeline.EventReason, tt.action)
+	}
+}
+
+func TestParseMergedPullRequestCommit(t *testing.T) {
+	payload := func(action, state, extra string) string {
+		return `{
+			"object_kind": "merge_request",
+			"user": {"username": "jetsung"},
+			"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci"},
+			"merge_request": {"id": 1, "iid": 3, "action": "` + action + `", "state": "` + state + `", "source_branch": "dev", "target_branch": "main",
+				"last_commit": {"id": "source-head"}` + extra + `}
+		}`
+	}
+
+	tests := []struct {
+		name, action, state, extra, commit string
+	}{
+		{name: "squash merge", action: "merge", state: "merged", extra: `, "squash": true, "merge_commit_sha": "squashed", "target_branch_commit": {"id": "target-head"}`, commit: "squashed"},
+		{name: "target head", action: "merge", state: "merged", extra: `, "target_branch_commit": {"id": "target-head"}`, commit: "target-head"},
+		{name: "no merge info", action: "merge", state: "merged", commit: "source-head"},
+		{name: "closed", action: "close", state: "closed", extra: `, "target_branch_commit": {"id": "target-head"}`, commit: "source-head"},
+		{name: "open", action: "open", state: "opened", extra: `, "target_branch_commit": {"id": "target-head"}`, commit: "source-head"},
+	}
+	for _, tt := range tests {
+		_, pipeline, err := parsePullRequestHook(newLinkBuilder(defaultURL), strings.NewReader(payload(tt.action, tt.state, tt.extra)))
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.commit, pipeline.Commit, tt.name)
+	}
+}
+
+func TestParseDeploymentHook(t *testing.T) {
+	payload := func(status, ref string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(`{
+			"object_kind": "deployment",
+			"status": "`+status+`",
+			"deployment_id": 12,
+			"environment": "production",
+			"task": "migrate",
+			"ref": "`+ref+`",
+			"sha": "e0f538eaf7ded5a29cac7068497f455300b3a5ae",
+			"commit_title": "release v1",
+			"user": {"username": "jetsung", "email": "i@jetsung.com"},
+			"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci", "default_branch": "main"}
+		}`))
+		req.Header.Set(hookEvent, hookDeployment)
+		return req
+	}
+
+	repo, pipeline, err := parseHook(payload("created", "develop"), newLinkBuilder(defaultURL))
+	assert.NoError(t, err)
+	assert.Equal(t, "7720285", string(repo.ForgeRemoteID))
+	assert.Equal(t, model.EventDeploy, pipeline.Event)
+	assert.Equal(t, "production", pipeline.DeployTo)
+	assert.Equal(t, "migrate", pipeline.DeployTask)
+	assert.Equal(t, "refs/heads/develop", pipeline.Ref)
+	assert.Equal(t, "develop", pipeline.Branch)
+	assert.Equal(t, "e0f538eaf7ded5a29cac7068497f455300b3a5ae", pipeline.Commit)
+	assert.Equal(t, "release v1", pipeline.Message)
+
+	// 部署提交时使用默认分支
+	_, pipeline, err = parseHook(payload("", "e0f538ea"), newLinkBuilder(defaultURL))
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/heads/main", pipeline.Ref)
+	assert.Equal(t, "main", pipeline.Branch)
+
+	repo, pipeline, err = parseHook(payload("success", "main"), newLinkBuilder(defaultURL))
+	assert.NoError(t, err)
+	assert.Nil(t, repo)
+	assert.Nil(t, pipeline)
+}
+
+func TestParsePullRequestFromFork(t *testing.T) {
+	payload := func(mergeRequest string) string {
+		return `{
+			"object_kind": "merge_request",
+			"user": {"username": "reviewer"},
+			"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci"},
+			"merge_request": {"id": 1, "iid": 3, "action": "open", "state": "opened", "source_branch": "dev", "target_branch": "main",
+				"last_commit": {"id": "abc"}, "author": {"username": "contributor"}` + mergeRequest + `}
+		}`
+	}
+
+	tests := []struct {
+		name, mergeRequest string
+		fork               bool
+	}{
+		{name: "same project", mergeRequest: `, "source": {"id": 7720285}, "target": {"id": 7720285}`, fork: false},
+		{name: "fork", mergeRequest: `, "source": {"id": 1}, "target": {"id": 7720285}`, fork: true},
+		{name: "project ids", mergeRequest: `, "source_project_id": 1, "target_project_id": 7720285`, fork: true},
+		{name: "same path", mergeRequest: `, "source": {"path_with_namespace": "jetsung/testci"}`, fork: false},
+		{name: "fork path", mergeRequest: `, "source": {"path_with_namespace": "contributor/testci"}`, fork: true},
+		{name: "unknown source", mergeRequest: ``, fork: true},
+	}
+	for _, tt := range tests {
+		_, pipeline, err := parsePullRequestHook(newLinkBuilder(defaultURL), strings.NewReader(payload(tt.mergeRequest)))
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.fork, pipeline.FromFork, tt.name)
+		// 审批白名单按合并请求作者判断，而不是触发事件的用户
+		assert.Equal(t, "contributor", pipeline.Author, tt.name)
+		assert.Equal(t, "reviewer", pipeline.Sender, tt.name)
+	}
+}
+
+func TestParsePullRequestApproval(t *testing.T) {
+	payload := func(action string) string {
+		return `{
+			"object_kind": "merge_request",
+			"user": {"username": "maintainer"},
+			"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci"},
+			"merge_request": {"id": 1, "iid": 3, "action": "` + action + `", "state": "opened", "source_branch": "dev", "target_branch": "main",
+				"last_commit": {"id": "abc"}, "author": {"username": "jetsung"}, "source": {"id": 7720285}, "target": {"id": 7720285}}
+		}`
+	}
+
+	for _, action := range []string{"approved", "approval"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(payload(action)))
+		req.Header.Set(hookEvent, hookMergeRequest)
+		repo, pipeline, err := parseHook(req, newLinkBuilder(defaultURL))
+		assert.NoError(t, err, action)
+		assert.Equal(t, "jetsung/testci", repo.FullName, action)
+		assert.Equal(t, model.EventPullMetadata, pipeline.Event, action)
+		assert.Equal(t, []string{"approved"}, pipeline.EventReason, action)
+		assert.Equal(t, "abc", pipeline.Commit, action)
+		assert.Equal(t, "jetsung", pipeline.Author, action)
+		assert.Equal(t, "maintainer", pipeline.Sender, action)
+	}
+
+	_, pipeline, err := parsePullRequestHook(newLinkBuilder(defaultURL), strings.NewReader(payload("unapproved")))
+	assert.NoError(t, err)
+	assert.Nil(t, pipeline)
+}
+
+func TestParseUnsupportedHook(t *testing.T) {
+	parse := func(header, payload string) error {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(payload))
+		if header != "" {
+			req.Header.Set(hookEvent, header)
+		}
+		repo, pipeline, err := parseHook(req, newLinkBuilder(defaultURL))
+		assert.Nil(t, repo)
+		assert.Nil(t, pipeline)
+		return err
+	}
+
+	ignored := testutil.ToFloat64(unsupportedHooks.WithLabelValues("Issue Hook"))
+	err := parse("Issue Hook", `{"object_kind": "issue"}`)
+	var ignore *forge_types.ErrIgnoreEvent
+	assert.ErrorAs(t, err, &ignore)
+	assert.Equal(t, "Issue Hook", ignore.Event)
+	assert.NotEmpty(t, ignore.Reason)
+	assert.Equal(t, ignored+1, testutil.ToFloat64(unsupportedHooks.WithLabelValues("Issue Hook")))
+
+	// 没有事件头时使用 object_kind
+	err = parse("", `{"object_kind": "wiki_page"}`)
+	assert.ErrorAs(t, err, &ignore)
+	assert.Equal(t, "wiki_page", ignore.Event)
+
+	err = parse("", `not json`)
+	assert.ErrorAs(t, err, &ignore)
+	assert.Empty(t, ignore.Event)
+}
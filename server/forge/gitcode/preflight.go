@@ -0,0 +1,69 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hookPath 是 Woodpecker 接收 webhook 的路径，见 server/router
+const hookPath = "/api/hook"
+
+// healthURL 根据 webhook 地址推导出同一 Woodpecker 实例的健康检查地址，保留 root path 前缀
+func healthURL(link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("webhook url %q is not absolute", link)
+	}
+
+	prefix := strings.TrimSuffix(u.Path, "/")
+	if i := strings.LastIndex(prefix, hookPath); i >= 0 {
+		prefix = prefix[:i]
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: prefix + "/healthz"}).String(), nil
+}
+
+// checkReachable 在创建 webhook 前请求 Woodpecker 自身的健康检查地址，
+// 避免注册一个永远无法投递的 webhook；ctx 的超时由调用方按 opHook 设置
+func checkReachable(ctx context.Context, transport http.RoundTripper, link string) error {
+	target, err := healthURL(link)
+	if err != nil {
+		return fmt.Errorf("pre-flight check: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("pre-flight check: %w", err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return fmt.Errorf("pre-flight check: woodpecker is not reachable at %s, GitCode could never deliver webhooks "+
+			"(check WOODPECKER_HOST, DNS, TLS certificates and firewall rules): %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("pre-flight check: %s answered with status %d instead of woodpecker's health endpoint, "+
+			"check WOODPECKER_HOST and the reverse proxy configuration", target, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthURL(t *testing.T) {
+	for link, expected := range map[string]string{
+		"https://ci.example.com/api/hook?access_token=abc":      "https://ci.example.com/healthz",
+		"https://example.com/woodpecker/api/hook?access_token=": "https://example.com/woodpecker/healthz",
+		"http://ci.example.com:8000/":                           "http://ci.example.com:8000/healthz",
+	} {
+		actual, err := healthURL(link)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := healthURL("/api/hook")
+	assert.Error(t, err)
+}
+
+func TestCheckReachable(t *testing.T) {
+	respond := func(status int, err error) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "https://ci.example.com/healthz", req.URL.String())
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+		})
+	}
+	link := "https://ci.example.com/api/hook?access_token=abc"
+
+	assert.NoError(t, checkReachable(t.Context(), respond(http.StatusNoContent, nil), link))
+
+	err := checkReachable(t.Context(), respond(http.StatusBadGateway, nil), link)
+	assert.ErrorContains(t, err, "answered with status 502")
+
+	err = checkReachable(t.Context(), respond(0, errors.New("no such host")), link)
+	assert.ErrorContains(t, err, "woodpecker is not reachable at https://ci.example.com/healthz")
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/common"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	shared_utils "go.woodpecker-ci.org/woodpecker/v3/shared/utils"
+)
+
+// IsProtectedRef 判断流水线对应的分支或标签是否受保护，与 GitLab 受保护变量的语义一致
+func (c *GitCode) IsProtectedRef(ctx context.Context, u *model.User, r *model.Repo, p *model.Pipeline) (bool, error) {
+	// 合并请求的代码尚未合入目标分支，复刻仓库的更是外部代码，与目标分支是否受保护无关。
+	// 由合并请求流水线部署的流水线同样如此，因此按引用而不是事件判断
+	if p.FromFork || strings.HasPrefix(p.Ref, "refs/pull/") {
+		return false, nil
+	}
+
+	ctx = withPipeline(ctx, p)
+	token := common.UserToken(ctx, r, u)
+	client := c.newGitCodeClient(token)
+
+	if tag, ok := strings.CutPrefix(p.Ref, "refs/tags/"); ok {
+		rules, err := shared_utils.Paginate(func(page int) ([]*ProtectedTag, error) {
+			return client.GetProtectedTags(ctx, r.Owner, r.Name, page, c.perPage(ctx))
+		}, -1)
+		if err != nil {
+			return false, err
+		}
+		return matchesProtectedTag(rules, tag), nil
+	}
+
+	if p.Branch == "" {
+		return false, nil
+	}
+	branch, err := client.GetBranch(ctx, r.Owner, r.Name, p.Branch)
+	if err != nil {
+		return false, err
+	}
+	return branch.Protected, nil
+}
+
+// matchesProtectedTag 判断标签是否匹配任一受保护规则，规则支持 * 通配符
+func matchesProtectedTag(rules []*ProtectedTag, tag string) bool {
+	for _, rule := range rules {
+		if rule.Name == tag {
+			return true
+		}
+		if ok, err := path.Match(rule.Name, tag); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+func TestIsProtectedRef(t *testing.T) {
+	user := &model.User{AccessToken: "token"}
+	repo := &model.Repo{Owner: "owner", Name: "repo", FullName: "owner/repo"}
+
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/protected_tags") && req.URL.Query().Get("page") == "1":
+			return http.StatusOK, `[{"name":"v*"},{"name":"stable"}]`
+		case strings.HasSuffix(req.URL.Path, "/protected_tags"):
+			return http.StatusOK, `[]`
+		case strings.HasSuffix(req.URL.Path, "/branches/main"):
+			return http.StatusOK, `{"name":"main","commit":{"id":"abc"},"protected":true}`
+		default:
+			return http.StatusOK, `{"name":"feature","commit":{"id":"def"},"protected":false}`
+		}
+	})
+
+	tests := []struct {
+		name     string
+		pipeline *model.Pipeline
+		expected bool
+	}{
+		{name: "protected branch", pipeline: &model.Pipeline{Event: model.EventPush, Ref: "refs/heads/main", Branch: "main"}, expected: true},
+		{name: "unprotected branch", pipeline: &model.Pipeline{Event: model.EventPush, Ref: "refs/heads/feature", Branch: "feature"}, expected: false},
+		{name: "wildcard tag", pipeline: &model.Pipeline{Event: model.EventTag, Ref: "refs/tags/v1.2.0"}, expected: true},
+		{name: "exact tag", pipeline: &model.Pipeline{Event: model.EventTag, Ref: "refs/tags/stable"}, expected: true},
+		{name: "unprotected tag", pipeline: &model.Pipeline{Event: model.EventTag, Ref: "refs/tags/nightly"}, expected: false},
+		{name: "pull request", pipeline: &model.Pipeline{Event: model.EventPull, Ref: "refs/pull/1/head", Branch: "main"}, expected: false},
+		{name: "fork pull request", pipeline: &model.Pipeline{Event: model.EventPull, Ref: "refs/pull/1/head", Branch: "main", FromFork: true}, expected: false},
+		{name: "deployment of a pull request", pipeline: &model.Pipeline{Event: model.EventDeploy, Ref: "refs/pull/1/head", Branch: "main"}, expected: false},
+		{name: "deployment from a fork", pipeline: &model.Pipeline{Event: model.EventDeploy, Ref: "refs/heads/main", Branch: "main", FromFork: true}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protected, err := c.IsProtectedRef(t.Context(), user, repo, tt.pipeline)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, protected)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxyDirect 表示该类操作不经过任何代理
+const proxyDirect = "direct"
+
+var operationNames = map[string]operation{
+	"default": opDefault,
+	"hook":    opHook,
+	"sync":    opSync,
+	"archive": opArchive,
+}
+
+// proxyProfiles 为每类操作指定出口代理，nil 值表示直连；
+// 未配置的操作沿用环境变量中的代理设置
+type proxyProfiles map[operation]*url.URL
+
+// parseProxyProfiles 解析形如 "sync=http://proxy:3128" 或 "hook=direct" 的配置
+func parseProxyProfiles(entries []string) (proxyProfiles, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	profiles := make(proxyProfiles, len(entries))
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid proxy profile %q, expected <operation>=<proxy url>", entry)
+		}
+		op, ok := operationNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("invalid proxy profile %q, unknown operation %q", entry, name)
+		}
+
+		value = strings.TrimSpace(value)
+		if value == proxyDirect {
+			profiles[op] = nil
+			continue
+		}
+		proxyURL, err := url.Parse(value)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy profile %q, proxy must be an absolute url or %q", entry, proxyDirect)
+		}
+		profiles[op] = proxyURL
+	}
+	return profiles, nil
+}
+
+// proxy 可用作 http.Transport.Proxy，按请求 context 中记录的操作类别选择代理
+func (p proxyProfiles) proxy(req *http.Request) (*url.URL, error) {
+	if proxyURL, ok := p[operationFromContext(req.Context())]; ok {
+		return proxyURL, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProxyProfiles(t *testing.T) {
+	profiles, err := parseProxyProfiles(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, profiles)
+
+	profiles, err = parseProxyProfiles([]string{"sync=http://bulk-proxy:3128", " Hook = direct "})
+	assert.NoError(t, err)
+	if assert.Len(t, profiles, 2) {
+		assert.Equal(t, "http://bulk-proxy:3128", profiles[opSync].String())
+		assert.Nil(t, profiles[opHook])
+	}
+
+	for _, entry := range []string{"sync", "unknown=http://proxy", "sync=proxy:3128", "sync="} {
+		_, err = parseProxyProfiles([]string{entry})
+		assert.Error(t, err, entry)
+	}
+}
+
+func TestProxyProfilesSelect(t *testing.T) {
+	profiles, err := parseProxyProfiles([]string{"sync=http://bulk-proxy:3128", "hook=direct"})
+	assert.NoError(t, err)
+
+	proxyFor := func(ctx context.Context) string {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.gitcode.com/api/v5/user", nil)
+		assert.NoError(t, err)
+		proxyURL, err := profiles.proxy(req)
+		assert.NoError(t, err)
+		if proxyURL == nil {
+			return ""
+		}
+		return proxyURL.String()
+	}
+
+	syncCtx, cancel := defaultOperationTimeouts.withOperation(t.Context(), opSync)
+	defer cancel()
+	assert.Equal(t, "http://bulk-proxy:3128", proxyFor(syncCtx))
+
+	hookCtx, cancel := defaultOperationTimeouts.withOperation(t.Context(), opHook)
+	defer cancel()
+	assert.Equal(t, "", proxyFor(hookCtx))
+}
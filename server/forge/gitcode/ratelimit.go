@@ -0,0 +1,186 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxRateLimitWait 是请求等待其 token 限流重置的最长时间
+	maxRateLimitWait = time.Minute
+	// defaultRateLimitWait 是 429 响应未给出重置时间时的等待时间
+	defaultRateLimitWait = time.Second
+	// maxRateLimitRetries 是收到 429 响应后重新发送请求的次数
+	maxRateLimitRetries = 3
+	// unixResetThreshold 用于区分重置时间响应头中的 unix 时间戳和秒数
+	unixResetThreshold = 1_000_000_000
+)
+
+// rateLimits 记录每个 token 的限流在何时之前已用尽。所有客户端共享该记录，
+// GitCode 报告限流用尽后，同一 token 的请求会一起暂缓
+type rateLimits struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newRateLimits() *rateLimits {
+	return &rateLimits{until: make(map[string]time.Time)}
+}
+
+// delay 返回 key 的请求需要等待的时间
+func (l *rateLimits) delay(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := time.Until(l.until[key])
+	if d <= 0 {
+		delete(l.until, key)
+		return 0
+	}
+	return min(d, maxRateLimitWait)
+}
+
+// block 将 key 的请求暂缓到指定时间
+func (l *rateLimits) block(key string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.until[key]) {
+		l.until[key] = until
+	}
+}
+
+// tokenKey 标识 token，而不在内存中保存 token 本身
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// rateLimitMiddleware 在 token 的限流用尽时让请求排队等待，
+// 并在限流重置后重新发送被 429 拒绝的请求
+func rateLimitMiddleware(limits *rateLimits, token string) Middleware {
+	key := tokenKey(token)
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for attempt := 0; ; attempt++ {
+				if d := limits.delay(key); d > 0 {
+					log.Debug().Msgf("GitCode: rate limit exhausted, waiting %s before %s %s", d.Round(time.Second), req.Method, req.URL.Path)
+					select {
+					case <-req.Context().Done():
+						return nil, req.Context().Err()
+					case <-time.After(d):
+					}
+				}
+
+				resp, err := next.RoundTrip(req)
+				if err != nil {
+					return nil, err
+				}
+				if until, limited := rateLimitReset(resp, time.Now()); limited {
+					limits.block(key, until)
+				}
+				if resp.StatusCode != http.StatusTooManyRequests || attempt >= maxRateLimitRetries {
+					return resp, nil
+				}
+
+				retry, ok := resend(req)
+				if !ok {
+					return resp, nil
+				}
+				_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+				resp.Body.Close()
+				req = retry
+			}
+		})
+	}
+}
+
+// resend 返回可以再次发送的 req 副本，再次发送需要新的请求体
+func resend(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, true
+}
+
+// rateLimitReset 返回限流在何时之前已用尽，原因可能是请求被 429 拒绝，也可能是剩余请求数为 0
+func rateLimitReset(resp *http.Response, now time.Time) (time.Time, bool) {
+	reset, hasReset := parseReset(resp.Header, now)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return retryAfter, true
+		}
+		if hasReset {
+			return reset, true
+		}
+		return now.Add(defaultRateLimitWait), true
+	}
+
+	remaining := firstHeader(resp.Header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	return reset, hasReset && remaining == "0"
+}
+
+// parseReset 读取重置时间响应头，其值为 unix 时间戳或距离限流重置的秒数
+func parseReset(header http.Header, now time.Time) (time.Time, bool) {
+	value, err := strconv.ParseInt(firstHeader(header, "X-RateLimit-Reset", "RateLimit-Reset"), 10, 64)
+	if err != nil || value < 0 {
+		return time.Time{}, false
+	}
+	if value >= unixResetThreshold {
+		return time.Unix(value, 0), true
+	}
+	return now.Add(time.Duration(value) * time.Second), true
+}
+
+// parseRetryAfter 读取以秒数或 HTTP 日期表示的 Retry-After 响应头
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+func firstHeader(header http.Header, keys ...string) string {
+	for _, key := range keys {
+		if value := header.Get(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitReset(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	response := func(status int, header map[string]string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		for k, v := range header {
+			resp.Header.Set(k, v)
+		}
+		return resp
+	}
+
+	until, limited := rateLimitReset(response(http.StatusTooManyRequests, map[string]string{"Retry-After": "30"}), now)
+	assert.True(t, limited)
+	assert.Equal(t, now.Add(30*time.Second), until)
+
+	until, limited = rateLimitReset(response(http.StatusTooManyRequests, map[string]string{"Retry-After": now.Add(time.Minute).UTC().Format(http.TimeFormat)}), now)
+	assert.True(t, limited)
+	assert.WithinDuration(t, now.Add(time.Minute), until, 0)
+
+	until, limited = rateLimitReset(response(http.StatusTooManyRequests, map[string]string{"X-RateLimit-Reset": "1700000120"}), now)
+	assert.True(t, limited)
+	assert.WithinDuration(t, now.Add(2*time.Minute), until, 0)
+
+	until, limited = rateLimitReset(response(http.StatusTooManyRequests, nil), now)
+	assert.True(t, limited)
+	assert.Equal(t, now.Add(defaultRateLimitWait), until)
+
+	until, limited = rateLimitReset(response(http.StatusOK, map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "10"}), now)
+	assert.True(t, limited)
+	assert.Equal(t, now.Add(10*time.Second), until)
+
+	_, limited = rateLimitReset(response(http.StatusOK, map[string]string{"X-RateLimit-Remaining": "42", "X-RateLimit-Reset": "10"}), now)
+	assert.False(t, limited)
+
+	_, limited = rateLimitReset(response(http.StatusOK, nil), now)
+	assert.False(t, limited)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	t.Run("resends after too many requests", func(t *testing.T) {
+		calls := 0
+		transport := rateLimitMiddleware(newRateLimits(), "token")(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls == 1 {
+				return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0"}}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			}
+			body, _ := io.ReadAll(req.Body)
+			assert.Equal(t, `{"state":"success"}`, string(body))
+			return okResponse(), nil
+		}))
+
+		req, _ := http.NewRequest(http.MethodPost, "https://api.gitcode.com/api/v5/repos/a/b/statuses/abc", strings.NewReader(`{"state":"success"}`))
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		calls := 0
+		transport := rateLimitMiddleware(newRateLimits(), "token")(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0"}}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+		}))
+
+		req, _ := http.NewRequest(http.MethodGet, "https://api.gitcode.com/api/v5/user", nil)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, maxRateLimitRetries+1, calls)
+	})
+
+	t.Run("queues requests of the same token", func(t *testing.T) {
+		limits := newRateLimits()
+		limits.block(tokenKey("token"), time.Now().Add(100*time.Millisecond))
+		next := roundTripperFunc(func(*http.Request) (*http.Response, error) { return okResponse(), nil })
+
+		req, _ := http.NewRequest(http.MethodGet, "https://api.gitcode.com/api/v5/user", nil)
+		start := time.Now()
+		_, err := rateLimitMiddleware(limits, "token")(next).RoundTrip(req)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+		start = time.Now()
+		_, err = rateLimitMiddleware(limits, "other")(next).RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+		limits.block(tokenKey("token"), time.Now().Add(time.Minute))
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, err = rateLimitMiddleware(limits, "token")(next).RoundTrip(req.WithContext(ctx))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
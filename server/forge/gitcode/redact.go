@@ -0,0 +1,79 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redacted 替换日志和错误信息中的敏感内容
+const redacted = "REDACTED"
+
+// secretPattern 匹配文本中形如 access_token=xxx 或 "client_secret":"xxx" 的敏感字段
+var secretPattern = regexp.MustCompile(`(?i)((?:access|refresh|private)[_-]?token|client_secret|password|secret)(["']?\s*[:=]\s*["']?)[^\s"'&,;}]+`)
+
+// isSecretParam 判断查询参数是否携带令牌、密钥或签名
+func isSecretParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, marker := range []string{"token", "secret", "password", "signature", "sig"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL 返回隐藏了敏感查询参数值的 URL
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	query := u.Query()
+	changed := false
+	for name := range query {
+		if isSecretParam(name) {
+			query.Set(name, redacted)
+			changed = true
+		}
+	}
+	if !changed && u.User == nil {
+		return u.String()
+	}
+	clean := *u
+	clean.User = nil
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// redactText 隐藏文本中的敏感字段，用于 API 返回的错误信息
+func redactText(s string) string {
+	return secretPattern.ReplaceAllString(s, "${1}${2}"+redacted)
+}
+
+// redactError 隐藏 HTTP 客户端错误中请求 URL 的敏感查询参数
+func redactError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	parsed, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return &url.Error{Op: urlErr.Op, URL: redacted, Err: urlErr.Err}
+	}
+	return &url.Error{Op: urlErr.Op, URL: redactURL(parsed), Err: urlErr.Err}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	shared_utils "go.woodpecker-ci.org/woodpecker/v3/shared/utils"
+)
+
+// 发行版 webhook 的动作
+const (
+	releasePublished   = "published"
+	releasePrereleased = "prereleased"
+	releaseEdited      = "edited"
+)
+
+// releaseActions 是可以触发流水线的发行版动作
+var releaseActions = []string{releasePublished, releasePrereleased, releaseEdited}
+
+// defaultReleaseActions 默认只有发布发行版时触发流水线
+var defaultReleaseActions = []string{releasePublished}
+
+// parseReleaseActions 校验并去重触发流水线的发行版动作，为空时使用 defaultReleaseActions
+func parseReleaseActions(values []string) ([]string, error) {
+	var actions []string
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if !slices.Contains(releaseActions, value) {
+			return nil, fmt.Errorf("unknown release action %q, expected one of %s", value, strings.Join(releaseActions, ", "))
+		}
+		actions = append(actions, value)
+	}
+	if len(actions) == 0 {
+		return defaultReleaseActions, nil
+	}
+	return shared_utils.Deduplicate(actions), nil
+}
+
+// releaseAction 返回发行版 webhook 的动作，不带动作的旧版本 webhook 视为发布
+func releaseAction(hook *releaseHook) string {
+	return strings.ToLower(orDefault(hook.Action, releasePublished))
+}
+
+// triggersRelease 判断发行版流水线的动作是否会触发流水线，动作保存在流水线的事件原因中
+func (c *GitCode) triggersRelease(pipeline *model.Pipeline) bool {
+	if len(pipeline.EventReason) == 0 {
+		return true
+	}
+	return slices.Contains(c.releaseActions, pipeline.EventReason[0])
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func TestParseReleaseActions(t *testing.T) {
+	actions, err := parseReleaseActions(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{releasePublished}, actions)
+
+	actions, err = parseReleaseActions([]string{" Published", "edited", "published", ""})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{releasePublished, releaseEdited}, actions)
+
+	_, err = parseReleaseActions([]string{"deleted"})
+	assert.ErrorContains(t, err, `unknown release action "deleted"`)
+}
+
+func releasePayload(action string) string {
+	return `{
+		"action": "` + action + `",
+		"repository": {"id": 7720285, "full_name": "jetsung/testci", "name": "testci", "path": "testci", "namespace": {"path": "jetsung"}},
+		"sender": {"login": "jetsung"},
+		"release": {"tag_name": "v1.0.0", "name": "v1.0.0", "prerelease": true}
+	}`
+}
+
+func TestHookFiltersReleaseActions(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "7720285", FullName: "jetsung/testci", Owner: "jetsung", Name: "testci", UserID: 1, Hash: "hash"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("7720285"), "jetsung/testci").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token", Expiry: 1 << 40}, nil).Maybe()
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	c := newStubGitCode(t, func(*http.Request) (int, string) {
+		return http.StatusOK, `[]`
+	})
+	hook := func(payload string) (*model.Pipeline, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", strings.NewReader(payload))
+		req.Header.Set(hookEvent, hookRelease)
+		req.Header.Set(hookToken, webhookSecret(repo))
+		_, pipeline, err := c.Hook(ctx, req)
+		return pipeline, err
+	}
+
+	pipeline, err := hook(releasePayload(releasePublished))
+	assert.NoError(t, err)
+	assert.Equal(t, model.EventRelease, pipeline.Event)
+	assert.Equal(t, []string{releasePublished}, pipeline.EventReason)
+
+	// 不带动作的 webhook 视为发布
+	pipeline, err = hook(releasePayload(""))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{releasePublished}, pipeline.EventReason)
+
+	for _, action := range []string{releasePrereleased, releaseEdited, "deleted"} {
+		_, err = hook(releasePayload(action))
+		assert.ErrorIs(t, err, &forge_types.ErrIgnoreEvent{}, action)
+		assert.ErrorContains(t, err, "release action "+action, action)
+	}
+
+	c.releaseActions = []string{releasePublished, releasePrereleased}
+	pipeline, err = hook(releasePayload(releasePrereleased))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{releasePrereleased}, pipeline.EventReason)
+	assert.True(t, pipeline.IsPrerelease)
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultMaxRetries   = 2
+	defaultRetryBackoff = 500 * time.Millisecond
+	// maxRetryBackoff 是两次尝试之间指数退避时间的上限
+	maxRetryBackoff = 30 * time.Second
+)
+
+// retryTransport 重试网络层失败或返回临时网关错误（502、503、504）的幂等请求，
+// 两次尝试之间按指数增长并带随机抖动的时间退避，其他 HTTP 错误响应原样返回
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	backoff    time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		retryable := isRetryableError(err) || (err == nil && isRetryableStatus(resp.StatusCode))
+		if !retryable || attempt >= t.maxRetries {
+			return withRetries(req, resp, err, attempt)
+		}
+		if resp != nil {
+			// 读完响应体以便复用连接
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			resp.Body.Close()
+		}
+
+		delay := retryDelay(t.backoff, attempt)
+		log.Debug().Err(err).Msgf("GitCode: retrying %s %s in %s (attempt %d/%d)", req.Method, req.URL.Path, delay, attempt+1, t.maxRetries)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isIdempotent 判断请求是否可以安全地再次发送
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+// isRetryableStatus 判断响应状态码是否为临时网关错误
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay 返回第 attempt+1 次重试前的退避时间：每次尝试后 base 翻倍，不超过 maxRetryBackoff，
+// 并加上最多一半的随机抖动，避免客户端同时重试
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	// 限制移位次数，避免尝试次数过大时溢出
+	delay := min(base<<min(attempt, 16), maxRetryBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// RetryError 表示请求重试后仍在网络层失败
+type RetryError struct {
+	Retries int
+	Err     error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d retries)", e.Err, e.Retries)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+type retriesKey struct{}
+
+// withRetries 记录请求的重试次数：错误包装为 RetryError，响应则将次数记录在
+// resp.Request 的 context 中，由 newAPIError 读取
+func withRetries(req *http.Request, resp *http.Response, err error, retries int) (*http.Response, error) {
+	if retries == 0 {
+		return resp, err
+	}
+	if err != nil {
+		return nil, &RetryError{Retries: retries, Err: err}
+	}
+	resp.Request = req.WithContext(context.WithValue(req.Context(), retriesKey{}, retries))
+	return resp, nil
+}
+
+// retriesOf 返回 resp 对应请求的重试次数
+func retriesOf(resp *http.Response) int {
+	if resp.Request == nil {
+		return 0
+	}
+	retries, _ := resp.Request.Context().Value(retriesKey{}).(int)
+	return retries
+}
+
+// isRetryableError 判断错误是否为连接重置、DNS 临时错误等暂时性的网络故障
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
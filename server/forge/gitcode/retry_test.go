@@ -0,0 +1,152 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func okResponse() *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	assert.True(t, isRetryableError(&net.OpError{Op: "read", Err: syscall.ECONNRESET}))
+	assert.True(t, isRetryableError(fmt.Errorf("get: %w", &net.DNSError{Err: "server misbehaving", IsTemporary: true})))
+	assert.False(t, isRetryableError(&net.DNSError{Err: "no such host", IsNotFound: true}))
+	assert.False(t, isRetryableError(context.Canceled))
+	assert.False(t, isRetryableError(errors.New("tls: bad certificate")))
+}
+
+func TestRetryTransport(t *testing.T) {
+	t.Run("retries get on connection reset", func(t *testing.T) {
+		calls := 0
+		transport := &retryTransport{
+			next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				calls++
+				if calls < 3 {
+					return nil, &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+				}
+				return okResponse(), nil
+			}),
+			maxRetries: 2,
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "https://api.gitcode.com/api/v5/user", nil)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("does not retry post", func(t *testing.T) {
+		calls := 0
+		transport := &retryTransport{
+			next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				calls++
+				return nil, &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+			}),
+			maxRetries: 2,
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, "https://api.gitcode.com/api/v5/repos/a/b/hooks", strings.NewReader("{}"))
+		_, err := transport.RoundTrip(req)
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("does not retry http errors", func(t *testing.T) {
+		calls := 0
+		transport := &retryTransport{
+			next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				calls++
+				return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil
+			}),
+			maxRetries: 2,
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "https://api.gitcode.com/api/v5/repos/a/b", nil)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retries delete on gateway errors", func(t *testing.T) {
+		calls := 0
+		transport := &retryTransport{
+			next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				calls++
+				if calls == 1 {
+					return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+				}
+				return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+			}),
+			maxRetries: 2,
+		}
+
+		req, _ := http.NewRequest(http.MethodDelete, "https://api.gitcode.com/api/v5/repos/a/b/hooks/1", nil)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("reports retries", func(t *testing.T) {
+		transport := &retryTransport{
+			next: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(`{"message":"busy"}`))}, nil
+			}),
+			maxRetries: 2,
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "https://api.gitcode.com/api/v5/repos/a/b", nil)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		apiErr := newAPIError(resp)
+		assert.Equal(t, 2, apiErr.Retries)
+		assert.EqualError(t, apiErr, "API error 503: busy (after 2 retries)")
+
+		transport.next = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+		})
+		_, err = transport.RoundTrip(req)
+		var retryErr *RetryError
+		assert.ErrorAs(t, err, &retryErr)
+		assert.Equal(t, 2, retryErr.Retries)
+		assert.ErrorIs(t, err, syscall.ECONNRESET)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Zero(t, retryDelay(0, 3))
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		delay := retryDelay(time.Second, attempt)
+		assert.GreaterOrEqual(t, delay, expected/2)
+		assert.LessOrEqual(t, delay, expected)
+	}
+	assert.LessOrEqual(t, retryDelay(time.Second, 20), maxRetryBackoff)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// squashMergeRef 匹配 GitCode 压缩合并生成的提交信息中对合并请求的引用，
+// 如 "See merge request owner/repo!12" 或以 "!12" 开头的标题行
+var squashMergeRef = regexp.MustCompile(`(?m)(?:^|merge request \S*)!(\d+)\b`)
+
+// squashMergeNumber 从提交信息中解析压缩合并对应的合并请求编号
+func squashMergeNumber(message string) (int64, bool) {
+	match := squashMergeRef.FindStringSubmatch(message)
+	if match == nil {
+		return 0, false
+	}
+	number, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+	return number, true
+}
+
+// pullRequestMessage 由合并请求的标题和描述组成流水线信息
+func pullRequestMessage(pr *PullRequest) string {
+	title := strings.TrimSpace(pr.Title)
+	body := strings.TrimSpace(pr.Body)
+	if body == "" {
+		return title
+	}
+	return title + "\n\n" + body
+}
+
+// useSquashMergeMessage 对压缩合并产生的推送，使用合并请求的标题和描述代替自动生成的提交信息；
+// 查询失败或合并请求与本次推送不对应时保留原提交信息
+func (c *GitCode) useSquashMergeMessage(ctx context.Context, repo *model.Repo, p *model.Pipeline) {
+	number, ok := squashMergeNumber(p.Message)
+	if !ok {
+		return
+	}
+
+	pr, err := c.getPullRequest(ctx, repo, number)
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not get merge request %s!%d for squash merge", repo.FullName, number)
+		return
+	}
+	if pr.MergeCommitSHA != "" && pr.MergeCommitSHA != p.Commit {
+		return
+	}
+	if message := pullRequestMessage(pr); message != "" {
+		p.Message = message
+	}
+}
+
+// getPullRequest 使用仓库所有者的令牌查询合并请求
+func (c *GitCode) getPullRequest(ctx context.Context, repo *model.Repo, number int64) (*PullRequest, error) {
+	client, repo, err := c.repoOwnerClient(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPullRequest(ctx, repo.Owner, repo.Name, number)
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+func TestSquashMergeNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected int64
+		ok       bool
+	}{
+		{name: "merge request reference", message: "* fix a\n\n* fix b\n\nSee merge request owner/repo!12", expected: 12, ok: true},
+		{name: "title prefix", message: "!7 add login page\n* wip", expected: 7, ok: true},
+		{name: "plain commit", message: "fix typo in readme", ok: false},
+		{name: "exclamation in text", message: "wow!3 times faster", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, ok := squashMergeNumber(tt.message)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, number)
+		})
+	}
+}
+
+func TestUseSquashMergeMessage(t *testing.T) {
+	repo := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Owner: "owner", Name: "repo", UserID: 1}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("1"), "owner/repo").Return(repo, nil)
+	mockStore.On("GetUser", int64(1)).Return(&model.User{AccessToken: "token"}, nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, "/api/v5/repos/owner/repo/pulls/12", req.URL.Path)
+		return http.StatusOK, `{"number":12,"title":"Add login page","body":"Closes #3","merge_commit_sha":"abc"}`
+	})
+
+	pipeline := &model.Pipeline{Event: model.EventPush, Commit: "abc", Message: "* wip\n\n* fix\n\nSee merge request owner/repo!12"}
+	c.useSquashMergeMessage(ctx, repo, pipeline)
+	assert.Equal(t, "Add login page\n\nCloses #3", pipeline.Message)
+
+	// 合并请求对应其他提交时保留原信息
+	pipeline = &model.Pipeline{Event: model.EventPush, Commit: "def", Message: "See merge request owner/repo!12"}
+	c.useSquashMergeMessage(ctx, repo, pipeline)
+	assert.Equal(t, "See merge request owner/repo!12", pipeline.Message)
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server"
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge/common"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// Status 将工作流状态设置为提交状态，提交所属的合并请求会同步显示
+func (c *GitCode) Status(ctx context.Context, user *model.User, repo *model.Repo, pipeline *model.Pipeline, workflow *model.Workflow) error {
+	if pipeline.Commit == "" {
+		return nil
+	}
+
+	ctx, cancel := c.timeouts.withOperation(withPipeline(ctx, pipeline), opDefault)
+	defer cancel()
+
+	_, err := c.newGitCodeClient(common.UserToken(ctx, repo, user)).CreateCommitStatus(ctx, repo.Owner, repo.Name, pipeline.Commit, &CreateStatusRequest{
+		State:       convertStatus(workflow.State),
+		TargetURL:   common.GetPipelineStatusURL(repo, pipeline, workflow),
+		Description: statusDescription(pipeline, workflow),
+		Context:     c.statusContextOf(repo, pipeline, workflow),
+	})
+	return err
+}
+
+// statusContextPlaceholders 是状态上下文模板中可用的占位符
+var statusContextPlaceholders = []string{"%context", "%event", "%workflow", "%owner", "%repo", "%axis"}
+
+var statusContextPlaceholder = regexp.MustCompile(`%[a-z_]+`)
+
+// parseStatusContext 校验状态上下文模板，模板中只能使用 statusContextPlaceholders
+func parseStatusContext(template string) (string, error) {
+	template = strings.TrimSpace(template)
+	for _, placeholder := range statusContextPlaceholder.FindAllString(template, -1) {
+		if !slices.Contains(statusContextPlaceholders, placeholder) {
+			return "", fmt.Errorf("unknown placeholder %q in gitcode status context, expected %s", placeholder, strings.Join(statusContextPlaceholders, ", "))
+		}
+	}
+	return template, nil
+}
+
+// statusContextOf 返回工作流的提交状态上下文，未配置模板时使用服务端的状态上下文格式。
+// 与服务端格式一致，合并请求的事件为 pr；%axis 为矩阵工作流的编号，非矩阵工作流为 0
+func (c *GitCode) statusContextOf(repo *model.Repo, pipeline *model.Pipeline, workflow *model.Workflow) string {
+	if c.statusContext == "" {
+		return common.GetPipelineStatusContext(repo, pipeline, workflow)
+	}
+
+	event := string(pipeline.Event)
+	if pipeline.Event == model.EventPull {
+		event = "pr"
+	}
+	return strings.NewReplacer(
+		"%context", server.Config.Server.StatusContext,
+		"%event", event,
+		"%workflow", workflow.Name,
+		"%owner", repo.Owner,
+		"%repo", repo.Name,
+		"%axis", strconv.Itoa(workflow.AxisID),
+	).Replace(c.statusContext)
+}
+
+// maxStatusDescriptionLength 是 GitCode 提交状态描述允许的最大字符数
+const maxStatusDescriptionLength = 140
+
+// statusDescription 生成提交状态描述，附带耗时和通过的步骤数，
+// 如 "Pipeline was successful in 2m5s, 5/6 steps passed"。workflow 为空时统计整条流水线
+func statusDescription(p *model.Pipeline, w *model.Workflow) string {
+	state, started, finished := p.Status, p.Started, p.Finished
+	steps := pipelineSteps(p)
+	if w != nil {
+		state, started, finished = w.State, w.Started, w.Finished
+		steps = w.Children
+	}
+
+	description := common.GetPipelineStatusDescription(state)
+	if started > 0 && finished >= started {
+		description += " in " + (time.Duration(finished-started) * time.Second).String()
+	}
+	if passed, total := countSteps(steps); total > 0 {
+		description += fmt.Sprintf(", %d/%d steps passed", passed, total)
+	}
+	return truncateDescription(description, maxStatusDescriptionLength)
+}
+
+func pipelineSteps(p *model.Pipeline) []*model.Step {
+	var steps []*model.Step
+	for _, w := range p.Workflows {
+		steps = append(steps, w.Children...)
+	}
+	return steps
+}
+
+// countSteps 统计成功的步骤数和总步骤数，跳过的步骤不计入
+func countSteps(steps []*model.Step) (passed, total int) {
+	for _, step := range steps {
+		switch step.State {
+		case model.StatusSkipped:
+			continue
+		case model.StatusSuccess:
+			passed++
+		}
+		total++
+	}
+	return passed, total
+}
+
+// truncateDescription 按字符截断描述，避免截断多字节字符，截断时以省略号结尾
+func truncateDescription(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+func TestStatusDescription(t *testing.T) {
+	workflow := &model.Workflow{
+		State:    model.StatusFailure,
+		Started:  100,
+		Finished: 225,
+		Children: []*model.Step{
+			{State: model.StatusSuccess},
+			{State: model.StatusSuccess},
+			{State: model.StatusFailure},
+			{State: model.StatusSkipped},
+		},
+	}
+	pipeline := &model.Pipeline{
+		Status:    model.StatusRunning,
+		Started:   100,
+		Workflows: []*model.Workflow{workflow, {Children: []*model.Step{{State: model.StatusRunning}}}},
+	}
+
+	assert.Equal(t, "Pipeline failed in 2m5s, 2/3 steps passed", statusDescription(pipeline, workflow))
+	assert.Equal(t, "Pipeline is running, 2/4 steps passed", statusDescription(pipeline, nil))
+	assert.Equal(t, "Pipeline is pending", statusDescription(&model.Pipeline{Status: model.StatusPending}, nil))
+}
+
+func TestTruncateDescription(t *testing.T) {
+	assert.Equal(t, "short", truncateDescription("short", 10))
+
+	long := strings.Repeat("流水线", 60)
+	truncated := truncateDescription(long, maxStatusDescriptionLength)
+	assert.True(t, utf8.ValidString(truncated))
+	assert.Equal(t, maxStatusDescriptionLength, utf8.RuneCountInString(truncated))
+	assert.True(t, strings.HasSuffix(truncated, "…"))
+}
+
+func TestStatus(t *testing.T) {
+	var request CreateStatusRequest
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "/api/v5/repos/owner/repo/statuses/abc", req.URL.Path)
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+		return http.StatusCreated, `{"id":1,"state":"failure"}`
+	})
+
+	repo := &model.Repo{ID: 1, Owner: "owner", Name: "repo", FullName: "owner/repo"}
+	pipeline := &model.Pipeline{Number: 3, Event: model.EventPush, Commit: "abc"}
+	workflow := &model.Workflow{PID: 1, Name: "build", State: model.StatusFailure, Started: 10, Finished: 15}
+	err := c.Status(t.Context(), &model.User{AccessToken: "token"}, repo, pipeline, workflow)
+	assert.NoError(t, err)
+	assert.Equal(t, "failure", request.State)
+	assert.Equal(t, "Pipeline failed in 5s", request.Description)
+	assert.Contains(t, request.TargetURL, "/repos/1/pipeline/3/1")
+}
+
+func TestStatusPerWorkflow(t *testing.T) {
+	server.Config.Server.Host = "https://ci.example.com"
+	server.Config.Server.StatusContext = "ci/woodpecker"
+	server.Config.Server.StatusContextFormat = "{{ .context }}/{{ .event }}/{{ .workflow }}{{if not (eq .axis_id 0)}}/{{.axis_id}}{{end}}"
+
+	var requests []CreateStatusRequest
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		var request CreateStatusRequest
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+		requests = append(requests, request)
+		return http.StatusCreated, `{"id":1}`
+	})
+
+	repo := &model.Repo{ID: 1, Owner: "owner", Name: "repo", FullName: "owner/repo"}
+	pipeline := &model.Pipeline{Number: 3, Event: model.EventPull, Commit: "abc", Workflows: []*model.Workflow{
+		{PID: 1, Name: "build", State: model.StatusSuccess},
+		{PID: 4, Name: "test", State: model.StatusFailure},
+		{PID: 7, Name: "deploy", State: model.StatusSkipped},
+	}}
+	for _, workflow := range pipeline.Workflows {
+		assert.NoError(t, c.Status(t.Context(), &model.User{AccessToken: "token"}, repo, pipeline, workflow))
+	}
+
+	assert.Len(t, requests, 3)
+	assert.Equal(t, CreateStatusRequest{State: "success", Context: "ci/woodpecker/pr/build", TargetURL: "https://ci.example.com/repos/1/pipeline/3/1", Description: "Pipeline was successful"}, requests[0])
+	assert.Equal(t, CreateStatusRequest{State: "failure", Context: "ci/woodpecker/pr/test", TargetURL: "https://ci.example.com/repos/1/pipeline/3/4", Description: "Pipeline failed"}, requests[1])
+	assert.Equal(t, "cancelled", requests[2].State)
+	assert.Equal(t, "ci/woodpecker/pr/deploy", requests[2].Context)
+}
+
+func TestParseStatusContext(t *testing.T) {
+	template, err := parseStatusContext(" ci/woodpecker/%event/%workflow ")
+	assert.NoError(t, err)
+	assert.Equal(t, "ci/woodpecker/%event/%workflow", template)
+
+	_, err = parseStatusContext("ci/%pipeline")
+	assert.ErrorContains(t, err, `unknown placeholder "%pipeline"`)
+}
+
+func TestStatusContextTemplate(t *testing.T) {
+	server.Config.Server.StatusContext = "ci/woodpecker"
+	server.Config.Server.StatusContextFormat = "{{ .context }}/{{ .event }}/{{ .workflow }}"
+
+	c := &GitCode{}
+	repo := &model.Repo{Owner: "owner", Name: "repo"}
+	pull := &model.Pipeline{Event: model.EventPull}
+	workflow := &model.Workflow{Name: "build", AxisID: 2}
+	assert.Equal(t, "ci/woodpecker/pr/build", c.statusContextOf(repo, pull, workflow))
+
+	c.statusContext = "%context/%owner/%repo/%event/%workflow/%axis"
+	assert.Equal(t, "ci/woodpecker/owner/repo/pr/build/2", c.statusContextOf(repo, pull, workflow))
+	assert.Equal(t, "ci/woodpecker/owner/repo/push/build/2", c.statusContextOf(repo, &model.Pipeline{Event: model.EventPush}, workflow))
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+)
+
+// systemHookQuery 是系统 webhook 地址中指定 forge 的查询参数，系统 webhook 的地址中没有仓库令牌
+const systemHookQuery = "forge_id"
+
+// isSystemHook 判断请求是否为发送到 /api/hook?forge_id=<id> 的系统 webhook
+func isSystemHook(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Get(systemHookQuery) != "" && query.Get("access_token") == ""
+}
+
+// verifySystemHook 校验系统 webhook 携带的密码，未配置 Opts.SystemHookSecret 时拒绝所有系统 webhook
+func (c *GitCode) verifySystemHook(r *http.Request) error {
+	if c.systemHookSecret == "" {
+		return errors.New("system hooks are disabled, set a system hook secret to enable them")
+	}
+	if err := verifySecret(r, c.systemHookSecret); err != nil {
+		return fmt.Errorf("system hook carries an %w", err)
+	}
+	return nil
+}
+
+// SystemHookRepo 校验实例级别的系统 webhook，并按项目 ID 找到投递所属的已激活仓库。
+// 读取后的请求体会被还原，服务端随后调用 Hook 再次解析
+func (c *GitCode) SystemHookRepo(ctx context.Context, r *http.Request) (*model.Repo, error) {
+	if err := c.verifySystemHook(r); err != nil {
+		return nil, err
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+
+	id := hookProjectID(payload)
+	if id == 0 {
+		return nil, errors.New("system hook does not contain a project id")
+	}
+	_store, ok := store.TryFromContext(ctx)
+	if !ok {
+		return nil, errors.New("could not get store from context")
+	}
+	return _store.GetRepoForgeID(model.ForgeRemoteID(strconv.Itoa(id)))
+}
+
+// hookProjectID 返回 webhook 所属项目的 ID，不同事件分别在 project_id、project 或 repository 中携带
+func hookProjectID(payload []byte) int {
+	var hook struct {
+		ProjectID int `json:"project_id"`
+		Project   struct {
+			ID int `json:"id"`
+		} `json:"project"`
+		Repository struct {
+			ID int `json:"id"`
+		} `json:"repository"`
+	}
+	if json.Unmarshal(payload, &hook) != nil {
+		return 0
+	}
+	return cmp.Or(hook.ProjectID, hook.Project.ID, hook.Repository.ID)
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+)
+
+const systemHookPayload = `{
+	"object_kind": "push",
+	"ref": "refs/heads/main",
+	"before": "0000000000000000000000000000000000000000",
+	"after": "abc",
+	"user_username": "jetsung",
+	"project_id": 7720285,
+	"project": {"id": 7720285, "name": "testci", "namespace": "jetsung", "path_with_namespace": "jetsung/testci", "web_url": "https://gitcode.com/jetsung/testci"},
+	"commits": [{"id": "abc", "message": "fix"}]
+}`
+
+func TestHookProjectID(t *testing.T) {
+	assert.Equal(t, 1, hookProjectID([]byte(`{"project_id": 1, "project": {"id": 2}}`)))
+	assert.Equal(t, 2, hookProjectID([]byte(`{"project": {"id": 2}}`)))
+	assert.Equal(t, 3, hookProjectID([]byte(`{"repository": {"id": 3}}`)))
+	assert.Equal(t, 0, hookProjectID([]byte(`not json`)))
+}
+
+func TestSystemHookRepo(t *testing.T) {
+	stored := &model.Repo{ForgeRemoteID: "7720285", FullName: "jetsung/testci", Owner: "jetsung", Name: "testci"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoForgeID", model.ForgeRemoteID("7720285")).Return(stored, nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	request := func(secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook?forge_id=1", strings.NewReader(systemHookPayload))
+		req.Header.Set(hookEvent, hookPush)
+		req.Header.Set(hookToken, secret)
+		return req
+	}
+
+	c := newStubGitCode(t, func(*http.Request) (int, string) {
+		return http.StatusOK, `[]`
+	})
+	_, err := c.SystemHookRepo(ctx, request("system-secret"))
+	assert.ErrorContains(t, err, "system hooks are disabled")
+
+	c.systemHookSecret = "system-secret"
+	_, err = c.SystemHookRepo(ctx, request("guess"))
+	assert.ErrorContains(t, err, "invalid secret")
+
+	req := request("system-secret")
+	repo, err := c.SystemHookRepo(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, stored, repo)
+
+	// 请求体仍可被 Hook 读取
+	body, err := io.ReadAll(req.Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, systemHookPayload, string(body))
+}
+
+func TestHookAcceptsSystemHooks(t *testing.T) {
+	c := newStubGitCode(t, func(*http.Request) (int, string) {
+		return http.StatusOK, `[]`
+	})
+	c.systemHookSecret = "system-secret"
+	hook := func(secret string) (*model.Repo, *model.Pipeline, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook?forge_id=1", strings.NewReader(systemHookPayload))
+		req.Header.Set(hookEvent, hookPush)
+		req.Header.Set(hookToken, secret)
+		return c.Hook(t.Context(), req)
+	}
+
+	// 系统 webhook 不携带仓库的密码
+	repo, pipeline, err := hook("system-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "jetsung/testci", repo.FullName)
+	assert.Equal(t, "abc", pipeline.Commit)
+
+	_, _, err = hook(webhookSecret(&model.Repo{Hash: "hash"}))
+	assert.ErrorContains(t, err, "system hook carries an invalid secret")
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxTraceBodySize 限制记录的请求体和响应体的大小
+const maxTraceBodySize = 4 << 10
+
+// traceMiddleware 记录发送给 GitCode API 的每次尝试，包括地址、状态码、耗时以及请求体和响应体的开头部分，
+// 地址和请求体中的凭据会被隐去。请求体和响应体仍可能包含私有仓库的内容，因此需要显式开启
+func traceMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			event := log.Info().
+				Str("method", req.Method).
+				Str("url", redactURL(req.URL))
+			if req.Body != nil && req.Body != http.NoBody {
+				head, body, err := peekBody(req.Body)
+				if err != nil {
+					return nil, err
+				}
+				req = req.Clone(req.Context())
+				req.Body = body
+				event = event.Str("request_body", traceBody(head))
+			}
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			event = event.Dur("duration", time.Since(start))
+			if err != nil {
+				event.Err(redactError(err)).Msg("GitCode API trace")
+				return nil, err
+			}
+
+			head, body, err := peekBody(resp.Body)
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			resp.Body = body
+			event.
+				Int("status", resp.StatusCode).
+				Str("content_type", resp.Header.Get("Content-Type")).
+				Str("response_body", traceBody(head)).
+				Msg("GitCode API trace")
+			return resp, nil
+		})
+	}
+}
+
+// peekBody 读取 body 的前 maxTraceBodySize+1 个字节，并返回仍能读出完整内容的 body
+func peekBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	head, err := io.ReadAll(io.LimitReader(body, maxTraceBodySize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	return head, struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), body), body}, nil
+}
+
+// traceBody 隐去凭据并截断用于跟踪日志的 body
+func traceBody(head []byte) string {
+	truncated := len(head) > maxTraceBodySize
+	if truncated {
+		head = head[:maxTraceBodySize]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return "<binary>"
+	}
+	// 截断可能拆开多字节字符
+	text := redactText(strings.ToValidUTF8(string(head), ""))
+	if truncated {
+		text += "…(truncated)"
+	}
+	return text
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = logger })
+
+	large := strings.Repeat("x", maxTraceBodySize+100)
+	transport := traceMiddleware()(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, `{"password":"hunter2","name":"hook"}`, string(body))
+		return &http.Response{StatusCode: http.StatusCreated, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(strings.NewReader(large))}, nil
+	}))
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.gitcode.com/api/v5/repos/a/b/hooks?access_token=secret", strings.NewReader(`{"password":"hunter2","name":"hook"}`))
+	resp, err := transport.RoundTrip(req)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, large, string(body), "the traced response body is still read completely")
+
+	out := buf.String()
+	assert.Contains(t, out, `"status":201`)
+	assert.Contains(t, out, `"method":"POST"`)
+	assert.Contains(t, out, "access_token=REDACTED")
+	assert.Contains(t, out, `name\":\"hook`)
+	assert.Contains(t, out, "(truncated)")
+	assert.NotContains(t, out, "secret")
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, large)
+}
+
+func TestTraceBody(t *testing.T) {
+	assert.Equal(t, "<binary>", traceBody([]byte("PK\x03\x04\x00\x00")))
+	assert.Equal(t, `{"client_secret":"REDACTED"}`, traceBody([]byte(`{"client_secret":"abc"}`)))
+	// a multi-byte character cut at the limit is dropped
+	head := []byte(strings.Repeat("a", maxTraceBodySize-1) + "中")
+	assert.Equal(t, strings.Repeat("a", maxTraceBodySize-1)+"…(truncated)", traceBody(head))
+}
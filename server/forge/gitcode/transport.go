@@ -0,0 +1,177 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// Middleware 包装 http.RoundTripper，为 GitCode 客户端增加单一功能，例如认证、日志或重试
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// roundTripperFunc 将函数适配为 http.RoundTripper 接口
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// chain 将中间件依次包装在 base 外层，第一个中间件位于最外层，最先处理每个请求
+func chain(base http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		base = middlewares[i](base)
+	}
+	return base
+}
+
+// headerMiddleware 设置每个 GitCode API 请求都携带的请求头
+func headerMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("User-Agent", "Woodpecker-CI")
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// AuthMode 决定 access token 发送给 GitCode API 的方式
+type AuthMode string
+
+const (
+	// AuthModeBearer 通过 "Authorization: Bearer" 请求头发送 token
+	AuthModeBearer AuthMode = "bearer"
+	// AuthModePrivateToken 通过 "PRIVATE-TOKEN" 请求头发送 token
+	AuthModePrivateToken AuthMode = "private-token"
+	// AuthModeQuery 将 token 作为 access_token 查询参数附加到地址中。token 会出现在代理和服务器日志中，
+	// 仅用于不支持上述请求头的旧版实例
+	AuthModeQuery AuthMode = "query"
+)
+
+// parseAuthMode 校验 mode，为空时使用 AuthModeBearer
+func parseAuthMode(mode string) (AuthMode, error) {
+	switch m := AuthMode(strings.ToLower(strings.TrimSpace(mode))); m {
+	case "":
+		return AuthModeBearer, nil
+	case AuthModeBearer, AuthModePrivateToken, AuthModeQuery:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown gitcode auth mode %q, expected one of %s, %s or %s", mode, AuthModeBearer, AuthModePrivateToken, AuthModeQuery)
+	}
+}
+
+// authMiddleware 使用给定的 access token 认证请求
+func authMiddleware(token string, mode AuthMode) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if token == "" {
+			return next
+		}
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			switch mode {
+			case AuthModeQuery:
+				q := req.URL.Query()
+				q.Set("access_token", token)
+				req.URL.RawQuery = q.Encode()
+			case AuthModePrivateToken:
+				req.Header.Set("PRIVATE-TOKEN", token)
+			default:
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+type correlationKey struct{}
+
+// withCorrelation 在 ctx 中记录 id，便于在日志中找到同一流水线或投递发出的所有 API 请求
+func withCorrelation(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// withPipeline 将请求关联到流水线 p，仅在流水线已存入数据库时生效
+func withPipeline(ctx context.Context, p *model.Pipeline) context.Context {
+	if p == nil || p.ID == 0 {
+		return ctx
+	}
+	return withCorrelation(ctx, "pipeline-"+strconv.FormatInt(p.ID, 10))
+}
+
+func correlationFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// loggingMiddleware 记录每个请求的方法、接口、状态码、耗时和关联 ID。失败的请求记录为 debug 级别，
+// 成功的请求记录为 trace 级别。成功的读请求占大部分流量，设置了 sampler 时会经过采样
+func loggingMiddleware(sampler zerolog.Sampler) Middleware {
+	sampled := log.Logger
+	if sampler != nil {
+		sampled = log.Logger.Sample(sampler)
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			var event *zerolog.Event
+			switch {
+			case err != nil || resp.StatusCode >= http.StatusBadRequest:
+				event = log.Debug()
+			case req.Method == http.MethodGet:
+				event = sampled.Trace()
+			default:
+				event = log.Trace()
+			}
+			event = event.
+				Str("method", req.Method).
+				Str("endpoint", req.URL.Path).
+				Stringer("operation", operationFromContext(req.Context())).
+				Dur("duration", time.Since(start))
+			if id := correlationFromContext(req.Context()); id != "" {
+				event = event.Str("correlation_id", id)
+			}
+			if err != nil {
+				event.Err(redactError(err)).Msg("GitCode API request failed")
+				return nil, err
+			}
+			event.Int("status", resp.StatusCode).Msg("GitCode API request")
+			return resp, nil
+		})
+	}
+}
+
+// retryMiddleware 在暂时性网络错误时重试幂等请求
+func retryMiddleware(maxRetries int, backoff time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &retryTransport{
+			next:       next,
+			maxRetries: maxRetries,
+			backoff:    backoff,
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	transport := chain(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		order = append(order, "base")
+		return okResponse(), nil
+	}), record("first"), record("second"))
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.gitcode.com/api/v5/user", nil)
+	_, err := transport.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "base"}, order)
+}
+
+func TestAuthAndHeaderMiddleware(t *testing.T) {
+	var seen *http.Request
+	transport := chain(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		seen = req
+		return okResponse(), nil
+	}), headerMiddleware(), authMiddleware("secret&token", AuthModeBearer))
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.gitcode.com/api/v5/user?page=1", nil)
+	_, err := transport.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret&token", seen.Header.Get("Authorization"))
+	assert.Empty(t, seen.URL.Query().Get("access_token"))
+	assert.Equal(t, "1", seen.URL.Query().Get("page"))
+	assert.Equal(t, "application/json", seen.Header.Get("Accept"))
+	// the original request is left untouched
+	assert.Empty(t, req.Header.Get("Authorization"))
+	assert.Empty(t, req.Header.Get("Accept"))
+}
+
+func TestAuthModes(t *testing.T) {
+	var seen *http.Request
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		seen = req
+		return okResponse(), nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.gitcode.com/api/v5/user?page=1", nil)
+	_, err := chain(base, authMiddleware("secret&token", AuthModePrivateToken)).RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret&token", seen.Header.Get("PRIVATE-TOKEN"))
+	assert.Empty(t, seen.Header.Get("Authorization"))
+
+	_, err = chain(base, authMiddleware("secret&token", AuthModeQuery)).RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret&token", seen.URL.Query().Get("access_token"))
+	assert.Equal(t, "1", seen.URL.Query().Get("page"))
+	assert.Empty(t, seen.Header.Get("Authorization"))
+	assert.Empty(t, req.URL.Query().Get("access_token"))
+
+	mode, err := parseAuthMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, AuthModeBearer, mode)
+	mode, err = parseAuthMode(" Private-Token ")
+	assert.NoError(t, err)
+	assert.Equal(t, AuthModePrivateToken, mode)
+	_, err = parseAuthMode("cookie")
+	assert.Error(t, err)
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf).Level(zerolog.TraceLevel)
+	t.Cleanup(func() { log.Logger = logger })
+
+	status := http.StatusOK
+	transport := chain(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: status}, nil
+	}), loggingMiddleware(&zerolog.BasicSampler{N: 2}))
+
+	ctx, cancel := defaultOperationTimeouts.withOperation(withPipeline(t.Context(), &model.Pipeline{ID: 42}), opSync)
+	defer cancel()
+	for range 4 {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.gitcode.com/api/v5/user/repos?access_token=secret", nil)
+		_, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+	}
+	// only every second successful read is logged
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	assert.Contains(t, buf.String(), `"endpoint":"/api/v5/user/repos"`)
+	assert.Contains(t, buf.String(), `"operation":"sync"`)
+	assert.Contains(t, buf.String(), `"correlation_id":"pipeline-42"`)
+	assert.NotContains(t, buf.String(), "secret")
+
+	// failures are never sampled
+	buf.Reset()
+	status = http.StatusNotFound
+	for range 2 {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.gitcode.com/api/v5/user", nil)
+		_, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, strings.Count(buf.String(), `"level":"debug"`))
+}
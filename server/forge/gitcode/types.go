@@ -55,6 +55,13 @@ type pullRequestHook struct {
 		MergeStatus               string `json:"merge_status"`
 		WorkInProgress            bool   `json:"work_in_progress"`
 		MergeWhenPipelineSucceeds bool   `json:"merge_when_pipeline_succeeds"`
+		MergeCommitSHA            string `json:"merge_commit_sha"` // 合并后生成的提交，压缩合并时为压缩后的提交
+		Squash                    bool   `json:"squash"`
+
+		// 目标分支的最新提交，合并后即为合并产生的提交
+		TargetBranchCommit struct {
+			ID string `json:"id"`
+		} `json:"target_branch_commit"`
 
 		Author struct {
 			ID        int    `json:"id"`
@@ -116,8 +123,42 @@ type pullRequestHook struct {
 		Homepage        string `json:"homepage"`
 	} `json:"repository"`
 
-	Labels  []interface{} `json:"labels"`
-	Changes interface{}   `json:"changes"`
+	Labels  []*Label `json:"labels"`
+	Changes struct {
+		// 仅在标签变化时返回
+		Labels *struct {
+			Previous []*Label `json:"previous"`
+			Current  []*Label `json:"current"`
+		} `json:"labels"`
+		// 仅在草稿状态变化时返回，不同实例使用 draft 或 work_in_progress
+		Draft          *boolChange `json:"draft"`
+		WorkInProgress *boolChange `json:"work_in_progress"`
+	} `json:"changes"`
+}
+
+// boolChange webhook 中布尔字段变化前后的值
+type boolChange struct {
+	Previous bool `json:"previous"`
+	Current  bool `json:"current"`
+}
+
+// hookProject webhook 中的项目信息
+type hookProject struct {
+	ID                int    `json:"id"`                  // 项目 ID
+	Name              string `json:"name"`                // 项目名称
+	Description       string `json:"description"`         // 项目描述
+	WebURL            string `json:"web_url"`             // 项目的 Web 访问地址
+	AvatarURL         string `json:"avatar_url"`          // 项目头像的 URL 地址
+	GitSSHURL         string `json:"git_ssh_url"`         // 项目的 SSH 克隆地址
+	GitHTTPURL        string `json:"git_http_url"`        // 项目的 HTTP 克隆地址
+	Namespace         string `json:"namespace"`           // 项目的命名空间
+	VisibilityLevel   int    `json:"visibility_level"`    // 项目可见性级别（0: 私有, 10: 内部, 20: 公开）
+	PathWithNamespace string `json:"path_with_namespace"` // 带命名空间的项目路径
+	DefaultBranch     string `json:"default_branch"`      // 项目的默认分支
+	Homepage          string `json:"homepage"`            // 项目主页 URL
+	URL               string `json:"url"`                 // 项目 Git 仓库 URL
+	SSHURL            string `json:"ssh_url"`             // 项目 SSH 克隆 URL
+	HTTPURL           string `json:"http_url"`            // 项目 HTTP 克隆 URL
 }
 
 // pushHook GitCode push webhook 数据结构
@@ -139,24 +180,8 @@ type pushHook struct {
 	UserAvatar   string `json:"user_avatar"`   // 用户头像的 URL 地址
 
 	// 项目信息
-	ProjectID int `json:"project_id"` // 项目的唯一标识符
-	Project   struct {
-		ID                int    `json:"id"`                  // 项目 ID
-		Name              string `json:"name"`                // 项目名称
-		Description       string `json:"description"`         // 项目描述
-		WebURL            string `json:"web_url"`             // 项目的 Web 访问地址
-		AvatarURL         string `json:"avatar_url"`          // 项目头像的 URL 地址
-		GitSSHURL         string `json:"git_ssh_url"`         // 项目的 SSH 克隆地址
-		GitHTTPURL        string `json:"git_http_url"`        // 项目的 HTTP 克隆地址
-		Namespace         string `json:"namespace"`           // 项目的命名空间
-		VisibilityLevel   int    `json:"visibility_level"`    // 项目可见性级别（0: 私有, 1:公开）
-		PathWithNamespace string `json:"path_with_namespace"` // 带命名空间的项目路径
-		DefaultBranch     string `json:"default_branch"`      // 项目的默认分支
-		Homepage          string `json:"homepage"`            // 项目主页 URL
-		URL               string `json:"url"`                 // 项目 Git 仓库 URL
-		SSHURL            string `json:"ssh_url"`             // 项目 SSH 克隆 URL
-		HTTPURL           string `json:"http_url"`            // 项目 HTTP 克隆 URL
-	} `json:"project"`
+	ProjectID int         `json:"project_id"` // 项目的唯一标识符
+	Project   hookProject `json:"project"`
 
 	// 提交信息
 	Commits []struct {
@@ -171,6 +196,10 @@ type pushHook struct {
 		Added    []string `json:"added"`    // 本次提交新增的文件列表
 		Removed  []string `json:"removed"`  // 本次提交删除的文件列表
 		Modified []string `json:"modified"` // 本次提交修改的文件列表
+		Renamed  []struct {
+			OldPath string `json:"old_path"` // 重命名前的路径
+			NewPath string `json:"new_path"` // 重命名后的路径
+		} `json:"renamed"` // 本次提交重命名的文件列表，仅部分 GitCode 版本提供
 	} `json:"commits"`
 
 	// 统计信息
@@ -185,7 +214,7 @@ type pushHook struct {
 		Homepage        string `json:"homepage"`         // 仓库主页 URL
 		GitHTTPURL      string `json:"git_http_url"`     // 仓库 HTTP 克隆地址
 		GitSSHURL       string `json:"git_ssh_url"`      // 仓库 SSH 克隆地址
-		VisibilityLevel int    `json:"visibility_level"` // 仓库可见性级别（0: 私有, 1: 公开）
+		VisibilityLevel int    `json:"visibility_level"` // 仓库可见性级别（0: 私有, 10: 内部, 20: 公开）
 	} `json:"repository"`
 
 	// 分支和提交信息
@@ -195,6 +224,28 @@ type pushHook struct {
 	UUID        string `json:"uuid"`          // 本次推送事件的唯一标识符
 }
 
+// deploymentHook GitCode deployment webhook 数据结构
+type deploymentHook struct {
+	ObjectKind   string `json:"object_kind"`   // 事件类型，此处为 "deployment"
+	Status       string `json:"status"`        // 部署状态，创建时为 "created" 或为空
+	DeploymentID int64  `json:"deployment_id"` // 部署 ID
+	Environment  string `json:"environment"`   // 目标环境
+	Task         string `json:"task"`          // 部署任务，可选
+	Description  string `json:"description"`   // 部署描述，可选
+	Ref          string `json:"ref"`           // 部署的分支、标签或提交
+	SHA          string `json:"sha"`           // 部署的提交 SHA 值
+	CommitURL    string `json:"commit_url"`    // 提交详情页面的 URL
+	CommitTitle  string `json:"commit_title"`  // 提交信息的标题
+
+	User struct {
+		Username  string `json:"username"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	} `json:"user"`
+
+	Project hookProject `json:"project"`
+}
+
 // releaseHook GitCode release webhook 数据结构
 type releaseHook struct {
 	Action  string      `json:"action"`
@@ -205,13 +256,72 @@ type releaseHook struct {
 
 // Release GitCode release 信息
 type Release struct {
-	ID          int64  `json:"id"`
-	TagName     string `json:"tag_name"`
-	Name        string `json:"name"`
-	Body        string `json:"body"`
-	Draft       bool   `json:"draft"`
-	Prerelease  bool   `json:"prerelease"`
-	CreatedAt   string `json:"created_at"`
-	PublishedAt string `json:"published_at"`
-	Author      *User  `json:"author"`
+	ID          int64           `json:"id"`
+	TagName     string          `json:"tag_name"`
+	Name        string          `json:"name"`
+	Body        string          `json:"body"`
+	Draft       bool            `json:"draft"`
+	Prerelease  bool            `json:"prerelease"`
+	CreatedAt   string          `json:"created_at"`
+	PublishedAt string          `json:"published_at"`
+	Author      *User           `json:"author"`
+	Assets      []*ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset GitCode release 附件信息
+type ReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Size               int64  `json:"size"`
+}
+
+// noteHook GitCode note (评论) webhook 数据结构，仅包含合并请求评论使用的字段
+type noteHook struct {
+	ObjectKind string `json:"object_kind"` // 事件类型，此处为 "note"
+
+	User struct {
+		ID        int    `json:"id"`
+		Name      string `json:"name"`
+		Username  string `json:"username"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	} `json:"user"`
+
+	ProjectID int         `json:"project_id"` // 项目的唯一标识符
+	Project   hookProject `json:"project"`
+
+	ObjectAttributes struct {
+		ID           int    `json:"id"`
+		Note         string `json:"note"`          // 评论内容
+		NoteableType string `json:"noteable_type"` // 评论对象类型，如 "MergeRequest"、"Issue"、"Commit"
+		URL          string `json:"url"`           // 评论的 URL
+	} `json:"object_attributes"`
+
+	MergeRequest struct {
+		ID           int    `json:"id"`
+		IID          int    `json:"iid"`
+		Title        string `json:"title"`
+		State        string `json:"state"`
+		TargetBranch string `json:"target_branch"`
+		SourceBranch string `json:"source_branch"`
+		URL          string `json:"url"`
+
+		SourceProjectID int `json:"source_project_id"`
+		TargetProjectID int `json:"target_project_id"`
+
+		LastCommit struct {
+			ID      string `json:"id"`
+			Message string `json:"message"`
+		} `json:"last_commit"`
+
+		Source struct {
+			ID                int    `json:"id"`
+			PathWithNamespace string `json:"path_with_namespace"`
+		} `json:"source"`
+
+		Target struct {
+			ID                int    `json:"id"`
+			PathWithNamespace string `json:"path_with_namespace"`
+		} `json:"target"`
+	} `json:"merge_request"`
 }
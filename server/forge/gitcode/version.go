@@ -0,0 +1,124 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	apiV5 = "v5"
+	apiV6 = "v6"
+
+	// versionProbeTimeout 限制启动时在后台探测 API 版本的耗时
+	versionProbeTimeout = 5 * time.Second
+)
+
+// apiAdapter 隔离不同 API 版本之间的请求和响应差异
+type apiAdapter interface {
+	// version 返回 API 版本号，如 "v5"
+	version() string
+	// baseURL 返回 API 根地址
+	baseURL() string
+	// pageQuery 构建分页查询参数
+	pageQuery(page, limit int) url.Values
+	// decode 将响应体解析到 result
+	decode(body []byte, result any) error
+}
+
+// v5Adapter 对应当前的 GitCode API v5，apiURL 为空时使用 gitcode.com 的 API 地址
+type v5Adapter struct {
+	apiURL string
+}
+
+func (v5Adapter) version() string { return apiV5 }
+
+func (a v5Adapter) baseURL() string {
+	if a.apiURL == "" {
+		return defaultAPI
+	}
+	return a.apiURL
+}
+
+func (v5Adapter) pageQuery(page, limit int) url.Values { return pageQuery(page, limit) }
+
+func (v5Adapter) decode(body []byte, result any) error { return json.Unmarshal(body, result) }
+
+// v6Adapter 对应 GitCode API v6，列表接口可能使用 {"data": [...]} 包装
+type v6Adapter struct {
+	v5Adapter
+}
+
+func (v6Adapter) version() string { return apiV6 }
+
+func (a v6Adapter) baseURL() string { return strings.TrimSuffix(a.v5Adapter.baseURL(), apiV5) + apiV6 }
+
+func (a v6Adapter) decode(body []byte, result any) error {
+	if isSlicePointer(result) && bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(body, &envelope); err == nil && envelope.Data != nil {
+			body = envelope.Data
+		}
+	}
+	return a.v5Adapter.decode(body, result)
+}
+
+func isSlicePointer(v any) bool {
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Slice
+}
+
+// adapterFor 返回指定版本的适配器，未知版本使用 v5
+func adapterFor(version, apiURL string) apiAdapter {
+	if version == apiV6 {
+		return v6Adapter{v5Adapter{apiURL: apiURL}}
+	}
+	return v5Adapter{apiURL: apiURL}
+}
+
+// probeAPIVersion 判断实例是否提供 v6，默认使用 v5。只有 v6 的 /user 返回 JSON 对象，
+// 或以 GitCode 自身的错误格式拒绝未认证的请求时才使用 v6；404、网关对未知路径返回的
+// 401/403/5xx 以及网络错误都不足以说明 v6 可用。apiURL 为 v5 的 API 地址，v6 的地址由它推导
+func probeAPIVersion(ctx context.Context, apiURL string, newClient func(apiAdapter) *GitCodeClient) apiAdapter {
+	ctx, cancel := context.WithTimeout(ctx, versionProbeTimeout)
+	defer cancel()
+
+	v5 := v5Adapter{apiURL: apiURL}
+	v6 := v6Adapter{v5}
+	_, body, err := newClient(v6).fetch(ctx, userEndpoint(), nil)
+
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) && json.Valid(body) {
+			return v6
+		}
+	case errors.As(err, &apiErr):
+		if apiErr.StatusCode == http.StatusUnauthorized && (apiErr.Code != "" || apiErr.Number != 0) {
+			return v6
+		}
+	}
+	return v5
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestV6AdapterDecode(t *testing.T) {
+	adapter := v6Adapter{}
+
+	var branches []*Branch
+	assert.NoError(t, adapter.decode([]byte(`{"data":[{"name":"main"}],"total":1}`), &branches))
+	assert.Len(t, branches, 1)
+	assert.Equal(t, "main", branches[0].Name)
+
+	branches = nil
+	assert.NoError(t, adapter.decode([]byte(`[{"name":"dev"}]`), &branches))
+	assert.Equal(t, "dev", branches[0].Name)
+
+	var branch *Branch
+	assert.NoError(t, adapter.decode([]byte(`{"name":"main","data":"ignored"}`), &branch))
+	assert.Equal(t, "main", branch.Name)
+}
+
+func TestProbeAPIVersion(t *testing.T) {
+	respond := func(status int, body string, err error) Middleware {
+		return func(http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if err != nil {
+					return nil, err
+				}
+				assert.Equal(t, "/api/v6/user", req.URL.Path)
+				return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+			})
+		}
+	}
+
+	probe := func(status int, body string, err error) string {
+		return probeAPIVersion(context.Background(), "", func(adapter apiAdapter) *GitCodeClient {
+			return NewGitCodeClient("", false, WithAPIAdapter(adapter), WithRetry(0, 0), WithMiddleware(respond(status, body, err)))
+		}).version()
+	}
+
+	assert.Equal(t, apiV6, probe(http.StatusOK, `{"login":"octocat"}`, nil))
+	assert.Equal(t, apiV6, probe(http.StatusUnauthorized, `{"error_code":401,"error_code_name":"UNAUTHORIZED","error_message":"token required"}`, nil))
+
+	// 网关对未知路径的响应不说明 v6 可用
+	assert.Equal(t, apiV5, probe(http.StatusUnauthorized, `{}`, nil))
+	assert.Equal(t, apiV5, probe(http.StatusForbidden, `<html>forbidden</html>`, nil))
+	assert.Equal(t, apiV5, probe(http.StatusBadGateway, `{"error_code":502}`, nil))
+	assert.Equal(t, apiV5, probe(http.StatusOK, `<html>app</html>`, nil))
+	assert.Equal(t, apiV5, probe(http.StatusNotFound, `{}`, nil))
+	assert.Equal(t, apiV5, probe(0, "", errors.New("tls: bad certificate")))
+}
+
+func TestAdapterBaseURL(t *testing.T) {
+	assert.Equal(t, defaultAPI, adapterFor(apiV5, "").baseURL())
+	assert.Equal(t, "https://api.gitcode.com/api/v6", adapterFor(apiV6, "").baseURL())
+	assert.Equal(t, "https://git.example.com/api/v5", adapterFor(apiV5, "https://git.example.com/api/v5").baseURL())
+	assert.Equal(t, "https://git.example.com/api/v6", adapterFor(apiV6, "https://git.example.com/api/v5").baseURL())
+}
@@ -0,0 +1,254 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store/types"
+	"go.woodpecker-ci.org/woodpecker/v3/shared/token"
+	shared_utils "go.woodpecker-ci.org/woodpecker/v3/shared/utils"
+)
+
+const (
+	// hookToken 携带注册 webhook 时设置的密码，签名模式下携带签名
+	hookToken = "X-Gitcode-Token"
+	// hookTimestamp 携带签名使用的毫秒时间戳
+	hookTimestamp = "X-Gitcode-Timestamp"
+	// maxSignatureAge 是签名时间戳与当前时间的最大偏差，更早的签名视为重放
+	maxSignatureAge = time.Hour
+)
+
+// webhookSecret 由仓库激活时生成并保存的 Hash 派生出 webhook 密码，
+// 重新激活或修复仓库时保持不变
+func webhookSecret(r *model.Repo) string {
+	mac := hmac.New(sha256.New, []byte(r.Hash))
+	mac.Write([]byte("gitcode-webhook"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// authenticateHook 在解析请求体之前，通过 webhook 地址中的令牌找到激活的仓库并校验密码。
+// 地址中没有令牌或者作为 addon 运行时返回 nil，由 verifyHookRepo 在解析之后校验
+func authenticateHook(ctx context.Context, r *http.Request) (*model.Repo, error) {
+	_store, ok := store.TryFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	raw := r.URL.Query().Get("access_token")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var stored *model.Repo
+	_, err := token.Parse([]token.Type{token.HookToken}, raw, func(t *token.Token) (string, error) {
+		var err error
+		if remoteID := t.Get("repo-forge-remote-id"); remoteID != "" {
+			stored, err = _store.GetRepoForgeID(model.ForgeRemoteID(remoteID))
+		} else {
+			var id int64
+			if id, err = strconv.ParseInt(t.Get("repo-id"), 10, 64); err == nil {
+				stored, err = _store.GetRepo(id)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		return stored.Hash, nil
+	})
+	if err != nil {
+		rejectedHooks.WithLabelValues("invalid_token").Inc()
+		return nil, fmt.Errorf("could not verify the webhook token: %w", err)
+	}
+	return stored, verifyWebhookSecret(r, stored, stored)
+}
+
+// verifyHookRepo 校验 webhook 中的仓库与激活的仓库是同一个，且携带的密码与仓库的密码一致。
+// 仓库未激活时交由服务端处理；作为 addon 运行时无法读取仓库，跳过校验
+func verifyHookRepo(ctx context.Context, r *http.Request, repo *model.Repo) error {
+	_store, ok := store.TryFromContext(ctx)
+	if !ok {
+		log.Debug().Msgf("GitCode: could not verify the webhook of %s without a store", repo.FullName)
+		return nil
+	}
+
+	stored, err := _store.GetRepoNameFallback(repo.ForgeRemoteID, repo.FullName)
+	if errors.Is(err, types.RecordNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := verifyRepoIdentity(repo, stored); err != nil {
+		rejectedHooks.WithLabelValues("repo_mismatch").Inc()
+		return err
+	}
+	return verifyWebhookSecret(r, repo, stored)
+}
+
+// verifyRepoIdentity 校验 webhook 中的仓库与激活的仓库一致。按名称找到的仓库可能已被删除后重建，
+// 因此 webhook 带有项目 ID 时以 ID 为准，仓库改名后 ID 不变；不带 ID 时比较克隆地址
+func verifyRepoIdentity(repo, stored *model.Repo) error {
+	if repo.ForgeRemoteID.IsValid() && stored.ForgeRemoteID.IsValid() {
+		if repo.ForgeRemoteID != stored.ForgeRemoteID {
+			return fmt.Errorf("webhook for %s belongs to project %s, but the repository was activated as project %s", repo.FullName, repo.ForgeRemoteID, stored.ForgeRemoteID)
+		}
+		return nil
+	}
+	if repo.Clone != "" && stored.Clone != "" && normalizeCloneURL(repo.Clone) != normalizeCloneURL(stored.Clone) {
+		return fmt.Errorf("webhook for %s comes from %s, but the repository was activated from %s", repo.FullName, repo.Clone, stored.Clone)
+	}
+	return nil
+}
+
+// normalizeCloneURL 去除克隆地址中不影响仓库身份的差异
+func normalizeCloneURL(clone string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(clone, "/"), ".git"))
+}
+
+// verifyWebhookSecret 校验 webhook 携带的密码与激活的仓库的密码一致
+func verifyWebhookSecret(r *http.Request, repo, stored *model.Repo) error {
+	err := verifySecret(r, webhookSecret(stored))
+	if errors.Is(err, errMissingSecret) {
+		return fmt.Errorf("webhook for %s carries no secret, repair the repository to register the webhook again", repo.FullName)
+	}
+	if err != nil {
+		return fmt.Errorf("webhook for %s carries an %w", repo.FullName, err)
+	}
+	return nil
+}
+
+// errMissingSecret 表示 webhook 没有携带密码
+var errMissingSecret = errors.New("no secret")
+
+// verifySecret 校验 webhook 携带的密码并统计被拒绝的投递。
+// webhook 使用签名模式时，hookToken 中是由密码和 hookTimestamp 计算的签名
+func verifySecret(r *http.Request, secret string) error {
+	value := r.Header.Get(hookToken)
+	if value == "" {
+		rejectedHooks.WithLabelValues("missing_secret").Inc()
+		return errMissingSecret
+	}
+	if subtle.ConstantTimeCompare([]byte(value), []byte(secret)) == 1 {
+		return nil
+	}
+	if timestamp := r.Header.Get(hookTimestamp); timestamp != "" {
+		if err := verifySignature(value, timestamp, secret, time.Now()); err != nil {
+			rejectedHooks.WithLabelValues("invalid_signature").Inc()
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+		return nil
+	}
+	rejectedHooks.WithLabelValues("invalid_secret").Inc()
+	return errors.New("invalid secret")
+}
+
+// verifySignature 校验签名模式的 webhook：签名为以密码为密钥对 "<时间戳>\n<密码>" 计算的
+// HMAC-SHA256 经 base64 编码的结果，可能再经过 URL 编码
+func verifySignature(signature, timestamp, secret string, now time.Time) error {
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.UnixMilli(ms)); age > maxSignatureAge || age < -maxSignatureAge {
+		return fmt.Errorf("timestamp %s is too far from the current time", time.UnixMilli(ms).UTC().Format(time.RFC3339))
+	}
+
+	if unescaped, err := url.PathUnescape(signature); err == nil {
+		signature = unescaped
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	if !hmac.Equal([]byte(signature), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// hookEvents 是可以为 webhook 注册的事件
+var hookEvents = []string{"push", "tag_push", "pull_request", "release", "note", "issues", "deployment"}
+
+// defaultHookEvents 是未配置时注册的事件
+var defaultHookEvents = []string{"push", "tag_push", "pull_request", "release"}
+
+// parseHookEvents 校验并去重要注册的 webhook 事件，为空时使用 defaultHookEvents
+func parseHookEvents(values []string) ([]string, error) {
+	var events []string
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if !slices.Contains(hookEvents, value) {
+			return nil, fmt.Errorf("unknown webhook event %q, expected one of %s", value, strings.Join(hookEvents, ", "))
+		}
+		events = append(events, value)
+	}
+	if len(events) == 0 {
+		return defaultHookEvents, nil
+	}
+	return shared_utils.Deduplicate(events), nil
+}
+
+// isWoodpeckerHook 判断 hookURL 是否指向 Woodpecker 的 webhook 地址 link。只比较路径，
+// 服务端更换域名后以旧域名注册的 webhook 也能被识别
+func isWoodpeckerHook(hookURL, link string) bool {
+	if hookURL == link {
+		return true
+	}
+	hook, err := url.Parse(hookURL)
+	if err != nil {
+		return false
+	}
+	target, err := url.Parse(link)
+	if err != nil || target.Path == "" {
+		return false
+	}
+	return strings.TrimSuffix(hook.Path, "/") == strings.TrimSuffix(target.Path, "/")
+}
+
+// findHook 返回指向 link 的 webhook，不存在或无法列出时返回 nil
+func (c *GitCode) findHook(ctx context.Context, client *GitCodeClient, r *model.Repo, link string) *Hook {
+	hooks, err := shared_utils.Paginate(func(page int) ([]*Hook, error) {
+		return client.GetHooks(ctx, r.Owner, r.Name, page, c.perPage(ctx))
+	}, -1)
+	if err != nil {
+		log.Debug().Err(err).Msgf("GitCode: could not list webhooks of %s", r.FullName)
+		return nil
+	}
+	for _, hook := range hooks {
+		if hook.URL == link {
+			return hook
+		}
+	}
+	return nil
+}
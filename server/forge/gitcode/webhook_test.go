@@ -0,0 +1,197 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitcode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store/types"
+	"go.woodpecker-ci.org/woodpecker/v3/shared/token"
+)
+
+func TestVerifyHookRepo(t *testing.T) {
+	stored := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Hash: "hash"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("1"), "owner/repo").Return(stored, nil)
+	mockStore.On("GetRepoNameFallback", model.ForgeRemoteID("2"), "owner/other").Return(nil, types.RecordNotExist)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	request := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/hook", nil)
+		if token != "" {
+			req.Header.Set(hookToken, token)
+		}
+		return req
+	}
+	repo := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo"}
+
+	assert.NoError(t, verifyHookRepo(ctx, request(webhookSecret(stored)), repo))
+	assert.ErrorContains(t, verifyHookRepo(ctx, request(""), repo), "carries no secret")
+	assert.ErrorContains(t, verifyHookRepo(ctx, request("guess"), repo), "invalid secret")
+	assert.NotEqual(t, webhookSecret(stored), webhookSecret(&model.Repo{Hash: "other"}))
+
+	// 未激活的仓库由服务端拒绝
+	assert.NoError(t, verifyHookRepo(ctx, request(""), &model.Repo{ForgeRemoteID: "2", FullName: "owner/other"}))
+}
+
+func TestAuthenticateHook(t *testing.T) {
+	stored := &model.Repo{ID: 5, ForgeRemoteID: "1", FullName: "owner/repo", Hash: "hash"}
+	mockStore := store_mocks.NewMockStore(t)
+	mockStore.On("GetRepoForgeID", model.ForgeRemoteID("1")).Return(stored, nil)
+	ctx := store.InjectToContext(t.Context(), mockStore)
+
+	request := func(hash, secret string) *http.Request {
+		link := token.New(token.HookToken)
+		link.Set("repo-forge-remote-id", "1")
+		signed, err := link.Sign(hash)
+		assert.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/api/hook?access_token="+signed, nil)
+		req.Header.Set(hookToken, secret)
+		return req
+	}
+
+	repo, err := authenticateHook(ctx, request("hash", webhookSecret(stored)))
+	assert.NoError(t, err)
+	assert.Equal(t, stored, repo)
+
+	rejected := testutil.ToFloat64(rejectedHooks.WithLabelValues("invalid_secret"))
+	_, err = authenticateHook(ctx, request("hash", "guess"))
+	assert.ErrorContains(t, err, "invalid secret")
+	assert.Equal(t, rejected+1, testutil.ToFloat64(rejectedHooks.WithLabelValues("invalid_secret")))
+
+	_, err = authenticateHook(ctx, request("other", webhookSecret(stored)))
+	assert.ErrorContains(t, err, "could not verify the webhook token")
+
+	// 没有令牌时在解析之后按名称校验
+	repo, err = authenticateHook(ctx, httptest.NewRequest(http.MethodPost, "/api/hook", nil))
+	assert.NoError(t, err)
+	assert.Nil(t, repo)
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	sign := func(timestamp, secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "\n" + secret))
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+
+	assert.NoError(t, verifySignature(sign(timestamp, "secret"), timestamp, "secret", now))
+	assert.NoError(t, verifySignature(url.QueryEscape(sign(timestamp, "secret")), timestamp, "secret", now))
+	assert.ErrorContains(t, verifySignature(sign(timestamp, "other"), timestamp, "secret", now), "does not match")
+	assert.ErrorContains(t, verifySignature(sign(timestamp, "secret"), timestamp, "secret", now.Add(2*time.Hour)), "too far")
+	assert.ErrorContains(t, verifySignature(sign("x", "secret"), "x", "secret", now), "invalid timestamp")
+
+	// 签名模式的 webhook 通过 verifyWebhookSecret 校验
+	stored := &model.Repo{FullName: "owner/repo", Hash: "hash"}
+	req := httptest.NewRequest(http.MethodPost, "/api/hook", nil)
+	current := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	req.Header.Set(hookToken, sign(current, webhookSecret(stored)))
+	req.Header.Set(hookTimestamp, current)
+	assert.NoError(t, verifyWebhookSecret(req, stored, stored))
+}
+
+func TestVerifyRepoIdentity(t *testing.T) {
+	stored := &model.Repo{ForgeRemoteID: "1", FullName: "owner/repo", Clone: "https://gitcode.com/owner/repo.git"}
+
+	// 改名后项目 ID 不变
+	assert.NoError(t, verifyRepoIdentity(&model.Repo{ForgeRemoteID: "1", FullName: "owner/renamed", Clone: "https://gitcode.com/owner/renamed.git"}, stored))
+	// 同名仓库被删除后重建
+	assert.ErrorContains(t, verifyRepoIdentity(&model.Repo{ForgeRemoteID: "7", FullName: "owner/repo"}, stored), "activated as project 1")
+
+	// 没有项目 ID 时比较克隆地址
+	assert.NoError(t, verifyRepoIdentity(&model.Repo{FullName: "owner/repo", Clone: "https://gitcode.com/Owner/Repo"}, stored))
+	assert.ErrorContains(t, verifyRepoIdentity(&model.Repo{FullName: "owner/repo", Clone: "https://gitcode.com/other/repo.git"}, stored), "activated from")
+}
+
+func TestActivateRegistersSecret(t *testing.T) {
+	var hook CreateHookRequest
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		if req.Method == http.MethodGet {
+			return http.StatusOK, `[]`
+		}
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&hook))
+		return http.StatusCreated, `{"id":1}`
+	})
+
+	repo := &model.Repo{Owner: "owner", Name: "repo", Hash: "hash"}
+	assert.NoError(t, c.Activate(t.Context(), &model.User{AccessToken: "token"}, repo, "https://ci.example.com/api/hook"))
+	assert.Equal(t, webhookSecret(repo), hook.Password)
+	assert.Equal(t, defaultHookEvents, hook.Events)
+}
+
+func TestActivateUpdatesExistingHook(t *testing.T) {
+	link := "https://ci.example.com/api/hook?access_token=abc"
+	var hook CreateHookRequest
+	var method, path string
+	c := newStubGitCode(t, func(req *http.Request) (int, string) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Query().Get("page") != "1":
+			return http.StatusOK, `[]`
+		case req.Method == http.MethodGet:
+			return http.StatusOK, `[{"id":3,"url":"https://other.example.com/hook"},{"id":7,"url":"` + link + `","events":["push"]}]`
+		}
+		method, path = req.Method, req.URL.Path
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&hook))
+		return http.StatusOK, `{"id":7}`
+	})
+	c.hookEvents = []string{"push", "note"}
+
+	repo := &model.Repo{Owner: "owner", Name: "repo", Hash: "hash"}
+	assert.NoError(t, c.Activate(t.Context(), &model.User{AccessToken: "token"}, repo, link))
+	assert.Equal(t, http.MethodPatch, method)
+	assert.Equal(t, "/api/v5/repos/owner/repo/hooks/7", path)
+	assert.Equal(t, []string{"push", "note"}, hook.Events)
+	assert.Equal(t, webhookSecret(repo), hook.Password)
+}
+
+func TestParseHookEvents(t *testing.T) {
+	events, err := parseHookEvents(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultHookEvents, events)
+
+	events, err = parseHookEvents([]string{" Push ", "note", "push", ""})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"push", "note"}, events)
+
+	_, err = parseHookEvents([]string{"wiki"})
+	assert.Error(t, err)
+}
+
+func TestIsWoodpeckerHook(t *testing.T) {
+	link := "https://ci.example.com/api/hook?access_token=abc"
+	assert.True(t, isWoodpeckerHook(link, link))
+	assert.True(t, isWoodpeckerHook("https://old-ci.example.com/api/hook?access_token=old", link))
+	assert.True(t, isWoodpeckerHook("http://10.0.0.1:8000/api/hook/", link))
+	assert.False(t, isWoodpeckerHook("https://ci.example.com/other/hook", link))
+	assert.False(t, isWoodpeckerHook("://invalid", link))
+}
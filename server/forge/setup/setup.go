@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -111,11 +112,79 @@ func setupForgejo(forge *model.Forge) (forge.Forge, error) {
 }
 
 func setupGitCode(forge *model.Forge) (forge.Forge, error) {
+	activationCheck, _ := forge.AdditionalOptions["activation-check"].(bool)
+	forkConfigFromTarget, _ := forge.AdditionalOptions["fork-config-from-target"].(bool)
+	apiURL, _ := forge.AdditionalOptions["api-url"].(string)
+	authMode, _ := forge.AdditionalOptions["auth-mode"].(string)
+	gitUsername, _ := forge.AdditionalOptions["git-username"].(string)
+	gitToken, _ := forge.AdditionalOptions["git-token"].(string)
+	branchHeadMachineAccount, _ := forge.AdditionalOptions["branch-head-machine-account"].(bool)
+	statusContext, _ := forge.AdditionalOptions["status-context"].(string)
+	debugTrace, _ := forge.AdditionalOptions["debug-trace"].(bool)
+	systemHookSecret, _ := forge.AdditionalOptions["system-hook-secret"].(string)
 	opts := gitcode.Opts{
-		OAuthClientID:     forge.OAuthClientID,
-		OAuthClientSecret: forge.OAuthClientSecret,
+		URL:                      forge.URL,
+		APIURL:                   apiURL,
+		SkipVerify:               forge.SkipVerify,
+		OAuthClientID:            forge.OAuthClientID,
+		OAuthClientSecret:        forge.OAuthClientSecret,
+		DomainAliases:            stringSliceOption(forge.AdditionalOptions["domain-aliases"]),
+		FailureIssueThreshold:    intOption(forge.AdditionalOptions["failure-issue-threshold"]),
+		Proxies:                  stringSliceOption(forge.AdditionalOptions["proxies"]),
+		ActivationCheck:          activationCheck,
+		MaxChangedFiles:          intOption(forge.AdditionalOptions["max-changed-files"]),
+		ForkConfigFromTarget:     forkConfigFromTarget,
+		RepoAffiliation:          stringSliceOption(forge.AdditionalOptions["repo-affiliation"]),
+		LogSampleRate:            intOption(forge.AdditionalOptions["log-sample-rate"]),
+		PageSize:                 intOption(forge.AdditionalOptions["page-size"]),
+		ConfigCacheSize:          intOption(forge.AdditionalOptions["config-cache-size"]),
+		ResponseCacheSize:        intOption(forge.AdditionalOptions["response-cache-size"]),
+		AuthMode:                 authMode,
+		GitUsername:              gitUsername,
+		GitToken:                 gitToken,
+		RepoCredentials:          stringSliceOption(forge.AdditionalOptions["repo-credentials"]),
+		RefreshMargin:            durationOption(forge.AdditionalOptions["refresh-margin"]),
+		HookEvents:               stringSliceOption(forge.AdditionalOptions["hook-events"]),
+		ReleaseActions:           stringSliceOption(forge.AdditionalOptions["release-actions"]),
+		BranchHeadMachineAccount: branchHeadMachineAccount,
+		StatusContext:            statusContext,
+		MaxRetries:               intOption(forge.AdditionalOptions["max-retries"]),
+		RetryBackoff:             durationOption(forge.AdditionalOptions["retry-backoff"]),
+		Timeouts:                 stringSliceOption(forge.AdditionalOptions["timeouts"]),
+		DebugTrace:               debugTrace,
+		MaxResponseSize:          intOption(forge.AdditionalOptions["max-response-size"]),
+		SystemHookSecret:         systemHookSecret,
 	}
 	log.Debug().
+		Str("url", opts.URL).
+		Str("api-url", opts.APIURL).
+		Bool("skip-verify", opts.SkipVerify).
+		Strs("domain-aliases", opts.DomainAliases).
+		Int("failure-issue-threshold", opts.FailureIssueThreshold).
+		Strs("proxies", opts.Proxies).
+		Bool("activation-check", opts.ActivationCheck).
+		Int("max-changed-files", opts.MaxChangedFiles).
+		Bool("fork-config-from-target", opts.ForkConfigFromTarget).
+		Strs("repo-affiliation", opts.RepoAffiliation).
+		Int("log-sample-rate", opts.LogSampleRate).
+		Int("page-size", opts.PageSize).
+		Int("config-cache-size", opts.ConfigCacheSize).
+		Int("response-cache-size", opts.ResponseCacheSize).
+		Str("auth-mode", opts.AuthMode).
+		Str("git-username", opts.GitUsername).
+		Bool("git-token-set", opts.GitToken != "").
+		Int("repo-credentials", len(opts.RepoCredentials)).
+		Dur("refresh-margin", opts.RefreshMargin).
+		Strs("hook-events", opts.HookEvents).
+		Strs("release-actions", opts.ReleaseActions).
+		Bool("branch-head-machine-account", opts.BranchHeadMachineAccount).
+		Str("status-context", opts.StatusContext).
+		Int("max-retries", opts.MaxRetries).
+		Dur("retry-backoff", opts.RetryBackoff).
+		Strs("timeouts", opts.Timeouts).
+		Bool("debug-trace", opts.DebugTrace).
+		Int("max-response-size", opts.MaxResponseSize).
+		Bool("system-hook-secret-set", opts.SystemHookSecret != "").
 		Bool("oauth-client-id-set", opts.OAuthClientID != "").
 		Bool("oauth-secret-id-set", opts.OAuthClientSecret != "").
 		Str("type", string(forge.Type)).
@@ -219,3 +288,48 @@ func setupAddon(forge *model.Forge) (forge.Forge, error) {
 	log.Debug().Str("executable", executable).Msg("setting up forge")
 	return addon.Load(executable)
 }
+
+// stringSliceOption reads a string list from additional options, which is
+// []any once it went through the JSON round trip of the store.
+func stringSliceOption(value any) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// intOption reads a number from additional options, which is float64 once
+// it went through the JSON round trip of the store.
+func intOption(value any) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// durationOption reads a duration from additional options, which is a number
+// of nanoseconds once it went through the JSON round trip of the store.
+func durationOption(value any) time.Duration {
+	switch v := value.(type) {
+	case time.Duration:
+		return v
+	case string:
+		d, _ := time.ParseDuration(v)
+		return d
+	}
+	return time.Duration(intOption(value))
+}
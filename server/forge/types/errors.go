@@ -23,6 +23,18 @@ import (
 
 var ErrNotImplemented = errors.New("not implemented")
 
+// ErrRepoNotFound is returned by forges if a repository does not exist.
+var ErrRepoNotFound = errors.New("repository not found")
+
+// ErrRepoNoPermission is returned by forges if a repository exists but the user is not allowed to access it.
+var ErrRepoNoPermission = errors.New("no permission to access repository, ask an admin to grant access")
+
+// HTTPStatusError is implemented by errors of forge API requests that carry the status code of the response.
+type HTTPStatusError interface {
+	error
+	HTTPStatus() int
+}
+
 type ErrIgnoreEvent struct {
 	Event  string
 	Reason string
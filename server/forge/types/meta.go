@@ -20,6 +20,8 @@ import "sort"
 type FileMeta struct {
 	Name string
 	Data []byte
+	// SHA is the blob hash reported by the forge, if available.
+	SHA string
 }
 
 type fileMetaList []*FileMeta
@@ -36,6 +36,7 @@ import (
 	"go.woodpecker-ci.org/woodpecker/v3/server/pipeline"
 	"go.woodpecker-ci.org/woodpecker/v3/server/pubsub"
 	"go.woodpecker-ci.org/woodpecker/v3/server/queue"
+	"go.woodpecker-ci.org/woodpecker/v3/server/statusqueue"
 	"go.woodpecker-ci.org/woodpecker/v3/server/store"
 )
 
@@ -357,6 +358,8 @@ func (s *RPC) Done(c context.Context, strWorkflowID string, state rpc.WorkflowSt
 	if !model.IsThereRunningStage(currentPipeline.Workflows) {
 		if currentPipeline, err = pipeline.UpdateStatusToDone(s.store, *currentPipeline, model.PipelineStatus(currentPipeline.Workflows), workflow.Finished); err != nil {
 			logger.Error().Err(err).Msgf("pipeline.UpdateStatusToDone: cannot update workflows final state")
+		} else if currentPipeline.Status == model.StatusFailure {
+			s.notifyForgeFailure(c, repo, currentPipeline)
 		}
 	}
 
@@ -552,6 +555,34 @@ func (s *RPC) completeChildrenIfParentCompleted(completedWorkflow *model.Workflo
 	}
 }
 
+// notifyForgeFailure lets forges that support it notify users about a failed pipeline.
+func (s *RPC) notifyForgeFailure(ctx context.Context, repo *model.Repo, pipeline *model.Pipeline) {
+	_forge, err := server.Config.Services.Manager.ForgeFromRepo(repo)
+	if err != nil {
+		log.Error().Err(err).Msgf("can not get forge for repo '%s'", repo.FullName)
+		return
+	}
+
+	notifier, ok := _forge.(forge.FailureNotifier)
+	if !ok {
+		return
+	}
+
+	user, err := s.store.GetUser(repo.UserID)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot get user with id '%d'", repo.UserID)
+		return
+	}
+
+	forge.Refresh(ctx, _forge, s.store, user)
+
+	// forges may look at previous pipelines, e.g. to detect repeated failures
+	ctx = store.InjectToContext(ctx, s.store)
+	if err := notifier.NotifyFailure(ctx, user, repo, pipeline); err != nil {
+		log.Error().Err(err).Msgf("error notifying about failed pipeline %s/%d", repo.FullName, pipeline.Number)
+	}
+}
+
 func (s *RPC) updateForgeStatus(ctx context.Context, repo *model.Repo, pipeline *model.Pipeline, workflow *model.Workflow) {
 	user, err := s.store.GetUser(repo.UserID)
 	if err != nil {
@@ -570,7 +601,10 @@ func (s *RPC) updateForgeStatus(ctx context.Context, repo *model.Repo, pipeline
 	// only do status updates for parent steps
 	if workflow != nil {
 		err = _forge.Status(ctx, user, repo, pipeline, workflow)
-		if err != nil {
+		if err != nil && statusqueue.Retryable(err) {
+			log.Error().Err(err).Msgf("error setting commit status for %s/%d, queued for retry", repo.FullName, pipeline.Number)
+			statusqueue.Enqueue(s.store, repo, pipeline, workflow)
+		} else if err != nil {
 			log.Error().Err(err).Msgf("error setting commit status for %s/%d", repo.FullName, pipeline.Number)
 		}
 	}
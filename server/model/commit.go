@@ -3,4 +3,9 @@ package model
 type Commit struct {
 	SHA      string
 	ForgeURL string
+	// optional commit details, only filled by forges that can provide them
+	Message   string
+	Author    string
+	Email     string
+	Timestamp int64
 }
@@ -67,9 +67,12 @@ type Repo struct {
 	IsActive                     bool                 `json:"active"                          xorm:"active"`
 	AllowPull                    bool                 `json:"allow_pr"                        xorm:"allow_pr"`
 	AllowDeploy                  bool                 `json:"allow_deploy"                    xorm:"allow_deploy"`
+	CommentOnFailure             bool                 `json:"comment_on_failure"              xorm:"comment_on_failure"`
+	SkipDraftPullRequests        bool                 `json:"skip_draft_pull_requests"        xorm:"skip_draft_pull_requests"`
 	Config                       string               `json:"config_file"                     xorm:"varchar(500) 'config_path'"`
 	Hash                         string               `json:"-"                               xorm:"varchar(500) 'hash'"`
 	Perm                         *Perm                `json:"-"                               xorm:"-"`
+	IsFork                       bool                 `json:"fork,omitempty"                  xorm:"-"`
 	CancelPreviousPipelineEvents []WebhookEvent       `json:"cancel_previous_pipeline_events" xorm:"json 'cancel_previous_pipeline_events'"`
 	NetrcTrustedPlugins          []string             `json:"netrc_trusted"                   xorm:"json 'netrc_trusted'"`
 	ConfigExtensionEndpoint      string               `json:"config_extension_endpoint"       xorm:"varchar(500) 'config_extension_endpoint'"`
@@ -125,6 +128,7 @@ func (r *Repo) Update(from *Repo) {
 		}
 	}
 	r.IsSCMPrivate = from.IsSCMPrivate
+	r.IsFork = from.IsFork
 }
 
 // RepoPatch represents a repository patch object.
@@ -136,6 +140,8 @@ type RepoPatch struct {
 	Visibility                   *string                    `json:"visibility,omitempty"`
 	AllowPull                    *bool                      `json:"allow_pr,omitempty"`
 	AllowDeploy                  *bool                      `json:"allow_deploy,omitempty"`
+	CommentOnFailure             *bool                      `json:"comment_on_failure,omitempty"`
+	SkipDraftPullRequests        *bool                      `json:"skip_draft_pull_requests,omitempty"`
 	CancelPreviousPipelineEvents *[]WebhookEvent            `json:"cancel_previous_pipeline_events"`
 	NetrcTrusted                 *[]string                  `json:"netrc_trusted"`
 	Trusted                      *TrustedConfigurationPatch `json:"trusted"`
@@ -45,13 +45,14 @@ type SecretStore interface {
 
 // Secret represents a secret variable, such as a password or token.
 type Secret struct {
-	ID     int64          `json:"id"              xorm:"pk autoincr 'id'"`
-	OrgID  int64          `json:"org_id"          xorm:"NOT NULL DEFAULT 0 UNIQUE(s) INDEX 'org_id'"`
-	RepoID int64          `json:"repo_id"         xorm:"NOT NULL DEFAULT 0 UNIQUE(s) INDEX 'repo_id'"`
-	Name   string         `json:"name"            xorm:"NOT NULL UNIQUE(s) INDEX 'name'"`
-	Value  string         `json:"value,omitempty" xorm:"TEXT 'value'"`
-	Images []string       `json:"images"          xorm:"json 'images'"`
-	Events []WebhookEvent `json:"events"          xorm:"json 'events'"`
+	ID        int64          `json:"id"              xorm:"pk autoincr 'id'"`
+	OrgID     int64          `json:"org_id"          xorm:"NOT NULL DEFAULT 0 UNIQUE(s) INDEX 'org_id'"`
+	RepoID    int64          `json:"repo_id"         xorm:"NOT NULL DEFAULT 0 UNIQUE(s) INDEX 'repo_id'"`
+	Name      string         `json:"name"            xorm:"NOT NULL UNIQUE(s) INDEX 'name'"`
+	Value     string         `json:"value,omitempty" xorm:"TEXT 'value'"`
+	Images    []string       `json:"images"          xorm:"json 'images'"`
+	Events    []WebhookEvent `json:"events"          xorm:"json 'events'"`
+	Protected bool           `json:"protected"       xorm:"protected"` // only expose to pipelines of protected branches and tags
 } //	@name	Secret
 
 // TableName return database table name for xorm.
@@ -123,12 +124,13 @@ func (s *Secret) Validate() error {
 // Copy makes a copy of the secret without the value.
 func (s *Secret) Copy() *Secret {
 	return &Secret{
-		ID:     s.ID,
-		OrgID:  s.OrgID,
-		RepoID: s.RepoID,
-		Name:   s.Name,
-		Images: s.Images,
-		Events: sortEvents(s.Events),
+		ID:        s.ID,
+		OrgID:     s.OrgID,
+		RepoID:    s.RepoID,
+		Name:      s.Name,
+		Images:    s.Images,
+		Events:    sortEvents(s.Events),
+		Protected: s.Protected,
 	}
 }
 
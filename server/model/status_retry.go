@@ -0,0 +1,32 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+// StatusRetry is a commit status post that failed and is waiting to be replayed.
+// There is at most one entry per workflow, the replay always sends the current workflow state.
+type StatusRetry struct {
+	ID          int64 `xorm:"pk autoincr 'id'"`
+	RepoID      int64 `xorm:"repo_id INDEX"`
+	PipelineID  int64 `xorm:"pipeline_id"`
+	WorkflowID  int64 `xorm:"UNIQUE 'workflow_id'"`
+	Attempts    int   `xorm:"attempts"`
+	NextAttempt int64 `xorm:"next_attempt INDEX"`
+	Created     int64 `xorm:"created NOT NULL DEFAULT 0"`
+}
+
+// TableName returns the database table name for xorm.
+func (StatusRetry) TableName() string {
+	return "status_retries"
+}
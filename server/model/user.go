@@ -60,6 +60,9 @@ type User struct {
 	// the avatar url for this user.
 	Avatar string `json:"avatar_url" xorm:" varchar(500) 'avatar'"`
 
+	// DisplayName is the full name or nickname shown by the forge, if any.
+	DisplayName string `json:"display_name,omitempty" xorm:"varchar(250) 'display_name'"`
+
 	// Admin indicates the user is a system administrator.
 	//
 	// NOTE: If the username is part of the WOODPECKER_ADMIN
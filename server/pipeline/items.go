@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"slices"
 
 	"github.com/rs/zerolog/log"
 
@@ -31,7 +32,7 @@ import (
 	"go.woodpecker-ci.org/woodpecker/v3/server/store"
 )
 
-func parsePipeline(forge forge.Forge, store store.Store, currentPipeline *model.Pipeline, user *model.User, repo *model.Repo, yamls []*forge_types.FileMeta, envs map[string]string) ([]*stepbuilder.Item, error) {
+func parsePipeline(ctx context.Context, forge forge.Forge, store store.Store, currentPipeline *model.Pipeline, user *model.User, repo *model.Repo, yamls []*forge_types.FileMeta, envs map[string]string) ([]*stepbuilder.Item, error) {
 	netrc, err := forge.Netrc(user, repo)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to generate netrc file")
@@ -48,6 +49,7 @@ func parsePipeline(forge forge.Forge, store store.Store, currentPipeline *model.
 	if err != nil {
 		log.Error().Err(err).Msgf("error getting secrets for %s#%d", repo.FullName, currentPipeline.Number)
 	}
+	secs = filterProtectedSecrets(ctx, forge, user, repo, currentPipeline, secs)
 
 	registryService := server.Config.Services.Manager.RegistryServiceFromRepo(repo)
 	regs, err := registryService.RegistryListPipeline(repo, currentPipeline)
@@ -92,11 +94,32 @@ func parsePipeline(forge forge.Forge, store store.Store, currentPipeline *model.
 	return b.Build()
 }
 
+// filterProtectedSecrets drops secrets marked as protected unless the pipeline
+// runs for a branch or tag the forge reports as protected. Pull requests never
+// get protected secrets, as their code is not reviewed yet.
+func filterProtectedSecrets(ctx context.Context, _forge forge.Forge, user *model.User, repo *model.Repo, currentPipeline *model.Pipeline, secs []*model.Secret) []*model.Secret {
+	isProtected := func(secret *model.Secret) bool { return secret.Protected }
+	if !slices.ContainsFunc(secs, isProtected) {
+		return secs
+	}
+
+	if checker, ok := _forge.(forge.RefProtectionChecker); ok && !currentPipeline.IsPullRequest() {
+		protected, err := checker.IsProtectedRef(ctx, user, repo, currentPipeline)
+		if err != nil {
+			log.Error().Err(err).Msgf("could not check if %s of %s is protected, withholding protected secrets", currentPipeline.Ref, repo.FullName)
+		} else if protected {
+			return secs
+		}
+	}
+
+	return slices.DeleteFunc(slices.Clone(secs), isProtected)
+}
+
 func createPipelineItems(c context.Context, forge forge.Forge, store store.Store,
 	currentPipeline *model.Pipeline, user *model.User, repo *model.Repo,
 	yamls []*forge_types.FileMeta, envs map[string]string,
 ) (*model.Pipeline, []*stepbuilder.Item, error) {
-	pipelineItems, err := parsePipeline(forge, store, currentPipeline, user, repo, yamls, envs)
+	pipelineItems, err := parsePipeline(c, forge, store, currentPipeline, user, repo, yamls, envs)
 	if pipeline_errors.HasBlockingErrors(err) {
 		currentPipeline, uErr := UpdateToStatusError(store, *currentPipeline, err)
 		if uErr != nil {
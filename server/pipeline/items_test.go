@@ -1,9 +1,14 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"go.woodpecker-ci.org/woodpecker/v3/pipeline/backend/types"
+	forge_mocks "go.woodpecker-ci.org/woodpecker/v3/server/forge/mocks"
 	"go.woodpecker-ci.org/woodpecker/v3/server/model"
 	sharedPipeline "go.woodpecker-ci.org/woodpecker/v3/server/pipeline/stepbuilder"
 )
@@ -50,3 +55,45 @@ func TestSetPipelineStepsOnPipeline(t *testing.T) {
 		t.Fatal("Should set step PPID")
 	}
 }
+
+type protectionForge struct {
+	*forge_mocks.MockForge
+	protected bool
+	err       error
+}
+
+func (f *protectionForge) IsProtectedRef(context.Context, *model.User, *model.Repo, *model.Pipeline) (bool, error) {
+	return f.protected, f.err
+}
+
+func TestFilterProtectedSecrets(t *testing.T) {
+	t.Parallel()
+
+	plain := &model.Secret{Name: "plain"}
+	protected := &model.Secret{Name: "protected", Protected: true}
+	secs := []*model.Secret{plain, protected}
+	push := &model.Pipeline{Event: model.EventPush, Ref: "refs/heads/main"}
+	pull := &model.Pipeline{Event: model.EventPull, Ref: "refs/pull/1/head"}
+
+	testCases := []struct {
+		name     string
+		forge    *protectionForge
+		pipeline *model.Pipeline
+		expected []*model.Secret
+	}{
+		{name: "protected ref", forge: &protectionForge{protected: true}, pipeline: push, expected: secs},
+		{name: "unprotected ref", forge: &protectionForge{}, pipeline: push, expected: []*model.Secret{plain}},
+		{name: "pull request", forge: &protectionForge{protected: true}, pipeline: pull, expected: []*model.Secret{plain}},
+		{name: "check failed", forge: &protectionForge{protected: true, err: errors.New("boom")}, pipeline: push, expected: []*model.Secret{plain}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, filterProtectedSecrets(t.Context(), tc.forge, nil, &model.Repo{}, tc.pipeline, secs))
+		})
+	}
+
+	// forges without protection data never expose protected secrets
+	assert.Equal(t, []*model.Secret{plain}, filterProtectedSecrets(t.Context(), forge_mocks.NewMockForge(t), nil, &model.Repo{}, push, secs))
+	assert.Len(t, secs, 2)
+}
@@ -137,9 +137,25 @@ func metadataPipelineFromModelPipeline(pipeline *model.Pipeline, includeParent b
 			PullRequestLabels:    pipeline.PullRequestLabels,
 			PullRequestMilestone: pipeline.PullRequestMilestone,
 			IsPrerelease:         pipeline.IsPrerelease,
+			ReleaseAssets:        metadataReleaseAssets(pipeline.ReleaseAssets),
 		},
 		Cron:   cron,
 		Author: pipeline.Author,
 		Avatar: pipeline.Avatar,
 	}
 }
+
+func metadataReleaseAssets(assets []*model.ReleaseAsset) []metadata.ReleaseAsset {
+	if len(assets) == 0 {
+		return nil
+	}
+	result := make([]metadata.ReleaseAsset, 0, len(assets))
+	for _, asset := range assets {
+		result = append(result, metadata.ReleaseAsset{
+			Name: asset.Name,
+			URL:  asset.URL,
+			Size: asset.Size,
+		})
+	}
+	return result
+}
@@ -87,6 +87,38 @@ func TestMetadataFromStruct(t *testing.T) {
 				"CI_SYSTEM_NAME": "woodpecker", "CI_SYSTEM_URL": "https://example.com", "CI_WORKFLOW_NAME": "hello", "CI_WORKFLOW_NUMBER": "0",
 			},
 		},
+		{
+			name:     "Test with release",
+			repo:     &model.Repo{ID: 7, FullName: "testUser/testRepo"},
+			pipeline: &model.Pipeline{Number: 3, Event: model.EventRelease, Ref: "refs/tags/v1.0.0"},
+			sysURL:   "https://example.com",
+			expectedMetadata: metadata.Metadata{
+				Sys:  metadata.System{Name: "woodpecker", Host: "example.com", URL: "https://example.com"},
+				Repo: metadata.Repo{ID: 7, Owner: "testUser", Name: "testRepo"},
+				Curr: metadata.Pipeline{
+					Number: 3,
+					Event:  "release",
+					Commit: metadata.Commit{Ref: "refs/tags/v1.0.0"},
+				},
+			},
+			expectedEnviron: map[string]string{
+				"CI":                "woodpecker",
+				"CI_COMMIT_REF":     "refs/tags/v1.0.0",
+				"CI_COMMIT_TAG":     "v1.0.0",
+				"CI_PIPELINE_EVENT": "release", "CI_COMMIT_PRERELEASE": "false",
+				"CI_COMMIT_RELEASE_UPLOAD_URL": "https://example.com/api/repos/7/pipelines/3/release-assets",
+				"CI_PIPELINE_CREATED":          "0", "CI_PIPELINE_FILES": "[]", "CI_PIPELINE_NUMBER": "3",
+				"CI_PIPELINE_PARENT": "0", "CI_PIPELINE_STARTED": "0", "CI_PIPELINE_URL": "https://example.com/repos/7/pipeline/3",
+				"CI_PREV_PIPELINE_CREATED":  "0",
+				"CI_PREV_PIPELINE_FINISHED": "0", "CI_PREV_PIPELINE_NUMBER": "0", "CI_PREV_PIPELINE_PARENT": "0",
+				"CI_PREV_PIPELINE_STARTED": "0", "CI_PREV_PIPELINE_URL": "https://example.com/repos/7/pipeline/0",
+				"CI_REPO": "testUser/testRepo", "CI_REPO_NAME": "testRepo", "CI_REPO_OWNER": "testUser",
+				"CI_REPO_PRIVATE": "false", "CI_REPO_TRUSTED": "false", "CI_REPO_TRUSTED_NETWORK": "false", "CI_REPO_TRUSTED_SECURITY": "false", "CI_REPO_TRUSTED_VOLUMES": "false",
+				"CI_STEP_NUMBER": "0", "CI_STEP_URL": "https://example.com/repos/7/pipeline/3", "CI_SYSTEM_HOST": "example.com",
+				"CI_SYSTEM_NAME": "woodpecker", "CI_SYSTEM_URL": "https://example.com",
+				"CI_WORKFLOW_NUMBER": "0",
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
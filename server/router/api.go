@@ -119,6 +119,7 @@ func apiRoutes(e *gin.RouterGroup) {
 					repo.POST("/pipelines/:number/cancel", session.MustPush, api.CancelPipeline)
 					repo.POST("/pipelines/:number/approve", session.MustPush, api.PostApproval)
 					repo.POST("/pipelines/:number/decline", session.MustPush, api.PostDecline)
+					repo.POST("/pipelines/:number/release-assets", session.MustPush, api.PostPipelineReleaseAsset)
 
 					repo.GET("/logs/:number/:stepId", api.GetStepLogs)
 					repo.DELETE("/logs/:number/:stepId", session.MustPush, api.DeleteStepLogs)
@@ -151,6 +151,33 @@ func setupForgeService(c *cli.Command, _store store.Store) error {
 		}
 	case c.Bool("gitcode"):
 		_forge.Type = model.ForgeTypeGitCode
+		_forge.AdditionalOptions["api-url"] = c.String("gitcode-api-url")
+		_forge.AdditionalOptions["domain-aliases"] = c.StringSlice("gitcode-domain-aliases")
+		_forge.AdditionalOptions["failure-issue-threshold"] = c.Int("gitcode-failure-issue-threshold")
+		_forge.AdditionalOptions["proxies"] = c.StringSlice("gitcode-proxies")
+		_forge.AdditionalOptions["activation-check"] = c.Bool("gitcode-activation-check")
+		_forge.AdditionalOptions["max-changed-files"] = c.Int("gitcode-max-changed-files")
+		_forge.AdditionalOptions["fork-config-from-target"] = c.Bool("gitcode-fork-config-from-target")
+		_forge.AdditionalOptions["repo-affiliation"] = c.StringSlice("gitcode-repo-affiliation")
+		_forge.AdditionalOptions["log-sample-rate"] = c.Int("gitcode-log-sample-rate")
+		_forge.AdditionalOptions["page-size"] = c.Int("gitcode-page-size")
+		_forge.AdditionalOptions["config-cache-size"] = c.Int("gitcode-config-cache-size")
+		_forge.AdditionalOptions["response-cache-size"] = c.Int("gitcode-response-cache-size")
+		_forge.AdditionalOptions["auth-mode"] = c.String("gitcode-auth-mode")
+		_forge.AdditionalOptions["git-username"] = c.String("gitcode-git-username")
+		_forge.AdditionalOptions["git-token"] = c.String("gitcode-git-token")
+		_forge.AdditionalOptions["repo-credentials"] = c.StringSlice("gitcode-repo-credentials")
+		_forge.AdditionalOptions["refresh-margin"] = c.Duration("gitcode-refresh-margin")
+		_forge.AdditionalOptions["hook-events"] = c.StringSlice("gitcode-hook-events")
+		_forge.AdditionalOptions["release-actions"] = c.StringSlice("gitcode-release-actions")
+		_forge.AdditionalOptions["branch-head-machine-account"] = c.Bool("gitcode-branch-head-machine-account")
+		_forge.AdditionalOptions["status-context"] = c.String("gitcode-status-context")
+		_forge.AdditionalOptions["max-retries"] = c.Int("gitcode-max-retries")
+		_forge.AdditionalOptions["retry-backoff"] = c.Duration("gitcode-retry-backoff")
+		_forge.AdditionalOptions["timeouts"] = c.StringSlice("gitcode-timeouts")
+		_forge.AdditionalOptions["debug-trace"] = c.Bool("gitcode-debug-trace")
+		_forge.AdditionalOptions["max-response-size"] = c.Int("gitcode-max-response-size")
+		_forge.AdditionalOptions["system-hook-secret"] = c.String("gitcode-system-hook-secret")
 		if _forge.URL == "" {
 			_forge.URL = "https://gitcode.com"
 		}
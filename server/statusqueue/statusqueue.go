@@ -0,0 +1,190 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statusqueue persists commit status posts that failed transiently and
+// replays them with backoff, so the status shown by the forge eventually
+// matches the pipeline even after an outage.
+package statusqueue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server"
+	"go.woodpecker-ci.org/woodpecker/v3/server/forge"
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store/types"
+)
+
+const (
+	// Specifies the interval woodpecker checks for due status retries.
+	checkTime = 15 * time.Second
+
+	// Specifies the batch size of retries to retrieve per check from database.
+	checkItems = 25
+
+	// Delay of the first retry, doubled with every failed attempt up to maxBackoff.
+	minBackoff = 30 * time.Second
+	maxBackoff = 30 * time.Minute
+
+	// Retries older than this are dropped, the status is most likely irrelevant by then.
+	maxAge = 24 * time.Hour
+)
+
+// Retryable reports whether a failed status post is worth replaying. Only
+// network errors, server errors and rate limits are, rejected requests like
+// missing permissions or deleted repos would fail the same way again. Forges
+// report the status of failed API requests through forge_types.HTTPStatusError.
+func Retryable(err error) bool {
+	var statusErr forge_types.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		status := statusErr.HTTPStatus()
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Enqueue persists a failed status post of the given workflow for a later replay.
+func Enqueue(store store.Store, repo *model.Repo, pipeline *model.Pipeline, workflow *model.Workflow) {
+	now := time.Now()
+	err := store.StatusRetryCreate(&model.StatusRetry{
+		RepoID:      repo.ID,
+		PipelineID:  pipeline.ID,
+		WorkflowID:  workflow.ID,
+		NextAttempt: now.Add(minBackoff).Unix(),
+		Created:     now.Unix(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot queue commit status retry for %s/%d", repo.FullName, pipeline.Number)
+	}
+}
+
+// Run starts the status retry loop.
+func Run(ctx context.Context, store store.Store) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(checkTime):
+			now := time.Now()
+			log.Trace().Msg("status queue: fetch due retries")
+
+			retries, err := store.StatusRetryListDue(now.Unix(), checkItems)
+			if err != nil {
+				log.Error().Err(err).Int64("now", now.Unix()).Msg("obtain status retry list")
+				continue
+			}
+
+			for _, retry := range retries {
+				if err := replay(ctx, store, retry, now); err != nil {
+					log.Error().Err(err).Int64("workflowID", retry.WorkflowID).Msg("replay commit status failed")
+				}
+			}
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 0; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+func replay(ctx context.Context, store store.Store, retry *model.StatusRetry, now time.Time) error {
+	// every server instance polls the queue, the instance claiming the retry first replays it
+	gotLock, err := store.StatusRetryGetLock(retry, now.Add(backoff(retry.Attempts+1)).Unix())
+	if err != nil {
+		return err
+	}
+	if !gotLock {
+		return nil
+	}
+
+	if now.Sub(time.Unix(retry.Created, 0)) > maxAge {
+		log.Warn().Int64("workflowID", retry.WorkflowID).Int("attempts", retry.Attempts).Msg("giving up on commit status retry")
+		return store.StatusRetryDelete(retry.ID)
+	}
+
+	repo, pipeline, workflow, user, err := load(store, retry)
+	if errors.Is(err, types.RecordNotExist) {
+		// repo, pipeline or workflow got deleted in the meantime
+		return store.StatusRetryDelete(retry.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_forge, err := server.Config.Services.Manager.ForgeFromRepo(repo)
+	if err != nil {
+		return err
+	}
+
+	forge.Refresh(ctx, _forge, store, user)
+
+	if err := _forge.Status(ctx, user, repo, pipeline, workflow); err != nil {
+		if !Retryable(err) {
+			log.Warn().Err(err).Int64("workflowID", retry.WorkflowID).Msg("giving up on commit status retry rejected by the forge")
+			return store.StatusRetryDelete(retry.ID)
+		}
+		retry.Attempts++
+		retry.NextAttempt = now.Add(backoff(retry.Attempts)).Unix()
+		if updateErr := store.StatusRetryUpdate(retry); updateErr != nil {
+			return errors.Join(err, updateErr)
+		}
+		return err
+	}
+
+	log.Debug().Msgf("replayed commit status for %s/%d after %d attempts", repo.FullName, pipeline.Number, retry.Attempts+1)
+	return store.StatusRetryDelete(retry.ID)
+}
+
+func load(store store.Store, retry *model.StatusRetry) (*model.Repo, *model.Pipeline, *model.Workflow, *model.User, error) {
+	repo, err := store.GetRepo(retry.RepoID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	pipeline, err := store.GetPipeline(retry.PipelineID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	workflow, err := store.WorkflowLoad(retry.WorkflowID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	user, err := store.GetUser(repo.UserID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return repo, pipeline, workflow, user, nil
+}
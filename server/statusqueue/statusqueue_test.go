@@ -0,0 +1,146 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statusqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server"
+	forge_mocks "go.woodpecker-ci.org/woodpecker/v3/server/forge/mocks"
+	forge_types "go.woodpecker-ci.org/woodpecker/v3/server/forge/types"
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	manager_mocks "go.woodpecker-ci.org/woodpecker/v3/server/services/mocks"
+	store_mocks "go.woodpecker-ci.org/woodpecker/v3/server/store/mocks"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store/types"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoff(0))
+	assert.Equal(t, time.Minute, backoff(1))
+	assert.Equal(t, 8*time.Minute, backoff(4))
+	assert.Equal(t, 30*time.Minute, backoff(6))
+	assert.Equal(t, 30*time.Minute, backoff(100))
+}
+
+// statusError is a forge API error carrying the status code of the response.
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("API error %d", int(e)) }
+func (e statusError) HTTPStatus() int { return int(e) }
+
+func TestRetryable(t *testing.T) {
+	connRefused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	assert.True(t, Retryable(connRefused))
+	assert.True(t, Retryable(fmt.Errorf("post status: %w", context.DeadlineExceeded)))
+	assert.True(t, Retryable(statusError(http.StatusBadGateway)))
+	assert.True(t, Retryable(fmt.Errorf("post status: %w", statusError(http.StatusTooManyRequests))))
+
+	assert.False(t, Retryable(statusError(http.StatusUnauthorized)))
+	assert.False(t, Retryable(statusError(http.StatusForbidden)))
+	assert.False(t, Retryable(statusError(http.StatusNotFound)))
+	assert.False(t, Retryable(forge_types.ErrNotImplemented))
+	assert.False(t, Retryable(context.Canceled))
+	assert.False(t, Retryable(errors.New("repository was deleted")))
+}
+
+func TestReplay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	user := &model.User{ID: 1, Login: "user1"}
+	repo := &model.Repo{ID: 1, UserID: 1, FullName: "owner1/repo1"}
+	pipeline := &model.Pipeline{ID: 2, Number: 3}
+	workflow := &model.Workflow{ID: 4, PipelineID: 2, State: model.StatusSuccess}
+
+	setup := func(t *testing.T) (*store_mocks.MockStore, *forge_mocks.MockForge) {
+		_manager := manager_mocks.NewMockManager(t)
+		_forge := forge_mocks.NewMockForge(t)
+		store := store_mocks.NewMockStore(t)
+		store.On("StatusRetryGetLock", mock.Anything, mock.Anything).Return(true, nil)
+		store.On("GetRepo", repo.ID).Return(repo, nil)
+		store.On("GetPipeline", pipeline.ID).Return(pipeline, nil)
+		store.On("WorkflowLoad", workflow.ID).Return(workflow, nil)
+		store.On("GetUser", user.ID).Return(user, nil)
+		_manager.On("ForgeFromRepo", repo).Return(_forge, nil)
+		server.Config.Services.Manager = _manager
+		return store, _forge
+	}
+
+	t.Run("success", func(t *testing.T) {
+		store, _forge := setup(t)
+		_forge.On("Status", mock.Anything, user, repo, pipeline, workflow).Return(nil)
+		store.On("StatusRetryDelete", int64(5)).Return(nil)
+
+		retry := &model.StatusRetry{ID: 5, RepoID: 1, PipelineID: 2, WorkflowID: 4, Created: now.Unix()}
+		assert.NoError(t, replay(t.Context(), store, retry, now))
+	})
+
+	t.Run("still unreachable", func(t *testing.T) {
+		store, _forge := setup(t)
+		_forge.On("Status", mock.Anything, user, repo, pipeline, workflow).Return(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+		store.On("StatusRetryUpdate", mock.Anything).Return(nil)
+
+		retry := &model.StatusRetry{ID: 5, RepoID: 1, PipelineID: 2, WorkflowID: 4, Attempts: 2, Created: now.Unix()}
+		assert.Error(t, replay(t.Context(), store, retry, now))
+		assert.Equal(t, 3, retry.Attempts)
+		assert.EqualValues(t, now.Add(4*time.Minute).Unix(), retry.NextAttempt)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		store, _forge := setup(t)
+		_forge.On("Status", mock.Anything, user, repo, pipeline, workflow).Return(statusError(http.StatusForbidden))
+		store.On("StatusRetryDelete", int64(5)).Return(nil)
+
+		retry := &model.StatusRetry{ID: 5, RepoID: 1, PipelineID: 2, WorkflowID: 4, Created: now.Unix()}
+		assert.NoError(t, replay(t.Context(), store, retry, now))
+	})
+
+	t.Run("claimed by another server", func(t *testing.T) {
+		store := store_mocks.NewMockStore(t)
+		store.On("StatusRetryGetLock", mock.Anything, now.Add(time.Minute).Unix()).Return(false, nil)
+
+		retry := &model.StatusRetry{ID: 5, RepoID: 1, PipelineID: 2, WorkflowID: 4, Created: now.Unix()}
+		assert.NoError(t, replay(t.Context(), store, retry, now))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		store := store_mocks.NewMockStore(t)
+		store.On("StatusRetryGetLock", mock.Anything, mock.Anything).Return(true, nil)
+		store.On("StatusRetryDelete", int64(5)).Return(nil)
+
+		retry := &model.StatusRetry{ID: 5, WorkflowID: 4, Created: now.Add(-25 * time.Hour).Unix()}
+		assert.NoError(t, replay(t.Context(), store, retry, now))
+	})
+
+	t.Run("deleted workflow", func(t *testing.T) {
+		store := store_mocks.NewMockStore(t)
+		store.On("StatusRetryGetLock", mock.Anything, mock.Anything).Return(true, nil)
+		store.On("GetRepo", repo.ID).Return(repo, nil)
+		store.On("GetPipeline", pipeline.ID).Return(nil, types.RecordNotExist)
+		store.On("StatusRetryDelete", int64(5)).Return(nil)
+
+		retry := &model.StatusRetry{ID: 5, RepoID: 1, PipelineID: 2, WorkflowID: 4, Created: now.Unix()}
+		assert.NoError(t, replay(t.Context(), store, retry, now))
+	})
+}
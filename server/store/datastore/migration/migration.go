@@ -76,6 +76,7 @@ var allBeans = []any{
 	new(model.Forge),
 	new(model.Workflow),
 	new(model.Org),
+	new(model.StatusRetry),
 }
 
 // TODO: make xormigrate context aware
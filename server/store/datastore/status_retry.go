@@ -0,0 +1,70 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore
+
+import (
+	"xorm.io/builder"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+)
+
+// StatusRetryCreate queues a status retry, replacing any entry already queued for the same workflow.
+func (s storage) StatusRetryCreate(retry *model.StatusRetry) error {
+	sess := s.engine.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+
+	if _, err := sess.Where("workflow_id = ?", retry.WorkflowID).Delete(new(model.StatusRetry)); err != nil {
+		return err
+	}
+
+	// only Insert set auto created ID back to object
+	if _, err := sess.Insert(retry); err != nil {
+		return err
+	}
+
+	return sess.Commit()
+}
+
+// StatusRetryListDue returns limited number of retries with NextAttempt being less or equal to the provided unix timestamp.
+func (s storage) StatusRetryListDue(now, limit int64) ([]*model.StatusRetry, error) {
+	retries := make([]*model.StatusRetry, 0, limit)
+	return retries, s.engine.Where(builder.Lte{"next_attempt": now}).
+		OrderBy("next_attempt").Limit(int(limit)).Find(&retries)
+}
+
+func (s storage) StatusRetryUpdate(retry *model.StatusRetry) error {
+	_, err := s.engine.ID(retry.ID).AllCols().Update(retry)
+	return err
+}
+
+func (s storage) StatusRetryDelete(id int64) error {
+	return wrapDelete(s.engine.ID(id).Delete(new(model.StatusRetry)))
+}
+
+// StatusRetryGetLock claims a due retry by updating NextAttempt, so only one server replays it.
+func (s storage) StatusRetryGetLock(retry *model.StatusRetry, newNextAttempt int64) (bool, error) {
+	cols, err := s.engine.ID(retry.ID).Where(builder.Eq{"next_attempt": retry.NextAttempt}).
+		Cols("next_attempt").Update(&model.StatusRetry{NextAttempt: newNextAttempt})
+	gotLock := cols != 0
+
+	if err == nil && gotLock {
+		retry.NextAttempt = newNextAttempt
+	}
+
+	return gotLock, err
+}
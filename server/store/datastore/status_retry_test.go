@@ -0,0 +1,74 @@
+// Copyright 2024 Woodpecker Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.woodpecker-ci.org/woodpecker/v3/server/model"
+	"go.woodpecker-ci.org/woodpecker/v3/server/store/types"
+)
+
+func TestStatusRetry(t *testing.T) {
+	store, closer := newTestStore(t, new(model.StatusRetry))
+	defer closer()
+
+	retries, err := store.StatusRetryListDue(1000, 10)
+	assert.NoError(t, err)
+	assert.Len(t, retries, 0)
+
+	assert.NoError(t, store.StatusRetryCreate(&model.StatusRetry{RepoID: 1, PipelineID: 1, WorkflowID: 1, NextAttempt: 100}))
+	assert.NoError(t, store.StatusRetryCreate(&model.StatusRetry{RepoID: 1, PipelineID: 1, WorkflowID: 2, NextAttempt: 2000}))
+
+	// a second failure for the same workflow replaces the queued entry
+	retry := &model.StatusRetry{RepoID: 1, PipelineID: 1, WorkflowID: 1, NextAttempt: 500}
+	assert.NoError(t, store.StatusRetryCreate(retry))
+	assert.NotEqualValues(t, 0, retry.ID)
+
+	retries, err = store.StatusRetryListDue(1000, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, retries, 1) {
+		assert.EqualValues(t, 500, retries[0].NextAttempt)
+	}
+
+	// only the first server claiming a due retry replays it
+	stale := *retry
+	gotLock, err := store.StatusRetryGetLock(retry, 1500)
+	assert.NoError(t, err)
+	assert.True(t, gotLock)
+	assert.EqualValues(t, 1500, retry.NextAttempt)
+	gotLock, err = store.StatusRetryGetLock(&stale, 1500)
+	assert.NoError(t, err)
+	assert.False(t, gotLock)
+
+	retry.Attempts = 1
+	retry.NextAttempt = 3000
+	assert.NoError(t, store.StatusRetryUpdate(retry))
+
+	retries, err = store.StatusRetryListDue(2500, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, retries, 1) {
+		assert.EqualValues(t, 2, retries[0].WorkflowID)
+	}
+
+	assert.NoError(t, store.StatusRetryDelete(retry.ID))
+	assert.ErrorIs(t, store.StatusRetryDelete(retry.ID), types.RecordNotExist)
+
+	retries, err = store.StatusRetryListDue(5000, 10)
+	assert.NoError(t, err)
+	assert.Len(t, retries, 1)
+}
@@ -5469,6 +5469,293 @@ func (_c *MockStore_ServerConfigSet_Call) RunAndReturn(run func(s string, s1 str
 	return _c
 }
 
+// StatusRetryCreate provides a mock function for the type MockStore
+func (_mock *MockStore) StatusRetryCreate(statusRetry *model.StatusRetry) error {
+	ret := _mock.Called(statusRetry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StatusRetryCreate")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(*model.StatusRetry) error); ok {
+		r0 = returnFunc(statusRetry)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStore_StatusRetryCreate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'StatusRetryCreate'
+type MockStore_StatusRetryCreate_Call struct {
+	*mock.Call
+}
+
+// StatusRetryCreate is a helper method to define mock.On call
+//   - statusRetry *model.StatusRetry
+func (_e *MockStore_Expecter) StatusRetryCreate(statusRetry interface{}) *MockStore_StatusRetryCreate_Call {
+	return &MockStore_StatusRetryCreate_Call{Call: _e.mock.On("StatusRetryCreate", statusRetry)}
+}
+
+func (_c *MockStore_StatusRetryCreate_Call) Run(run func(statusRetry *model.StatusRetry)) *MockStore_StatusRetryCreate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 *model.StatusRetry
+		if args[0] != nil {
+			arg0 = args[0].(*model.StatusRetry)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStore_StatusRetryCreate_Call) Return(err error) *MockStore_StatusRetryCreate_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStore_StatusRetryCreate_Call) RunAndReturn(run func(statusRetry *model.StatusRetry) error) *MockStore_StatusRetryCreate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// StatusRetryDelete provides a mock function for the type MockStore
+func (_mock *MockStore) StatusRetryDelete(n int64) error {
+	ret := _mock.Called(n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StatusRetryDelete")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = returnFunc(n)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStore_StatusRetryDelete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'StatusRetryDelete'
+type MockStore_StatusRetryDelete_Call struct {
+	*mock.Call
+}
+
+// StatusRetryDelete is a helper method to define mock.On call
+//   - n int64
+func (_e *MockStore_Expecter) StatusRetryDelete(n interface{}) *MockStore_StatusRetryDelete_Call {
+	return &MockStore_StatusRetryDelete_Call{Call: _e.mock.On("StatusRetryDelete", n)}
+}
+
+func (_c *MockStore_StatusRetryDelete_Call) Run(run func(n int64)) *MockStore_StatusRetryDelete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 int64
+		if args[0] != nil {
+			arg0 = args[0].(int64)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStore_StatusRetryDelete_Call) Return(err error) *MockStore_StatusRetryDelete_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStore_StatusRetryDelete_Call) RunAndReturn(run func(n int64) error) *MockStore_StatusRetryDelete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// StatusRetryGetLock provides a mock function for the type MockStore
+func (_mock *MockStore) StatusRetryGetLock(statusRetry *model.StatusRetry, n int64) (bool, error) {
+	ret := _mock.Called(statusRetry, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StatusRetryGetLock")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(*model.StatusRetry, int64) (bool, error)); ok {
+		return returnFunc(statusRetry, n)
+	}
+	if returnFunc, ok := ret.Get(0).(func(*model.StatusRetry, int64) bool); ok {
+		r0 = returnFunc(statusRetry, n)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(*model.StatusRetry, int64) error); ok {
+		r1 = returnFunc(statusRetry, n)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStore_StatusRetryGetLock_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'StatusRetryGetLock'
+type MockStore_StatusRetryGetLock_Call struct {
+	*mock.Call
+}
+
+// StatusRetryGetLock is a helper method to define mock.On call
+//   - statusRetry *model.StatusRetry
+//   - n int64
+func (_e *MockStore_Expecter) StatusRetryGetLock(statusRetry interface{}, n interface{}) *MockStore_StatusRetryGetLock_Call {
+	return &MockStore_StatusRetryGetLock_Call{Call: _e.mock.On("StatusRetryGetLock", statusRetry, n)}
+}
+
+func (_c *MockStore_StatusRetryGetLock_Call) Run(run func(statusRetry *model.StatusRetry, n int64)) *MockStore_StatusRetryGetLock_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 *model.StatusRetry
+		if args[0] != nil {
+			arg0 = args[0].(*model.StatusRetry)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStore_StatusRetryGetLock_Call) Return(b bool, err error) *MockStore_StatusRetryGetLock_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *MockStore_StatusRetryGetLock_Call) RunAndReturn(run func(statusRetry *model.StatusRetry, n int64) (bool, error)) *MockStore_StatusRetryGetLock_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// StatusRetryListDue provides a mock function for the type MockStore
+func (_mock *MockStore) StatusRetryListDue(n int64, n1 int64) ([]*model.StatusRetry, error) {
+	ret := _mock.Called(n, n1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StatusRetryListDue")
+	}
+
+	var r0 []*model.StatusRetry
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(int64, int64) ([]*model.StatusRetry, error)); ok {
+		return returnFunc(n, n1)
+	}
+	if returnFunc, ok := ret.Get(0).(func(int64, int64) []*model.StatusRetry); ok {
+		r0 = returnFunc(n, n1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.StatusRetry)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(int64, int64) error); ok {
+		r1 = returnFunc(n, n1)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStore_StatusRetryListDue_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'StatusRetryListDue'
+type MockStore_StatusRetryListDue_Call struct {
+	*mock.Call
+}
+
+// StatusRetryListDue is a helper method to define mock.On call
+//   - n int64
+//   - n1 int64
+func (_e *MockStore_Expecter) StatusRetryListDue(n interface{}, n1 interface{}) *MockStore_StatusRetryListDue_Call {
+	return &MockStore_StatusRetryListDue_Call{Call: _e.mock.On("StatusRetryListDue", n, n1)}
+}
+
+func (_c *MockStore_StatusRetryListDue_Call) Run(run func(n int64, n1 int64)) *MockStore_StatusRetryListDue_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 int64
+		if args[0] != nil {
+			arg0 = args[0].(int64)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStore_StatusRetryListDue_Call) Return(statusRetrys []*model.StatusRetry, err error) *MockStore_StatusRetryListDue_Call {
+	_c.Call.Return(statusRetrys, err)
+	return _c
+}
+
+func (_c *MockStore_StatusRetryListDue_Call) RunAndReturn(run func(n int64, n1 int64) ([]*model.StatusRetry, error)) *MockStore_StatusRetryListDue_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// StatusRetryUpdate provides a mock function for the type MockStore
+func (_mock *MockStore) StatusRetryUpdate(statusRetry *model.StatusRetry) error {
+	ret := _mock.Called(statusRetry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for StatusRetryUpdate")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(*model.StatusRetry) error); ok {
+		r0 = returnFunc(statusRetry)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStore_StatusRetryUpdate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'StatusRetryUpdate'
+type MockStore_StatusRetryUpdate_Call struct {
+	*mock.Call
+}
+
+// StatusRetryUpdate is a helper method to define mock.On call
+//   - statusRetry *model.StatusRetry
+func (_e *MockStore_Expecter) StatusRetryUpdate(statusRetry interface{}) *MockStore_StatusRetryUpdate_Call {
+	return &MockStore_StatusRetryUpdate_Call{Call: _e.mock.On("StatusRetryUpdate", statusRetry)}
+}
+
+func (_c *MockStore_StatusRetryUpdate_Call) Run(run func(statusRetry *model.StatusRetry)) *MockStore_StatusRetryUpdate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 *model.StatusRetry
+		if args[0] != nil {
+			arg0 = args[0].(*model.StatusRetry)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStore_StatusRetryUpdate_Call) Return(err error) *MockStore_StatusRetryUpdate_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStore_StatusRetryUpdate_Call) RunAndReturn(run func(statusRetry *model.StatusRetry) error) *MockStore_StatusRetryUpdate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // StepByUUID provides a mock function for the type MockStore
 func (_mock *MockStore) StepByUUID(s string) (*model.Step, error) {
 	ret := _mock.Called(s)
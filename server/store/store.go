@@ -169,6 +169,13 @@ type Store interface {
 	CronListNextExecute(int64, int64) ([]*model.Cron, error)
 	CronGetLock(*model.Cron, int64) (bool, error)
 
+	// StatusRetry
+	StatusRetryCreate(*model.StatusRetry) error
+	StatusRetryListDue(int64, int64) ([]*model.StatusRetry, error)
+	StatusRetryUpdate(*model.StatusRetry) error
+	StatusRetryDelete(int64) error
+	StatusRetryGetLock(*model.StatusRetry, int64) (bool, error)
+
 	// Forge
 	ForgeCreate(*model.Forge) error
 	ForgeGet(int64) (*model.Forge, error)
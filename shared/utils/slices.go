@@ -80,3 +80,33 @@ func StringSliceDeleteEmpty(s []string) []string {
 	}
 	return r
 }
+
+// Deduplicate returns the items of src in their original order without duplicates and zero values.
+func Deduplicate[T comparable](src []T) []T {
+	dst, _ := DeduplicateLimit(src, 0)
+	return dst
+}
+
+// DeduplicateLimit works like Deduplicate but keeps at most limit items if limit is greater than zero.
+// The returned bool reports whether unique items were left out because of the limit.
+func DeduplicateLimit[T comparable](src []T, limit int) ([]T, bool) {
+	var zero T
+	seen := make(map[T]struct{}, len(src))
+	dst := make([]T, 0, len(src))
+
+	for _, v := range src {
+		if v == zero {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		if limit > 0 && len(dst) == limit {
+			return dst, true
+		}
+		seen[v] = struct{}{}
+		dst = append(dst, v)
+	}
+
+	return dst, false
+}
@@ -89,3 +89,26 @@ func TestStringSliceDeleteEmpty(t *testing.T) {
 		assert.EqualValues(t, tc.out, exp, "got '%#v', expects %#v", exp, tc.out)
 	}
 }
+
+func TestDeduplicate(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, Deduplicate([]string{"a", "", "b", "a", "c", "b"}))
+	assert.Equal(t, []int{3, 1}, Deduplicate([]int{3, 0, 3, 1}))
+	assert.Equal(t, []string{}, Deduplicate[string](nil))
+}
+
+func TestDeduplicateLimit(t *testing.T) {
+	files := []string{"a", "b", "a", "c", "d"}
+
+	out, truncated := DeduplicateLimit(files, 0)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, out)
+	assert.False(t, truncated)
+
+	out, truncated = DeduplicateLimit(files, 2)
+	assert.Equal(t, []string{"a", "b"}, out)
+	assert.True(t, truncated)
+
+	// duplicates after the limit was reached don't count as left out
+	out, truncated = DeduplicateLimit([]string{"a", "b", "a", "b"}, 2)
+	assert.Equal(t, []string{"a", "b"}, out)
+	assert.False(t, truncated)
+}
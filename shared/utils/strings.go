@@ -16,21 +16,5 @@ package utils
 
 // DeduplicateStrings deduplicate string list, empty items are dropped.
 func DeduplicateStrings(src []string) []string {
-	m := make(map[string]struct{}, len(src))
-	dst := make([]string, 0, len(src))
-
-	for _, v := range src {
-		// Skip empty items
-		if len(v) == 0 {
-			continue
-		}
-		// Skip duplicates
-		if _, ok := m[v]; ok {
-			continue
-		}
-		m[v] = struct{}{}
-		dst = append(dst, v)
-	}
-
-	return dst
+	return Deduplicate(src)
 }
@@ -1,5 +1,4 @@
 import useConfig from '~/compositions/useConfig';
-import useUserConfig from '~/compositions/useUserConfig';
 
 export default () =>
   ({
@@ -8,10 +7,14 @@ export default () =>
     user: useConfig().user,
 
     authenticate(url?: string, forgeId?: number) {
-      if (url !== undefined) {
-        const config = useUserConfig();
-        config.setUserConfig('redirectUrl', url);
+      // the server returns to url after the login, it is kept in the oauth state
+      const query = new URLSearchParams();
+      if (forgeId !== undefined) {
+        query.set('forge_id', forgeId.toString());
       }
-      window.location.href = `${useConfig().rootPath}/authorize?${forgeId !== undefined ? `forge_id=${forgeId}` : ''}`;
+      if (url) {
+        query.set('url', url);
+      }
+      window.location.href = `${useConfig().rootPath}/authorize?${query.toString()}`;
     },
   }) as const;
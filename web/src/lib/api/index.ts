@@ -60,7 +60,7 @@ export default class WoodpeckerClient extends ApiClient {
     return this._get(`/api/repos/${repoId}/permissions`) as Promise<RepoPermissions>;
   }
 
-  async getRepoBranches(repoId: number, opts?: PaginationOptions): Promise<string[]> {
+  async getRepoBranches(repoId: number, opts?: PaginationOptions & { search?: string }): Promise<string[]> {
     const query = encodeQueryString(opts);
     return this._get(`/api/repos/${repoId}/branches?${query}`) as Promise<string[]>;
   }
@@ -49,6 +49,9 @@ export interface Repo {
   // Whether the repository is publicly visible.
   private: boolean;
 
+  // Whether the repository is a fork of another repository.
+  fork?: boolean;
+
   // Whether the repository has trusted access for pipelines.
   // If the repository is trusted then the host network can be used and
   // volumes can be created.
@@ -63,6 +66,12 @@ export interface Repo {
 
   allow_deploy: boolean;
 
+  // Whether the forge should comment on commits of failed default branch pipelines.
+  comment_on_failure: boolean;
+
+  // Whether draft pull requests should not trigger a pipeline until they are marked as ready.
+  skip_draft_pull_requests: boolean;
+
   config_file: string;
 
   visibility: RepoVisibility;
@@ -109,6 +118,8 @@ export type RepoSettings = Pick<
   | 'approval_allowed_users'
   | 'allow_pr'
   | 'allow_deploy'
+  | 'comment_on_failure'
+  | 'skip_draft_pull_requests'
   | 'cancel_previous_pipeline_events'
   | 'netrc_trusted'
 >;
@@ -12,6 +12,9 @@ export interface User {
   avatar_url: string;
   // The url for the avatar image.
 
+  display_name?: string;
+  // The full name or nickname shown by the forge.
+
   admin: boolean;
   // Whether the account has administrative privileges.
 
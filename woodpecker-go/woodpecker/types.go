@@ -166,13 +166,14 @@ type (
 
 	// Secret represents a secret variable, such as a password or token.
 	Secret struct {
-		ID     int64    `json:"id"`
-		OrgID  int64    `json:"org_id"`
-		RepoID int64    `json:"repo_id"`
-		Name   string   `json:"name"`
-		Value  string   `json:"value,omitempty"`
-		Images []string `json:"images"`
-		Events []string `json:"events"`
+		ID        int64    `json:"id"`
+		OrgID     int64    `json:"org_id"`
+		RepoID    int64    `json:"repo_id"`
+		Name      string   `json:"name"`
+		Value     string   `json:"value,omitempty"`
+		Images    []string `json:"images"`
+		Events    []string `json:"events"`
+		Protected bool     `json:"protected,omitempty"`
 	}
 
 	// Feed represents an item in the user's feed or timeline.